@@ -90,6 +90,11 @@ func (a *Action) insert(buf *Buffer) {
 	if data_chunk != nil {
 		line.Data = append(line.Data, data_chunk...)
 	}
+	buf.stats = nil
+	buf.adjustMarks(a, ActionInsert)
+	buf.adjustChanges(a, ActionInsert)
+	buf.markChange(a, ActionInsert)
+	buf.recordChange(a)
 	buf.Emit(BufferEvent{Type: BufferEventInsert, Action: a})
 }
 
@@ -112,6 +117,11 @@ func (a *Action) delete(buf *Buffer) {
 			line.Data = line.Data[:line.Len()-len(data)]
 		}
 	})
+	buf.stats = nil
+	buf.adjustMarks(a, ActionDelete)
+	buf.adjustChanges(a, ActionDelete)
+	buf.markChange(a, ActionDelete)
+	buf.recordChange(a)
 	buf.Emit(BufferEvent{Type: BufferEventDelete, Action: a})
 }
 
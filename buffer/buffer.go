@@ -2,10 +2,13 @@ package buffer
 
 import (
 	"bufio"
+	"bytes"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"os"
+	"path/filepath"
+	"sort"
 	"unicode/utf8"
 
 	"github.com/kisielk/vigo/utils"
@@ -22,14 +25,15 @@ func (l Line) Len() int {
 	return len(l.Data)
 }
 
-// Find a set of closest offsets for a given visual offset
-func (l *Line) FindClosestOffsets(voffset int) (bo, co, vo int) {
+// Find a set of closest offsets for a given visual offset, with tabstop cells
+// between tab stops.
+func (l *Line) FindClosestOffsets(voffset, tabstop int) (bo, co, vo int) {
 	data := l.Data
 	for len(data) > 0 {
 		var vodif int
 		r, rlen := utf8.DecodeRune(data)
 		data = data[rlen:]
-		vodif = utils.RuneAdvanceLen(r, vo)
+		vodif = utils.RuneAdvanceLen(r, vo, tabstop)
 		if vo+vodif > voffset {
 			return
 		}
@@ -53,6 +57,7 @@ const (
 	BufferEventHistoryForward
 	BufferEventHistoryEnd
 	BufferEventSave
+	BufferEventSign
 )
 
 type BufferEvent struct {
@@ -76,7 +81,28 @@ type Buffer struct {
 	// uniqueness is maintained by godit methods
 	Name string
 
+	// number of action groups which can be undone, the older ones are
+	// forgotten on the next change, 0 if there is no limit
+	UndoLevels int
+
 	listeners []chan BufferEvent
+
+	// cached word and character counts, reset on every modification
+	stats []Stats
+
+	marks map[rune]Cursor
+	signs map[*Line]Sign
+
+	// cursors kept by other packages, moved as the marks are
+	tracked []*Cursor
+
+	// positions of the last changes, the oldest first, visited by g; and g,
+	changes     []Cursor
+	changeIndex int // of the position last visited, len(changes) if none
+
+	// action group of the last change, extended by the next changes
+	// in the same group
+	changeGroup *ActionGroup
 }
 
 func NewEmptyBuffer() *Buffer {
@@ -88,6 +114,7 @@ func NewEmptyBuffer() *Buffer {
 	b.LastLine = l
 	b.NumLines = 1
 	b.listeners = []chan BufferEvent{}
+	b.UndoLevels = DefaultUndoLevels
 	b.initHistory()
 	return b
 }
@@ -105,6 +132,12 @@ func (b *Buffer) RemoveListener(c chan BufferEvent) {
 	}
 }
 
+// NumListeners returns the number of channels the events of the buffer are
+// sent to, one for each view attached to it.
+func (b *Buffer) NumListeners() int {
+	return len(b.listeners)
+}
+
 func (b *Buffer) Emit(e BufferEvent) {
 	for i := 0; i < len(b.listeners); i++ {
 		b.listeners[i] <- e
@@ -147,6 +180,7 @@ func NewBuffer(r io.Reader) (*Buffer, error) {
 	}
 
 	// history
+	b.UndoLevels = DefaultUndoLevels
 	b.initHistory()
 	return b, err
 }
@@ -176,6 +210,27 @@ func (b *Buffer) InsertRune(c Cursor, r rune) {
 	}
 }
 
+// InsertLineBelow inserts a line holding data after the line of the cursor c,
+// as a single action, and returns a cursor at the end of the new line.
+func (b *Buffer) InsertLineBelow(c Cursor, data []byte) Cursor {
+	c.MoveEOL()
+	b.Insert(c, append([]byte{'\n'}, data...))
+	c.Line = c.Line.Next
+	c.LineNum++
+	c.Boffset = len(data)
+	return c
+}
+
+// InsertLineAbove inserts a line holding data before the line of the cursor
+// c, as a single action, and returns a cursor at the end of the new line.
+func (b *Buffer) InsertLineAbove(c Cursor, data []byte) Cursor {
+	c.Boffset = 0
+	b.Insert(c, append(utils.CloneByteSlice(data), '\n'))
+	// the text of the cursor line moved to a line after it
+	c.Boffset = len(data)
+	return c
+}
+
 // If at the EOL, move contents of the next line to the end of the current line,
 // erasing the next line after that. Otherwise, delete one character under the
 // cursor.
@@ -215,6 +270,17 @@ func (b *Buffer) DeleteRuneBackward(c Cursor) {
 	b.Delete(c, rlen)
 }
 
+// LineCursor returns a cursor at the beginning of the line n, or of the
+// nearest line if n is out of the bounds of the buffer.
+func (b *Buffer) LineCursor(n int) Cursor {
+	c := Cursor{Line: b.FirstLine, LineNum: 1}
+	for c.LineNum < n && c.Line.Next != nil {
+		c.Line = c.Line.Next
+		c.LineNum++
+	}
+	return c
+}
+
 // InsertLine inserts a line after prev in the buffer.
 // If prev is nil then the line will be the new first line of the buffer.
 func (b *Buffer) InsertLine(line *Line, prev *Line) {
@@ -257,6 +323,7 @@ func (b *Buffer) DeleteLine(line *Line) {
 	}
 	line.Data = line.Data[:0]
 	b.NumLines--
+	delete(b.signs, line)
 }
 
 // maybeNextActionGroup moves history forward one action group and
@@ -273,6 +340,39 @@ func (b *Buffer) maybeNextActionGroup() {
 	b.History.Prev = prev
 	b.History.Next = nil
 	b.History.Actions = nil
+	b.pruneHistory()
+}
+
+// DefaultUndoLevels is the number of changes which can be undone in a new
+// buffer.
+const DefaultUndoLevels = 1000
+
+// pruneHistory forgets the oldest action groups beyond UndoLevels. The
+// newest of the forgotten groups becomes the sentinel of the history.
+func (b *Buffer) pruneHistory() {
+	if b.UndoLevels <= 0 {
+		return
+	}
+	g := b.History
+	for i := 0; i < b.UndoLevels; i++ {
+		if g.Prev == nil {
+			return
+		}
+		g = g.Prev
+	}
+	if g.Prev == nil {
+		// already the sentinel
+		return
+	}
+	for old := g.Prev; old != nil; old = old.Prev {
+		if old == b.onDisk {
+			// the contents on disk can't be reached by undo anymore
+			b.onDisk = nil
+		}
+	}
+	g.Prev.Next = nil
+	g.Prev = nil
+	g.Actions = nil
 }
 
 func (b *Buffer) FinalizeActionGroup() {
@@ -284,6 +384,46 @@ func (b *Buffer) FinalizeActionGroup() {
 	}
 }
 
+// JoinActionGroups makes the changes done since g was the current action
+// group a single one, undone at once, such as the changes of a command run
+// on several lines. It does nothing if g was undone or forgotten.
+func (b *Buffer) JoinActionGroups(g *ActionGroup) {
+	var first *ActionGroup
+	for x := b.History; x != g; x = x.Prev {
+		if x == nil {
+			return
+		}
+		first = x
+	}
+	last := b.History
+	if first == nil || first == last {
+		return
+	}
+	for x := first; ; x = x.Next {
+		if x != first {
+			first.Actions = append(first.Actions, x.Actions...)
+		}
+		if x == b.onDisk {
+			// the contents on disk are only those after the last group
+			b.onDisk = nil
+			if x == last {
+				b.onDisk = first
+			}
+		}
+		if x == b.changeGroup {
+			b.changeGroup = first
+		}
+		if x == last {
+			break
+		}
+	}
+	first.Next = last.Next
+	if first.Next != nil {
+		first.Next.Prev = first
+	}
+	b.History = first
+}
+
 func (b *Buffer) Insert(c Cursor, data []byte) {
 	b.maybeNextActionGroup()
 
@@ -314,6 +454,7 @@ func (b *Buffer) Undo() {
 	}
 	// undo action causes finalization, always
 	b.FinalizeActionGroup()
+	b.changeGroup = nil
 	// undo invariant tells us 'len(b.history.actions) != 0' in case if this is
 	// not a sentinel, revert the actions in the current action group
 	for i := len(b.History.Actions) - 1; i >= 0; i-- {
@@ -338,6 +479,7 @@ func (b *Buffer) Redo() {
 	}
 	// move one entry forward, and redo all its actions
 	b.History = b.History.Next
+	b.changeGroup = nil
 	for i := range b.History.Actions {
 		a := &b.History.Actions[i]
 		a.Apply(b)
@@ -348,27 +490,325 @@ func (b *Buffer) Redo() {
 // CleanupTrailingSpaces removes trailing whitespace
 // characters from every line in the buffer.
 func (b *Buffer) CleanupTrailingSpaces() {
-	cursor := Cursor{
-		Line:    b.FirstLine,
-		LineNum: 1,
-		Boffset: 0,
-	}
-	for cursor.Line != nil {
+	b.TrimTrailingSpaces(1, b.NumLines)
+}
+
+// TrimTrailingSpaces removes the trailing whitespace characters of the lines
+// from to to, both included. It returns the number of changed lines.
+func (b *Buffer) TrimTrailingSpaces(from, to int) int {
+	cursor := b.LineCursor(from)
+	changed := 0
+	for cursor.Line != nil && cursor.LineNum <= to {
 		llen := cursor.Line.Len()
-		i := utils.IndexLastNonSpace(cursor.Line.Data)
-		if i == -1 && llen > 0 {
-			// the whole string is whitespace
-			b.Delete(cursor, llen)
-		}
-		if i != -1 && i != llen-1 {
-			// some whitespace at the end
-			cursor.Boffset = i + 1
+		// -1 if the whole line is whitespace
+		cursor.Boffset = utils.IndexLastNonSpace(cursor.Line.Data) + 1
+		if cursor.Boffset < llen {
 			b.Delete(cursor, llen-cursor.Boffset)
+			changed++
+		}
+		cursor.Line = cursor.Line.Next
+		cursor.LineNum++
+	}
+	return changed
+}
+
+// Retab rewrites the leading whitespace of the lines from to to, both
+// included, with tabs and spaces, or only with spaces if expandTab is set,
+// with tabstop cells between tab stops. If all is set, every run of whitespace
+// in the lines is rewritten. It returns the number of changed lines.
+func (b *Buffer) Retab(from, to, tabstop int, expandTab, all bool) int {
+	cursor := b.LineCursor(from)
+	changed := 0
+	for cursor.Line != nil && cursor.LineNum <= to {
+		if b.retabLine(cursor, tabstop, expandTab, all) {
+			changed++
 		}
 		cursor.Line = cursor.Line.Next
 		cursor.LineNum++
-		cursor.Boffset = 0
 	}
+	return changed
+}
+
+// retabLine rewrites the whitespace of the line of the cursor, as described
+// by Retab. It reports whether the line was changed.
+func (b *Buffer) retabLine(cursor Cursor, tabstop int, expandTab, all bool) bool {
+	type run struct {
+		start, end int    // byte offsets
+		data       []byte // replacement
+	}
+	var runs []run
+	data := cursor.Line.Data
+	vo := 0
+	for i := 0; i < len(data); {
+		if data[i] != ' ' && data[i] != '\t' {
+			if !all {
+				break
+			}
+			r, rlen := utf8.DecodeRune(data[i:])
+			vo += utils.RuneAdvanceLen(r, vo, tabstop)
+			i += rlen
+			continue
+		}
+		j, end := i, vo
+		for ; j < len(data) && (data[j] == ' ' || data[j] == '\t'); j++ {
+			end += utils.RuneAdvanceLen(rune(data[j]), end, tabstop)
+		}
+		if ws := utils.MakeWhitespace(vo, end, tabstop, expandTab); !bytes.Equal(ws, data[i:j]) {
+			runs = append(runs, run{i, j, ws})
+		}
+		i, vo = j, end
+	}
+
+	// Replace from the end of the line, so that the offsets of the
+	// remaining runs stay valid.
+	for i := len(runs) - 1; i >= 0; i-- {
+		cursor.Boffset = runs[i].start
+		b.Delete(cursor, runs[i].end-runs[i].start)
+		if len(runs[i].data) > 0 {
+			b.Insert(cursor, runs[i].data)
+		}
+	}
+	return len(runs) > 0
+}
+
+// Reindent sets the indentation of the lines from to to, both included,
+// following the previous non-blank line. Lines after one ending with an
+// opening bracket get one more shiftWidth, lines starting with a closing
+// bracket one less. It returns the number of changed lines.
+func (b *Buffer) Reindent(from, to, shiftWidth, tabstop int, expandTab bool) int {
+	cursor := b.LineCursor(from)
+
+	width := 0
+	for l := cursor.Line.Prev; l != nil; l = l.Prev {
+		if data := bytes.TrimSpace(l.Data); len(data) > 0 {
+			width = utils.IndentWidth(l.Data, tabstop)
+			if bytes.IndexByte([]byte("{(["), data[len(data)-1]) != -1 {
+				width += shiftWidth
+			}
+			break
+		}
+	}
+
+	changed := 0
+	for cursor.Line != nil && cursor.LineNum <= to {
+		data := bytes.TrimSpace(cursor.Line.Data)
+		var indent []byte
+		if len(data) > 0 {
+			w := width
+			if bytes.IndexByte([]byte("})]"), data[0]) != -1 {
+				w -= shiftWidth
+			}
+			indent = utils.MakeIndent(w, tabstop, expandTab)
+			width = w
+			if bytes.IndexByte([]byte("{(["), data[len(data)-1]) != -1 {
+				width += shiftWidth
+			}
+		}
+
+		n := utils.IndexFirstNonSpace(cursor.Line.Data)
+		if !bytes.Equal(cursor.Line.Data[:n], indent) {
+			cursor.Boffset = 0
+			if n > 0 {
+				b.Delete(cursor, n)
+			}
+			if len(indent) > 0 {
+				b.Insert(cursor, indent)
+			}
+			changed++
+		}
+		cursor.Line = cursor.Line.Next
+		cursor.LineNum++
+	}
+	return changed
+}
+
+// JoinLines joins count lines, at least two, starting at the line of the
+// cursor c. If spaces is set, the leading whitespace of the joined lines is
+// removed and a space is put between the lines, unless the first one ends
+// with whitespace or is empty, or the next one is empty or starts with a
+// closing parenthesis. Otherwise the lines are joined as they are. It
+// returns a cursor at the last join, and false if there was no line to join.
+func (b *Buffer) JoinLines(c Cursor, count int, spaces bool) (Cursor, bool) {
+	joined := false
+	for i := 1; i < count || i == 1; i++ {
+		c.MoveEOL()
+		if c.LastLine() {
+			break
+		}
+		next := c.Line.Next.Data
+		if !spaces {
+			b.Delete(c, 1)
+			joined = true
+			continue
+		}
+
+		n := utils.IndexFirstNonSpace(next)
+		space := len(c.Line.Data) > 0 && n < len(next) && next[n] != ')'
+		if l := len(c.Line.Data); l > 0 && (c.Line.Data[l-1] == ' ' || c.Line.Data[l-1] == '\t') {
+			space = false
+		}
+		b.Delete(c, 1+n)
+		if space {
+			b.Insert(c, []byte{' '})
+		}
+		joined = true
+	}
+	return c, joined
+}
+
+// Alignment is the placement of the text of a line by Align.
+type Alignment int
+
+const (
+	AlignLeft Alignment = iota
+	AlignCenter
+	AlignRight
+)
+
+// Align places the text of the lines from to to, both included, within width
+// cells, replacing their leading and trailing whitespace. Left aligned lines
+// are indented by width cells instead. Blank lines are emptied. It returns the
+// number of changed lines.
+func (b *Buffer) Align(from, to int, align Alignment, width, tabstop int, expandTab bool) int {
+	cursor := b.LineCursor(from)
+	changed := 0
+	for cursor.Line != nil && cursor.LineNum <= to {
+		text := bytes.TrimSpace(cursor.Line.Data)
+		var data []byte
+		if len(text) > 0 {
+			textWidth := 0
+			for _, r := range string(text) {
+				textWidth += utils.RuneAdvanceLen(r, textWidth, tabstop)
+			}
+			indent := width
+			switch align {
+			case AlignCenter:
+				indent = (width - textWidth) / 2
+			case AlignRight:
+				indent = width - textWidth
+			}
+			if indent < 0 {
+				indent = 0
+			}
+			data = append(utils.MakeIndent(indent, tabstop, expandTab), text...)
+		}
+
+		if !bytes.Equal(cursor.Line.Data, data) {
+			cursor.Boffset = 0
+			if len(cursor.Line.Data) > 0 {
+				b.Delete(cursor, len(cursor.Line.Data))
+			}
+			if len(data) > 0 {
+				b.Insert(cursor, data)
+			}
+			changed++
+		}
+		cursor.Line = cursor.Line.Next
+		cursor.LineNum++
+	}
+	return changed
+}
+
+// SortLines sorts the lines from to to, both included, in byte order, or in
+// reverse order if reverse is set. Only the first of equal lines is kept if
+// unique is set. It returns the number of removed lines.
+func (b *Buffer) SortLines(from, to int, reverse, unique bool) int {
+	lines := b.rangeLines(from, to)
+	sorted := make([][]byte, len(lines))
+	copy(sorted, lines)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if reverse {
+			return bytes.Compare(sorted[i], sorted[j]) > 0
+		}
+		return bytes.Compare(sorted[i], sorted[j]) < 0
+	})
+	if unique {
+		n := 0
+		for i, l := range sorted {
+			if i == 0 || !bytes.Equal(l, sorted[n-1]) {
+				sorted[n] = l
+				n++
+			}
+		}
+		sorted = sorted[:n]
+	}
+	b.replaceLines(from, to, lines, sorted)
+	return len(lines) - len(sorted)
+}
+
+// UniqLines removes the lines from to to, both included, which are equal to
+// one of the lines before them, keeping the order of the others. It returns
+// the number of removed lines.
+func (b *Buffer) UniqLines(from, to int) int {
+	lines := b.rangeLines(from, to)
+	seen := make(map[string]bool)
+	var kept [][]byte
+	for _, l := range lines {
+		if !seen[string(l)] {
+			seen[string(l)] = true
+			kept = append(kept, l)
+		}
+	}
+	b.replaceLines(from, to, lines, kept)
+	return len(lines) - len(kept)
+}
+
+// rangeLines returns copies of the lines from to to, both included.
+func (b *Buffer) rangeLines(from, to int) [][]byte {
+	var lines [][]byte
+	for c := b.LineCursor(from); c.Line != nil && c.LineNum <= to; c.Line, c.LineNum = c.Line.Next, c.LineNum+1 {
+		lines = append(lines, append([]byte(nil), c.Line.Data...))
+	}
+	return lines
+}
+
+// replaceLines replaces the lines from to to, both included, whose contents
+// are old, with the lines new, unless they are the same.
+func (b *Buffer) replaceLines(from, to int, old, new [][]byte) {
+	text := bytes.Join(new, []byte{'\n'})
+	if bytes.Equal(bytes.Join(old, []byte{'\n'}), text) {
+		return
+	}
+	start, end := b.LineCursor(from), b.LineCursor(to)
+	end.MoveEOL()
+	b.Delete(start, start.Distance(end))
+	if len(text) > 0 {
+		b.Insert(start, text)
+	}
+}
+
+// ToggleComment comments out the lines from to to, both included, by adding
+// prefix and a space after their indentation. If all of them are already
+// commented out, the prefix and the space following it are removed instead.
+// Blank lines are left alone. It returns whether the lines were commented.
+func (b *Buffer) ToggleComment(from, to int, prefix []byte) bool {
+	commented := true
+	for l, n := b.LineCursor(from).Line, from; l != nil && n <= to; l, n = l.Next, n+1 {
+		data := bytes.TrimLeft(l.Data, " \t")
+		if len(data) > 0 && !bytes.HasPrefix(data, prefix) {
+			commented = false
+			break
+		}
+	}
+
+	cursor := b.LineCursor(from)
+	for cursor.Line != nil && cursor.LineNum <= to {
+		cursor.Boffset = utils.IndexFirstNonSpace(cursor.Line.Data)
+		if cursor.Boffset < len(cursor.Line.Data) {
+			if commented {
+				n := len(prefix)
+				if rest := cursor.Line.Data[cursor.Boffset+n:]; len(rest) > 0 && rest[0] == ' ' {
+					n++
+				}
+				b.Delete(cursor, n)
+			} else {
+				b.Insert(cursor, append(append([]byte{}, prefix...), ' '))
+			}
+		}
+		cursor.Line = cursor.Line.Next
+		cursor.LineNum++
+	}
+	return !commented
 }
 
 // CleanupTrailingNewlines removes all but one trailing newlines.
@@ -451,15 +891,7 @@ func (b *Buffer) SaveAs(filename string) error {
 	b.CleanupTrailingNewlines()
 	b.EnsureTrailingEOL()
 
-	r := b.reader()
-	f, err := os.Create(filename)
-	if err != nil {
-		return err
-	}
-	defer f.Close()
-
-	_, err = io.Copy(f, r)
-	if err != nil {
+	if err := writeFileAtomic(filename, b.reader()); err != nil {
 		return err
 	}
 
@@ -477,6 +909,92 @@ func (b *Buffer) reader() *BufferReader {
 	return newBufferReader(b)
 }
 
+// LinesReader returns a reader of the lines from to to, both included. Every
+// line but the last one of the buffer is followed by a newline.
+func (b *Buffer) LinesReader(from, to int) io.Reader {
+	br := newBufferReader(b)
+	br.Line = b.LineCursor(from).Line
+	br.end = b.LineCursor(to).Line
+	return br
+}
+
+// AppendTo appends the lines from to to, both included, to the existing
+// file filename.
+func (b *Buffer) AppendTo(filename string, from, to int) error {
+	f, err := os.OpenFile(filename, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, b.LinesReader(from, to)); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// WriteLines writes the lines from to to, both included, to the file
+// filename. An existing file is only replaced if overwrite is set.
+func (b *Buffer) WriteLines(filename string, from, to int, overwrite bool) error {
+	if _, err := os.Stat(filename); err == nil && !overwrite {
+		return fmt.Errorf("%s exists", filename)
+	}
+	return writeFileAtomic(filename, b.LinesReader(from, to))
+}
+
+// writeFileAtomic writes the contents of r to a temporary file, which then
+// replaces the file filename. The file is left untouched on errors. The
+// target of a symbolic link is replaced, keeping the link. When the
+// temporary file can't be created next to the file, or can't be given the
+// owner of the file, the file is written in place instead.
+func writeFileAtomic(filename string, r io.Reader) error {
+	if target, err := filepath.EvalSymlinks(filename); err == nil {
+		filename = target
+	}
+	mode := os.FileMode(0666)
+	fi, statErr := os.Stat(filename)
+	if statErr == nil {
+		mode = fi.Mode()
+	}
+
+	f, err := ioutil.TempFile(filepath.Dir(filename), "."+filepath.Base(filename)+".")
+	if err != nil {
+		return writeFile(filename, r)
+	}
+	defer os.Remove(f.Name())
+	_, err = io.Copy(f, r)
+	if err == nil {
+		err = f.Chmod(mode)
+	}
+	if err == nil && statErr == nil && chown(f, fi) != nil {
+		// the whole contents are written, copy them over the file
+		if _, err = f.Seek(0, io.SeekStart); err == nil {
+			err = writeFile(filename, f)
+		}
+		f.Close()
+		return err
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), filename)
+	}
+	return err
+}
+
+// writeFile writes the contents of r to the file filename in place.
+func writeFile(filename string, r io.Reader) error {
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
 func (b *Buffer) contents() []byte {
 	data, _ := ioutil.ReadAll(b.reader())
 	return data
@@ -486,6 +1004,7 @@ type BufferReader struct {
 	buffer *Buffer
 	Line   *Line
 	offset int
+	end    *Line // last line to read, to the end of the buffer if nil
 }
 
 func newBufferReader(buffer *Buffer) *BufferReader {
@@ -523,7 +1042,11 @@ func (br *BufferReader) Read(data []byte) (int, error) {
 			nread++
 		}
 
-		br.Line = br.Line.Next
+		if br.Line == br.end {
+			br.Line = nil
+		} else {
+			br.Line = br.Line.Next
+		}
 		br.offset = 0
 	}
 	return nread, nil
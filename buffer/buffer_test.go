@@ -2,6 +2,9 @@ package buffer
 
 import (
 	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 )
@@ -76,6 +79,39 @@ func TestInsertLine(t *testing.T) {
 	checkLines(t, b, l3, first, l2, l1)
 }
 
+func TestInsertLineAboveBelow(t *testing.T) {
+	b, err := NewBuffer(strings.NewReader("one\ntwo"))
+	if err != nil {
+		t.Fatal("Error creating buffer")
+	}
+	c := b.InsertLineAbove(b.LineCursor(1), []byte("zero"))
+	if c.LineNum != 1 || c.Boffset != 4 || string(c.Line.Data) != "zero" {
+		t.Errorf("above the first line: got %d:%d %q", c.LineNum, c.Boffset, c.Line.Data)
+	}
+	c = b.InsertLineBelow(b.LineCursor(3), []byte("three"))
+	if c.LineNum != 4 || c.Boffset != 5 || c.Line != b.LastLine {
+		t.Errorf("below the last line: got %d:%d %q", c.LineNum, c.Boffset, c.Line.Data)
+	}
+	checkLineBytes(t, b, [][]byte{
+		[]byte("zero"),
+		[]byte("one"),
+		[]byte("two"),
+		[]byte("three"),
+	})
+
+	// each insertion is a single action
+	b.FinalizeActionGroup()
+	b.InsertLineBelow(b.LineCursor(1), nil)
+	b.FinalizeActionGroup()
+	if n := len(b.History.Actions); n != 1 {
+		t.Errorf("got %d actions for an insertion, want 1", n)
+	}
+	b.Undo()
+	if b.NumLines != 4 {
+		t.Errorf("got %d lines after undo, want 4", b.NumLines)
+	}
+}
+
 func TestDeleteFirstLine(t *testing.T) {
 	b, err := NewBuffer(strings.NewReader("foo\nbar"))
 	if err != nil {
@@ -159,6 +195,272 @@ func TestCleanupTrailingSpaces(t *testing.T) {
 	})
 }
 
+func TestTrimTrailingSpaces(t *testing.T) {
+	b, err := NewBuffer(strings.NewReader(" blah \n \t\nbar   \n  baz \n"))
+	if err != nil {
+		t.Error("Error creating buffer")
+	}
+	if n := b.TrimTrailingSpaces(2, 3); n != 2 {
+		t.Errorf("wrong number of changed lines: got %d, want 2", n)
+	}
+	checkLineBytes(t, b, [][]byte{
+		[]byte(" blah "),
+		[]byte(""),
+		[]byte("bar"),
+		[]byte("  baz "),
+		[]byte(""),
+	})
+}
+
+func TestLineCursor(t *testing.T) {
+	b, err := NewBuffer(strings.NewReader("foo\nbar\nbaz"))
+	if err != nil {
+		t.Error("Error creating buffer")
+	}
+	tests := []struct {
+		n, lineNum int
+		data       string
+	}{
+		{-1, 1, "foo"},
+		{1, 1, "foo"},
+		{2, 2, "bar"},
+		{3, 3, "baz"},
+		{4, 3, "baz"},
+	}
+	for _, test := range tests {
+		c := b.LineCursor(test.n)
+		if c.LineNum != test.lineNum || string(c.Line.Data) != test.data || c.Boffset != 0 {
+			t.Errorf("%d: got line %d %q at %d", test.n, c.LineNum, c.Line.Data, c.Boffset)
+		}
+	}
+}
+
+func TestLinesReader(t *testing.T) {
+	b, err := NewBuffer(strings.NewReader("foo\nbar\nbaz\n"))
+	if err != nil {
+		t.Error("Error creating buffer")
+	}
+	tests := []struct {
+		from, to int
+		want     string
+	}{
+		{1, 1, "foo\n"},
+		{2, 3, "bar\nbaz\n"},
+		{1, 4, "foo\nbar\nbaz\n"},
+		{3, 4, "baz\n"},
+	}
+	for _, test := range tests {
+		data, err := ioutil.ReadAll(b.LinesReader(test.from, test.to))
+		if err != nil || string(data) != test.want {
+			t.Errorf("%d,%d: got %q, %v, want %q", test.from, test.to, data, err, test.want)
+		}
+	}
+}
+
+func TestWriteLines(t *testing.T) {
+	dir, err := ioutil.TempDir("", "vigo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	b, err := NewBuffer(strings.NewReader("foo\nbar\nbaz\n"))
+	if err != nil {
+		t.Error("Error creating buffer")
+	}
+	filename := filepath.Join(dir, "out")
+	if err := b.WriteLines(filename, 2, 3, false); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.WriteLines(filename, 1, 1, false); err == nil {
+		t.Error("existing file overwritten")
+	}
+	if err := b.AppendTo(filename, 1, 1); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := ioutil.ReadFile(filename); string(data) != "bar\nbaz\nfoo\n" {
+		t.Errorf("bad file contents: %q", data)
+	}
+	if err := b.WriteLines(filename, 1, 1, true); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := ioutil.ReadFile(filename); string(data) != "foo\n" {
+		t.Errorf("bad file contents after overwrite: %q", data)
+	}
+
+	// the target of a link is replaced, keeping the link
+	link := filepath.Join(dir, "link")
+	if err := os.Symlink(filename, link); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.WriteLines(link, 3, 3, true); err != nil {
+		t.Fatal(err)
+	}
+	if fi, err := os.Lstat(link); err != nil || fi.Mode()&os.ModeSymlink == 0 {
+		t.Errorf("the link was replaced: %v", err)
+	}
+	if data, _ := ioutil.ReadFile(filename); string(data) != "baz\n" {
+		t.Errorf("bad file contents through the link: %q", data)
+	}
+	if names, _ := filepath.Glob(filepath.Join(dir, ".*")); len(names) != 0 {
+		t.Errorf("temporary files left: %v", names)
+	}
+}
+
+func TestRetab(t *testing.T) {
+	b, err := NewBuffer(strings.NewReader("        foo\n\t  bar\tbaz\n    \tqux  quux\n"))
+	if err != nil {
+		t.Error("Error creating buffer")
+	}
+	if n := b.Retab(1, 3, 8, false, false); n != 2 {
+		t.Errorf("wrong number of changed lines: got %d, want 2", n)
+	}
+	checkLineBytes(t, b, [][]byte{
+		[]byte("\tfoo"),
+		[]byte("\t  bar\tbaz"),
+		[]byte("\tqux  quux"),
+		[]byte(""),
+	})
+
+	b.FinalizeActionGroup()
+	if n := b.Retab(2, 2, 8, true, true); n != 1 {
+		t.Errorf("wrong number of changed lines: got %d, want 1", n)
+	}
+	checkLineBytes(t, b, [][]byte{
+		[]byte("\tfoo"),
+		[]byte("          bar   baz"),
+		[]byte("\tqux  quux"),
+		[]byte(""),
+	})
+
+	b.Undo()
+	checkLineBytes(t, b, [][]byte{
+		[]byte("\tfoo"),
+		[]byte("\t  bar\tbaz"),
+		[]byte("\tqux  quux"),
+		[]byte(""),
+	})
+}
+
+func TestReindent(t *testing.T) {
+	b, err := NewBuffer(strings.NewReader("func f() {\n  if x {\n\n      y()\n        }\n}\n"))
+	if err != nil {
+		t.Error("Error creating buffer")
+	}
+	if n := b.Reindent(2, 6, 4, 8, true); n != 3 {
+		t.Errorf("wrong number of changed lines: got %d, want 3", n)
+	}
+	checkLineBytes(t, b, [][]byte{
+		[]byte("func f() {"),
+		[]byte("    if x {"),
+		[]byte(""),
+		[]byte("        y()"),
+		[]byte("    }"),
+		[]byte("}"),
+		[]byte(""),
+	})
+}
+
+func TestJoinLines(t *testing.T) {
+	b, err := NewBuffer(strings.NewReader("foo\n  bar\nbaz \n)\n\nqux\n"))
+	if err != nil {
+		t.Fatal("Error creating buffer")
+	}
+	c, ok := b.JoinLines(b.LineCursor(1), 1, true)
+	if !ok || c.Boffset != 3 {
+		t.Errorf("got cursor at %d, %v", c.Boffset, ok)
+	}
+	checkLineBytes(t, b, [][]byte{
+		[]byte("foo bar"),
+		[]byte("baz "),
+		[]byte(")"),
+		[]byte(""),
+		[]byte("qux"),
+		[]byte(""),
+	})
+
+	// trailing whitespace, closing parenthesis and empty line
+	b.JoinLines(b.LineCursor(2), 4, true)
+	checkLineBytes(t, b, [][]byte{
+		[]byte("foo bar"),
+		[]byte("baz ) qux"),
+		[]byte(""),
+	})
+
+	b, err = NewBuffer(strings.NewReader("foo\n  bar\n)\n"))
+	if err != nil {
+		t.Fatal("Error creating buffer")
+	}
+	b.JoinLines(b.LineCursor(1), 3, false)
+	checkLineBytes(t, b, [][]byte{
+		[]byte("foo  bar)"),
+		[]byte(""),
+	})
+	if _, ok := b.JoinLines(b.LineCursor(2), 2, true); ok {
+		t.Error("joined the last line")
+	}
+}
+
+func TestAlign(t *testing.T) {
+	b, err := NewBuffer(strings.NewReader("  foo  \n\t\nbarbaz\n"))
+	if err != nil {
+		t.Fatal("Error creating buffer")
+	}
+	if n := b.Align(1, 3, AlignCenter, 10, 8, true); n != 3 {
+		t.Errorf("got %d changed lines, want 3", n)
+	}
+	checkLineBytes(t, b, [][]byte{
+		[]byte("   foo"),
+		[]byte(""),
+		[]byte("  barbaz"),
+		[]byte(""),
+	})
+	b.Align(1, 3, AlignRight, 10, 8, true)
+	checkLineBytes(t, b, [][]byte{
+		[]byte("       foo"),
+		[]byte(""),
+		[]byte("    barbaz"),
+		[]byte(""),
+	})
+	b.Align(1, 1, AlignLeft, 2, 8, true)
+	checkLineBytes(t, b, [][]byte{
+		[]byte("  foo"),
+		[]byte(""),
+		[]byte("    barbaz"),
+		[]byte(""),
+	})
+}
+
+func TestToggleComment(t *testing.T) {
+	b, err := NewBuffer(strings.NewReader("foo\n\n\t// bar\n\tbaz\n"))
+	if err != nil {
+		t.Error("Error creating buffer")
+	}
+	if !b.ToggleComment(1, 4, []byte("//")) {
+		t.Error("lines not commented")
+	}
+	checkLineBytes(t, b, [][]byte{
+		[]byte("// foo"),
+		[]byte(""),
+		[]byte("\t// // bar"),
+		[]byte("\t// baz"),
+		[]byte(""),
+	})
+	if b.ToggleComment(1, 4, []byte("//")) {
+		t.Error("lines not uncommented")
+	}
+	if b.ToggleComment(3, 3, []byte("//")) {
+		t.Error("line not uncommented")
+	}
+	checkLineBytes(t, b, [][]byte{
+		[]byte("foo"),
+		[]byte(""),
+		[]byte("\tbar"),
+		[]byte("\tbaz"),
+		[]byte(""),
+	})
+}
+
 func TestCleanupTrailingNewlines(t *testing.T) {
 	b, err := NewBuffer(strings.NewReader("\nfoo\n\nbar\n\n"))
 	if err != nil {
@@ -186,3 +488,113 @@ func TestEnsureTrailingEOL(t *testing.T) {
 		[]byte(""),
 	})
 }
+
+func TestUndoLevels(t *testing.T) {
+	b, err := NewBuffer(strings.NewReader("\n"))
+	if err != nil {
+		t.Fatal("Error creating buffer")
+	}
+	b.UndoLevels = 3
+	b.onDisk = b.History.Next
+	for i := 0; i < 10; i++ {
+		b.Insert(Cursor{Line: b.FirstLine, LineNum: 1, Boffset: i}, []byte{'a' + byte(i)})
+		b.FinalizeActionGroup()
+
+		n := 0
+		for g := b.History; g.Prev != nil; g = g.Prev {
+			n++
+		}
+		if n > 3 {
+			t.Fatalf("%d changes: got %d action groups, want at most 3", i+1, n)
+		}
+	}
+	if b.onDisk != nil {
+		t.Error("forgotten action group still marked as on disk")
+	}
+
+	for i := 0; i < 5; i++ {
+		b.Undo()
+	}
+	checkLineBytes(t, b, [][]byte{[]byte("abcdefg"), []byte("")})
+	b.Redo()
+	checkLineBytes(t, b, [][]byte{[]byte("abcdefgh"), []byte("")})
+}
+
+func TestJoinActionGroups(t *testing.T) {
+	b, err := NewBuffer(strings.NewReader("\n"))
+	if err != nil {
+		t.Fatal("Error creating buffer")
+	}
+	b.Insert(Cursor{Line: b.FirstLine, LineNum: 1}, []byte("a"))
+	b.FinalizeActionGroup()
+	g := b.History
+	for i := 1; i < 4; i++ {
+		b.Insert(Cursor{Line: b.FirstLine, LineNum: 1, Boffset: i}, []byte{'a' + byte(i)})
+		b.FinalizeActionGroup()
+	}
+	b.JoinActionGroups(g)
+	if b.History.Prev != g {
+		t.Error("the changes weren't joined")
+	}
+
+	b.Undo()
+	checkLineBytes(t, b, [][]byte{[]byte("a"), []byte("")})
+	b.Redo()
+	checkLineBytes(t, b, [][]byte{[]byte("abcd"), []byte("")})
+
+	// after g was undone
+	b.Undo()
+	b.Undo()
+	b.JoinActionGroups(g)
+	b.Redo()
+	b.Redo()
+	checkLineBytes(t, b, [][]byte{[]byte("abcd"), []byte("")})
+}
+
+func TestSortLines(t *testing.T) {
+	b, err := NewBuffer(strings.NewReader("pear\napple\npear\nfig\napple\n"))
+	if err != nil {
+		t.Error("Error creating buffer")
+	}
+	if n := b.UniqLines(1, 5); n != 2 {
+		t.Errorf("wrong number of removed lines: got %d, want 2", n)
+	}
+	checkLineBytes(t, b, [][]byte{
+		[]byte("pear"),
+		[]byte("apple"),
+		[]byte("fig"),
+		[]byte(""),
+	})
+
+	b.FinalizeActionGroup()
+	b.Undo()
+	b.FinalizeActionGroup()
+	if n := b.SortLines(1, 5, false, true); n != 2 {
+		t.Errorf("wrong number of removed lines: got %d, want 2", n)
+	}
+	checkLineBytes(t, b, [][]byte{
+		[]byte("apple"),
+		[]byte("fig"),
+		[]byte("pear"),
+		[]byte(""),
+	})
+
+	b.FinalizeActionGroup()
+	if n := b.SortLines(1, 3, true, false); n != 0 {
+		t.Errorf("wrong number of removed lines: got %d, want 0", n)
+	}
+	checkLineBytes(t, b, [][]byte{
+		[]byte("pear"),
+		[]byte("fig"),
+		[]byte("apple"),
+		[]byte(""),
+	})
+
+	b.Undo()
+	checkLineBytes(t, b, [][]byte{
+		[]byte("apple"),
+		[]byte("fig"),
+		[]byte("pear"),
+		[]byte(""),
+	})
+}
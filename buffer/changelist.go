@@ -0,0 +1,56 @@
+package buffer
+
+// maxChanges is the number of positions kept in the change list.
+const maxChanges = 100
+
+// recordChange adds the position of the change made by the action a to the
+// change list, and makes the list start again from its end. A change on the
+// line of the last one replaces it.
+func (b *Buffer) recordChange(a *Action) {
+	c := a.Cursor
+	if n := len(b.changes); n > 0 && b.changes[n-1].LineNum == c.LineNum {
+		b.changes[n-1] = c
+	} else {
+		b.changes = append(b.changes, c)
+		if len(b.changes) > maxChanges {
+			b.changes = b.changes[1:]
+		}
+	}
+	b.changeIndex = len(b.changes)
+}
+
+// adjustChanges moves the positions of the change list after the action a was
+// applied as what.
+func (b *Buffer) adjustChanges(a *Action, what ActionType) {
+	for i := range b.changes {
+		switch what {
+		case ActionInsert:
+			b.changes[i].OnInsertAdjust(a)
+		case ActionDelete:
+			b.changes[i].OnDeleteAdjust(a)
+		}
+	}
+}
+
+// Change returns the position count entries before the current one in the
+// change list, or after it if count is negative, and makes it the current
+// one. It stops at the ends of the list, and reports false if it is already
+// at the end it goes to.
+func (b *Buffer) Change(count int) (Cursor, bool) {
+	if count < 0 && b.changeIndex >= len(b.changes)-1 {
+		// already past the newest change, or on it
+		return Cursor{}, false
+	}
+	i := b.changeIndex - count
+	if i < 0 {
+		i = 0
+	}
+	if i >= len(b.changes) {
+		i = len(b.changes) - 1
+	}
+	if i < 0 || i == b.changeIndex {
+		return Cursor{}, false
+	}
+	b.changeIndex = i
+	return b.changes[i], true
+}
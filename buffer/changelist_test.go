@@ -0,0 +1,44 @@
+package buffer
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestChangeList(t *testing.T) {
+	b, err := NewBuffer(strings.NewReader("one\ntwo\nthree\nfour\n"))
+	if err != nil {
+		t.Fatal("Error creating buffer")
+	}
+	if _, ok := b.Change(1); ok {
+		t.Fatal("got a change in a new buffer")
+	}
+
+	b.Insert(b.LineCursor(2), []byte("x"))
+	b.Insert(b.LineCursor(2), []byte("y"))
+	b.Insert(b.LineCursor(4), []byte("z"))
+	// moves the changes down
+	b.Insert(b.LineCursor(1), []byte("zero\n"))
+
+	for _, test := range []struct {
+		count int
+		line  int // 0 if there is no change to go to
+	}{
+		{-1, 0}, // nothing newer than the last change
+		{1, 1},
+		{1, 5},
+		{5, 3}, // stops at the oldest
+		{1, 0},
+		{-1, 5},
+		{-5, 1},
+		{-1, 0},
+	} {
+		c, ok := b.Change(test.count)
+		switch {
+		case ok != (test.line != 0):
+			t.Errorf("Change(%d): got %v, want %v", test.count, ok, test.line != 0)
+		case ok && c.LineNum != test.line:
+			t.Errorf("Change(%d): got line %d, want %d", test.count, c.LineNum, test.line)
+		}
+	}
+}
@@ -2,6 +2,8 @@ package buffer
 
 import (
 	"bytes"
+	"regexp"
+	"strings"
 	"unicode"
 	"unicode/utf8"
 
@@ -130,14 +132,15 @@ func (a Cursor) Distance(b Cursor) int {
 	return n * s
 }
 
-// VoffsetCoffset returns a visual and a character offset for a given cursor.
-func (c *Cursor) VoffsetCoffset() (vo, co int) {
+// VoffsetCoffset returns a visual and a character offset for a given cursor,
+// with tabstop cells between tab stops.
+func (c *Cursor) VoffsetCoffset(tabstop int) (vo, co int) {
 	data := c.Line.Data[:c.Boffset]
 	for len(data) > 0 {
 		r, rlen := utf8.DecodeRune(data)
 		data = data[rlen:]
 		co += 1
-		vo += utils.RuneAdvanceLen(r, vo)
+		vo += utils.RuneAdvanceLen(r, vo, tabstop)
 	}
 	return
 }
@@ -241,6 +244,62 @@ func (c *Cursor) PrevLine() bool {
 	return true
 }
 
+// SearchForward returns the start of the first match of re after the cursor,
+// and reports whether there is one. Each line is matched on its own, so ^ and
+// $ match at its beginning and end, and matches don't span lines. A match may
+// overlap the one at the cursor.
+func (c Cursor) SearchForward(re *regexp.Regexp) (Cursor, bool) {
+	for c.Line != nil {
+		data := c.Line.Data
+		from := 0
+		if c.Boffset >= 0 {
+			// from the cursor, which is the context of ^ and \b
+			from = c.Boffset
+		}
+		m := re.FindIndex(data[from:])
+		if m != nil && m[0] == 0 && c.Boffset >= 0 {
+			// the match at the cursor hides the ones overlapping it
+			_, rlen := utf8.DecodeRune(data[from:])
+			from += rlen
+			m = nil
+			if rlen > 0 {
+				m = re.FindIndex(data[from:])
+			}
+		}
+		if m != nil {
+			c.Boffset = from + m[0]
+			return c, true
+		}
+		c.Line = c.Line.Next
+		c.LineNum++
+		// a match at the beginning of the next line is after the cursor
+		c.Boffset = -1
+	}
+	return c, false
+}
+
+// SearchBackward returns the start of the last match of re before the cursor,
+// and reports whether there is one. Each line is matched on its own, as by
+// SearchForward, but matches don't overlap each other.
+func (c Cursor) SearchBackward(re *regexp.Regexp) (Cursor, bool) {
+	for c.Line != nil {
+		ms := re.FindAllIndex(c.Line.Data, -1)
+		for i := len(ms) - 1; i >= 0; i-- {
+			if ms[i][0] < c.Boffset {
+				c.Boffset = ms[i][0]
+				return c, true
+			}
+		}
+		c.Line = c.Line.Prev
+		c.LineNum--
+		if c.Line != nil {
+			// a match at the end of the previous line is before the cursor
+			c.Boffset = len(c.Line.Data) + 1
+		}
+	}
+	return c, false
+}
+
 // MoveBOL moves the cursor to the beginning of the current line.
 func (c *Cursor) MoveBOL() {
 	c.Boffset = 0
@@ -251,51 +310,89 @@ func (c *Cursor) MoveEOL() {
 	c.Boffset = len(c.Line.Data)
 }
 
+// MoveToColumn moves the cursor to the character of the current line shown at
+// the visual offset vcol, to the start of a tab or a wide character covering
+// it, or to the end of the line if it is shorter. There are tabstop cells
+// between tab stops.
+func (c *Cursor) MoveToColumn(vcol, tabstop int) {
+	c.Boffset, _, _ = c.Line.FindClosestOffsets(vcol, tabstop)
+}
+
+// WordUnderCursor returns the word under the cursor, or the one before it at
+// the end of the line. It returns a single character for characters which
+// aren't part of words, and nil for whitespace.
 func (c *Cursor) WordUnderCursor() []byte {
-	end, beg := *c, *c
-	var (
-		r rune
-		rlen int
-	)
-
-	r, _ = beg.RuneUnder()
-	if unicode.IsSpace(r) {
+	return c.WordUnderCursorFunc(LowercaseWordClass)
+}
+
+// WordUnderCursorFunc is like WordUnderCursor, with words split by wordClass,
+// see LowercaseWordClass.
+func (c *Cursor) WordUnderCursorFunc(wordClass func(rune) int) []byte {
+	beg := *c
+	if beg.EOL() {
+		if !beg.PrevRune(false) {
+			return nil
+		}
+	}
+	r, rlen := beg.RuneUnder()
+	class := wordClass(r)
+	switch {
+	case r == utf8.RuneError || class == classSpace:
 		return nil
+	case class == classPunct:
+		return c.Line.Data[beg.Boffset : beg.Boffset+rlen]
 	}
 
-	if !beg.BOL() {
-		r, rlen = beg.RuneBefore()
-		if r == utf8.RuneError {
-			return nil
+	// move the `beg` cursor back to the start of the word
+	for !beg.BOL() {
+		r, rlen := beg.RuneBefore()
+		if wordClass(r) != class {
+			break
 		}
+		beg.Boffset -= rlen
+	}
 
-		// move the `beg` cursor back to the start of the word
-		for utils.IsWord(r) && !beg.BOL() {
-			beg.Boffset -= rlen
-			r, rlen = beg.RuneBefore()
+	// and the `end` cursor to the rune after the end of the word
+	end := beg
+	for !end.EOL() {
+		r, rlen := end.RuneUnder()
+		if wordClass(r) != class {
+			break
 		}
+		end.Boffset += rlen
 	}
+	return c.Line.Data[beg.Boffset:end.Boffset]
+}
 
-	// set the end cursor to the same position as the start cursor
-	end.Boffset = beg.Boffset
+// isFilenameRune reports whether r can be part of the file names found by
+// FilenameUnderCursor.
+func isFilenameRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || strings.ContainsRune("/.-_+,#$%~=", r)
+}
 
-	// check if the word is just a single character
-	r, rlen = end.RuneAfter()
-	if !utils.IsWord(r) {
-		return c.Line.Data[end.Boffset:end.Boffset+1]
+// FilenameUnderCursor returns the file name under the cursor, made of letters,
+// digits and the characters /.-_+,#$%~=, or nil if there is none.
+func (c *Cursor) FilenameUnderCursor() []byte {
+	beg, end := c.Boffset, c.Boffset
+	data := c.Line.Data
+	for beg > 0 {
+		r, rlen := utf8.DecodeLastRune(data[:beg])
+		if !isFilenameRune(r) {
+			break
+		}
+		beg -= rlen
 	}
-
-	// move to the the rune after the end of the word
-	for utils.IsWord(r) && !end.EOL() {
-		end.Boffset += rlen
-		r, rlen = end.RuneAfter()
+	for end < len(data) {
+		r, rlen := utf8.DecodeRune(data[end:])
+		if !isFilenameRune(r) {
+			break
+		}
+		end += rlen
 	}
-	end.NextRune(false)
-
-	if beg.Boffset == end.Boffset {
+	if beg == end {
 		return nil
 	}
-	return c.Line.Data[beg.Boffset:end.Boffset]
+	return data[beg:end]
 }
 
 // Move cursor forward until current rune satisfies condition f.
@@ -326,67 +423,109 @@ func (c *Cursor) NextRuneFunc(f func(rune) bool) bool {
 }
 
 // Move cursor forward to beginning of next word.
-// Skips the rest of the current word, if any. Returns true if
-// the move was successful, false if EOF reached.
+// Skips the rest of the current word, if any. Empty lines count as words.
+// Returns true if the move was successful, false if EOF reached.
 func (c *Cursor) NextWord() bool {
-	isNotSpace := func(r rune) bool {
-		return !unicode.IsSpace(r)
+	return c.NextWordFunc(LowercaseWordClass)
+}
+
+// NextWordFunc is like NextWord, with words split by wordClass, see
+// LowercaseWordClass.
+func (c *Cursor) NextWordFunc(wordClass func(rune) int) bool {
+	// Lowercase word motion differentiates words consisting of
+	// (A-Z0-9_) and any other non-whitespace character. Skip until
+	// we find either the other word type or whitespace.
+	if r, _ := c.RuneUnder(); !c.EOL() && wordClass(r) != classSpace {
+		class := wordClass(r)
+		for !c.EOL() && wordClass(r) == class {
+			_, rlen := c.RuneUnder()
+			c.Boffset += rlen
+			r, _ = c.RuneUnder()
+		}
 	}
-	r, _ := c.RuneUnder()
-	if isNotSpace(r) {
-		// Lowercase word motion differentiates words consisting of
-		// (A-Z0-9_) and any other non-whitespace character. Skip until
-		// we find either the other word type or whitespace.
-		if utils.IsWord(r) {
-			c.NextRuneFunc(func(r rune) bool {
-				return !utils.IsWord(r) || unicode.IsSpace(r)
-			})
-		} else {
-			c.NextRuneFunc(func(r rune) bool {
-				return utils.IsWord(r) || unicode.IsSpace(r)
-			})
+
+	// Skip remaining whitespace until next word of any type, or an
+	// empty line.
+	for {
+		if c.EOL() {
+			if c.LastLine() {
+				return false
+			}
+			c.Line = c.Line.Next
+			c.LineNum++
+			c.Boffset = 0
+			if len(c.Line.Data) == 0 {
+				return true
+			}
+			continue
+		}
+		r, rlen := c.RuneUnder()
+		if !unicode.IsSpace(r) {
+			return true
 		}
+		c.Boffset += rlen
 	}
-	// Skip remaining whitespace until next word of any type.
-	return c.NextRuneFunc(isNotSpace)
+}
+
+// AtWordEnd reports whether the cursor is on the last rune of a word.
+func (c *Cursor) AtWordEnd() bool {
+	return c.AtWordEndFunc(LowercaseWordClass)
+}
+
+// AtWordEndFunc is like AtWordEnd, with words split by wordClass, see
+// LowercaseWordClass.
+func (c *Cursor) AtWordEndFunc(wordClass func(rune) int) bool {
+	r, _ := c.RuneUnder()
+	if c.EOL() || wordClass(r) == classSpace {
+		return false
+	}
+	next := *c
+	next.NextRune(false)
+	nr, _ := next.RuneUnder()
+	return next.EOL() || wordClass(nr) != wordClass(r)
 }
 
 // EndWord moves cursor to the end of current word or seeks to the
-// beginning of next word, if character under cursor is a whitespace.
+// end of next word, if character under cursor is a whitespace or the
+// last one of a word. Empty lines are skipped. Returns false, leaving the
+// cursor where it is, if there is no word end after the cursor.
 func (c *Cursor) EndWord() bool {
-	if !c.NextRune(true) {
+	return c.EndWordFunc(LowercaseWordClass)
+}
+
+// EndWordFunc is like EndWord, with words split by wordClass, see
+// LowercaseWordClass.
+func (c *Cursor) EndWordFunc(wordClass func(rune) int) bool {
+	d := *c
+	if !d.NextRune(true) {
 		return false
 	}
 
-	// Skip spaces until beginning of next word
-	r, _ := c.RuneUnder()
-	if c.EOL() || unicode.IsSpace(r) {
-		c.NextWord()
+	// Skip spaces until beginning of next word, empty lines included.
+	if r, _ := d.RuneUnder(); d.EOL() || unicode.IsSpace(r) {
+		if !d.NextRuneFunc(func(r rune) bool {
+			return !unicode.IsSpace(r)
+		}) {
+			// only whitespace up to the end of buffer
+			return false
+		}
 	}
 
-	// Skip to after the word.
-	r, _ = c.RuneUnder()
-	var f func(r rune) bool
-	if utils.IsWord(r) {
-		f = func(r rune) bool {
-			return !utils.IsWord(r) || unicode.IsSpace(r)
+	// Move to the last rune of the word, which ends at the end of line
+	// at the latest.
+	r, _ := d.RuneUnder()
+	class := wordClass(r)
+	for {
+		next := d
+		if !next.NextRune(false) || next.EOL() {
+			break
 		}
-	} else {
-		f = func(r rune) bool {
-			return utils.IsWord(r) || unicode.IsSpace(r)
+		if r, _ := next.RuneUnder(); wordClass(r) != class {
+			break
 		}
+		d = next
 	}
-
-	// This can go back to end of buffer but can be ignored,
-	// since we're going to backtrack one character.
-	c.NextRuneFunc(f)
-	c.PrevRune(true)
-	// Keep going back until BOF if we end up at EOL. This
-	// can happen on empty lines.
-	for c.EOL() && !(c.BOL() && c.FirstLine()) {
-		c.PrevRune(true)
-	}
-
+	*c = d
 	return true
 }
 
@@ -414,39 +553,98 @@ func (c *Cursor) PrevRuneFunc(f func(rune) bool) bool {
 	return true
 }
 
-// Move cursor forward to beginning of the previous word.
+// Move cursor backward to beginning of the previous word.
 // Skips the rest of the current word, if any, unless is located at its
-// first character. Returns true if the move was successful, false if EOF reached.
+// first character. Empty lines count as words. Returns false, leaving the
+// cursor where it is, if there is no word before the cursor.
 func (c *Cursor) PrevWord() bool {
-	isNotSpace := func(r rune) bool {
-		return !unicode.IsSpace(r)
-	}
+	return c.PrevWordFunc(LowercaseWordClass)
+}
+
+// PrevWordFunc is like PrevWord, with words split by wordClass, see
+// LowercaseWordClass.
+func (c *Cursor) PrevWordFunc(wordClass func(rune) int) bool {
+	d := *c
+	// Skip space until we find a word character or an empty line.
 	for {
-		// Skip space until we find a word character.
-		// Re-try if we reached beginning-of-line.
-		if !c.PrevRuneFunc(isNotSpace) {
-			return false
+		if d.BOL() {
+			if d.FirstLine() {
+				return false
+			}
+			d.Line = d.Line.Prev
+			d.LineNum--
+			d.Boffset = len(d.Line.Data)
+			if len(d.Line.Data) == 0 {
+				*c = d
+				return true
+			}
+			continue
 		}
-		if !c.BOL() {
+		r, rlen := d.RuneBefore()
+		if !unicode.IsSpace(r) {
 			break
 		}
+		d.Boffset -= rlen
 	}
-	r, _ := c.RuneBefore()
-	if isNotSpace(r) {
-		// Lowercase word motion differentiates words consisting of
-		// (A-Z0-9_) and any other non-whitespace character. Skip until
-		// we find either the other word type or whitespace.
-		if utils.IsWord(r) {
-			c.PrevRuneFunc(func(r rune) bool {
-				return !utils.IsWord(r) || unicode.IsSpace(r)
-			})
-		} else {
-			c.PrevRuneFunc(func(r rune) bool {
-				return utils.IsWord(r) || unicode.IsSpace(r)
-			})
-		}
+
+	// Lowercase word motion differentiates words consisting of
+	// (A-Z0-9_) and any other non-whitespace character. Skip until
+	// we find either the other word type or whitespace.
+	r, _ := d.RuneBefore()
+	class := wordClass(r)
+	for !d.BOL() && wordClass(r) == class {
+		_, rlen := d.RuneBefore()
+		d.Boffset -= rlen
+		r, _ = d.RuneBefore()
 	}
-	return !c.BOL()
+	*c = d
+	return true
+}
+
+// Classes of runes, runs of runes of the same class other than whitespace
+// forming words.
+const (
+	classSpace = iota
+	classWord
+	classPunct
+	classHan
+	classHiragana
+	classKatakana
+	classHangul
+)
+
+// LowercaseWordClass returns the class of the rune r for lowercase word
+// motions: whitespace, word characters (A-Z0-9_) or any other character.
+// Runs of runes of the same class other than whitespace, which is class 0,
+// form words. It is the default of the word motions and WordUnderCursor, the
+// Func variants of which take another such function.
+func LowercaseWordClass(r rune) int {
+	switch {
+	case unicode.IsSpace(r):
+		return classSpace
+	case utils.IsWord(r):
+		return classWord
+	}
+	return classPunct
+}
+
+// UnicodeWordClass is like LowercaseWordClass, with a class for each script
+// written without spaces, and combining marks as word characters, so that
+// text such as Japanese is split between kanji and kana.
+func UnicodeWordClass(r rune) int {
+	switch {
+	case unicode.Is(unicode.Han, r):
+		return classHan
+	case unicode.Is(unicode.Hiragana, r):
+		return classHiragana
+	case unicode.Is(unicode.Katakana, r):
+		return classKatakana
+	case unicode.Is(unicode.Hangul, r):
+		return classHangul
+	case unicode.IsMark(r):
+		return classWord
+	}
+	return LowercaseWordClass(r)
 }
 
 func (c *Cursor) OnInsertAdjust(a *Action) {
@@ -1,6 +1,8 @@
 package buffer
 
 import (
+	"regexp"
+	"strings"
 	"testing"
 	"unicode/utf8"
 )
@@ -44,6 +46,56 @@ func TestExtractBytes(t *testing.T) {
 	}
 }
 
+func TestVoffsetCoffsetWide(t *testing.T) {
+	lines := makeLines("a日e\u0301b")
+	tests := []struct {
+		boffset, vo, co int
+	}{
+		{1, 1, 1},
+		{4, 3, 2},
+		{5, 4, 3},
+		{7, 4, 4}, // after the combining mark
+		{8, 5, 5},
+	}
+	for _, test := range tests {
+		c := Cursor{Line: lines[0], Boffset: test.boffset}
+		if vo, co := c.VoffsetCoffset(8); vo != test.vo || co != test.co {
+			t.Errorf("at %d: got %d, %d, want %d, %d", test.boffset, vo, co, test.vo, test.co)
+		}
+	}
+
+	// a visual offset within a wide rune is at its start
+	for _, vo := range []int{1, 2} {
+		if bo, _, v := lines[0].FindClosestOffsets(vo, 8); bo != 1 || v != 1 {
+			t.Errorf("closest offsets of %d: got %d, %d", vo, bo, v)
+		}
+	}
+	if bo, _, _ := lines[0].FindClosestOffsets(4, 8); bo != 7 {
+		t.Errorf("closest offsets after a combining mark: got %d", bo)
+	}
+}
+
+func TestMoveToColumn(t *testing.T) {
+	lines := makeLines("\tä世x")
+	for _, test := range []struct {
+		vcol, boffset int
+	}{
+		{0, 0},
+		{3, 0}, // within the tab
+		{8, 1},
+		{9, 3},
+		{10, 3}, // second cell of the wide rune
+		{11, 6},
+		{50, 7}, // past the end of the line
+	} {
+		c := Cursor{Line: lines[0], LineNum: 1}
+		c.MoveToColumn(test.vcol, 8)
+		if c.Boffset != test.boffset {
+			t.Errorf("column %d: got byte offset %d, want %d", test.vcol, c.Boffset, test.boffset)
+		}
+	}
+}
+
 func TestNextRune(t *testing.T) {
 	lines := makeLines(
 		"// comment",
@@ -150,7 +202,7 @@ func TestPrevRuneWrap(t *testing.T) {
 }
 
 func TestNextWord(t *testing.T) {
-	// TODO test EOF, test empty line
+	// TODO test EOF
 	lines := makeLines(
 		"// comment",
 		"func bar(i int) {",
@@ -184,8 +236,31 @@ func TestNextWord(t *testing.T) {
 	}
 }
 
+func TestNextWordEmptyLines(t *testing.T) {
+	lines := makeLines(
+		"foo",
+		"",
+		"",
+		"  bar",
+	)
+	stops := []Cursor{
+		{lines[1], 2, 0},
+		{lines[2], 3, 0},
+		{lines[3], 4, 2},
+	}
+
+	c := &Cursor{Line: lines[0], LineNum: 1, Boffset: 0}
+	for i, s := range stops {
+		if !c.NextWord() {
+			t.Error("NextWord failed at index", i)
+		}
+		if c.Line != s.Line || c.LineNum != s.LineNum || c.Boffset != s.Boffset {
+			t.Errorf("Bad cursor at index %d: %d:%d, want %d:%d", i, c.LineNum, c.Boffset, s.LineNum, s.Boffset)
+		}
+	}
+}
+
 func TestEndWord(t *testing.T) {
-	// TODO test EOF
 	lines := makeLines(
 		"// comment",
 		"func bar(i int) {",
@@ -219,10 +294,45 @@ func TestEndWord(t *testing.T) {
 			t.Error("Bad cursor position at index", i, c.Boffset, "!=", s.Boffset)
 		}
 	}
+
+	// At the end of the last word
+	if c.EndWord() {
+		t.Error("EndWord succeeded at the end of buffer")
+	}
+	if c.Line != lines[4] || c.Boffset != 0 {
+		t.Error("Bad cursor position at the end of buffer", c.Boffset)
+	}
+}
+
+func TestEndWordEOF(t *testing.T) {
+	lines := makeLines(
+		"foo barbaz  ",
+		"",
+	)
+	c := &Cursor{Line: lines[0], Boffset: 5}
+	if !c.EndWord() || c.Line != lines[0] || c.Boffset != 9 {
+		t.Error("Bad cursor position in the last word", c.Boffset)
+	}
+	// only whitespace and an empty line are left
+	if c.EndWord() {
+		t.Error("EndWord succeeded after the last word")
+	}
+	if c.Line != lines[0] || c.Boffset != 9 {
+		t.Error("Cursor moved after the last word", c.Boffset)
+	}
+}
+
+func TestAtWordEnd(t *testing.T) {
+	lines := makeLines("foo(a)  bar")
+	for i, want := range []bool{false, false, true, true, true, true, false, false, false, false, true, false} {
+		c := Cursor{Line: lines[0], Boffset: i}
+		if got := c.AtWordEnd(); got != want {
+			t.Errorf("offset %d: got %v, want %v", i, got, want)
+		}
+	}
 }
 
 func TestPrevWord(t *testing.T) {
-	// TODO test BOF, test empty line
 	lines := makeLines(
 		"// comment",
 		"func bar(i int) {",
@@ -276,6 +386,55 @@ func TestPrevWordSpaces(t *testing.T) {
 	}
 }
 
+func TestPrevWordBOF(t *testing.T) {
+	lines := makeLines(
+		"  foo bar",
+	)
+	c := &Cursor{Line: lines[0], Boffset: 6}
+	if !c.PrevWord() || c.Boffset != 2 {
+		t.Error("Bad cursor position on the first word", c.Boffset)
+	}
+	// only whitespace is left before the cursor
+	if c.PrevWord() {
+		t.Error("PrevWord succeeded before the first word")
+	}
+	if c.Boffset != 2 {
+		t.Error("Cursor moved before the first word", c.Boffset)
+	}
+
+	c = &Cursor{Line: lines[0], Boffset: 0}
+	if c.PrevWord() {
+		t.Error("PrevWord succeeded at the beginning of buffer")
+	}
+	if c.Boffset != 0 {
+		t.Error("Cursor moved at the beginning of buffer", c.Boffset)
+	}
+}
+
+func TestPrevWordEmptyLines(t *testing.T) {
+	lines := makeLines(
+		"foo",
+		"",
+		"",
+		"  bar",
+	)
+	stops := []Cursor{
+		{lines[2], 3, 0},
+		{lines[1], 2, 0},
+		{lines[0], 1, 0},
+	}
+
+	c := &Cursor{Line: lines[3], LineNum: 4, Boffset: 2}
+	for i, s := range stops {
+		if !c.PrevWord() {
+			t.Error("PrevWord failed at index", i)
+		}
+		if c.Line != s.Line || c.LineNum != s.LineNum || c.Boffset != s.Boffset {
+			t.Errorf("Bad cursor at index %d: %d:%d, want %d:%d", i, c.LineNum, c.Boffset, s.LineNum, s.Boffset)
+		}
+	}
+}
+
 func TestSortCursors(t *testing.T) {
 
 	c1 := Cursor{nil, 1, 10}
@@ -394,3 +553,96 @@ func TestWordUnderCursor(t *testing.T) {
 		t.Error("Expected to return nil")
 	}
 }
+
+func TestUnicodeWords(t *testing.T) {
+	lines := makeLines("日本語のテキスト e\u0301te")
+	c := &Cursor{Line: lines[0], Boffset: 0}
+	if word := string(c.WordUnderCursor()); word != "日本語のテキスト" {
+		t.Error("Incorrect default word:", word)
+	}
+
+	if word := string(c.WordUnderCursorFunc(UnicodeWordClass)); word != "日本語" {
+		t.Error("Incorrect word:", word)
+	}
+	stops := []int{len("日本語"), len("日本語の"), len("日本語のテキスト ")}
+	for i, s := range stops {
+		c.NextWordFunc(UnicodeWordClass)
+		if c.Boffset != s {
+			t.Error("Bad cursor position at index", i, c.Boffset, "!=", s)
+		}
+	}
+	if word := string(c.WordUnderCursorFunc(UnicodeWordClass)); word != "e\u0301te" {
+		t.Error("Incorrect word with a combining mark:", word)
+	}
+}
+
+func TestFilenameUnderCursor(t *testing.T) {
+	for _, test := range []struct {
+		line    string
+		boffset int
+		want    string
+	}{
+		{`import "github.com/kisielk/vigo/buffer"`, 12, "github.com/kisielk/vigo/buffer"},
+		{"see ~/.vigo/colors/dark, then", 6, "~/.vigo/colors/dark,"},
+		{"(main.go)", 0, ""},
+		{"(main.go)", 1, "main.go"},
+		{"a.txt", 5, "a.txt"},
+		{"", 0, ""},
+	} {
+		c := Cursor{Line: &Line{Data: []byte(test.line)}, LineNum: 1, Boffset: test.boffset}
+		if got := string(c.FilenameUnderCursor()); got != test.want {
+			t.Errorf("%q at %d: got %q, want %q", test.line, test.boffset, got, test.want)
+		}
+	}
+}
+
+func TestSearch(t *testing.T) {
+	b, err := NewBuffer(strings.NewReader("foo bar\nbar foo\n\nfoo\n"))
+	if err != nil {
+		t.Fatal("Error creating buffer")
+	}
+	for _, test := range []struct {
+		re       string
+		from     [2]int // line and offset
+		backward bool
+		want     [2]int // or 0, 0 if there is no match
+	}{
+		{"foo", [2]int{1, 0}, false, [2]int{2, 4}},
+		{"bar", [2]int{1, 0}, false, [2]int{1, 4}},
+		{"bar", [2]int{1, 4}, false, [2]int{2, 0}},
+		{"^foo", [2]int{1, 0}, false, [2]int{4, 0}},
+		{"foo$", [2]int{1, 0}, false, [2]int{2, 4}},
+		{"^$", [2]int{1, 0}, false, [2]int{3, 0}},
+		{"o+", [2]int{2, 4}, false, [2]int{2, 5}},
+		{"o+", [2]int{2, 5}, false, [2]int{2, 6}},
+		{"o+", [2]int{2, 6}, false, [2]int{4, 1}},
+		{"o", [2]int{4, 1}, false, [2]int{4, 2}},
+		{"^o", [2]int{4, 0}, false, [2]int{0, 0}},
+		{`\bo`, [2]int{2, 3}, false, [2]int{0, 0}},
+		{"baz", [2]int{1, 0}, false, [2]int{0, 0}},
+		{"foo", [2]int{4, 0}, true, [2]int{2, 4}},
+		{"foo$", [2]int{2, 4}, true, [2]int{0, 0}},
+		{"bar$", [2]int{2, 0}, true, [2]int{1, 4}},
+		{"^", [2]int{2, 3}, true, [2]int{2, 0}},
+		{"^", [2]int{2, 0}, true, [2]int{1, 0}},
+	} {
+		c := b.LineCursor(test.from[0])
+		c.Boffset = test.from[1]
+		re := regexp.MustCompile(test.re)
+		search := c.SearchForward
+		if test.backward {
+			search = c.SearchBackward
+		}
+		m, ok := search(re)
+		got := [2]int{m.LineNum, m.Boffset}
+		if !ok {
+			got = [2]int{0, 0}
+		}
+		if got != test.want {
+			t.Errorf("%s from %v, backward %v: got %v, want %v", test.re, test.from, test.backward, got, test.want)
+		}
+		if ok && m.Line != b.LineCursor(m.LineNum).Line {
+			t.Errorf("%s from %v: line doesn't match its number", test.re, test.from)
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package buffer
+
+// SetMark sets the mark name at the position of the cursor c. Marks follow
+// the text they point to as the buffer is modified.
+func (b *Buffer) SetMark(name rune, c Cursor) {
+	if b.marks == nil {
+		b.marks = make(map[rune]Cursor)
+	}
+	b.marks[name] = c
+}
+
+// Mark returns the position of the mark name, and false if it isn't set.
+func (b *Buffer) Mark(name rune) (Cursor, bool) {
+	c, ok := b.marks[name]
+	return c, ok
+}
+
+// TrackCursor makes the cursor c follow the text it points to as the buffer
+// is modified, as the marks do, until it is passed to UntrackCursor.
+func (b *Buffer) TrackCursor(c *Cursor) {
+	b.tracked = append(b.tracked, c)
+}
+
+// UntrackCursor stops moving the cursor c with the changes of the buffer.
+func (b *Buffer) UntrackCursor(c *Cursor) {
+	for i, t := range b.tracked {
+		if t == c {
+			b.tracked = append(b.tracked[:i], b.tracked[i+1:]...)
+			return
+		}
+	}
+}
+
+// adjustMarks moves the marks and the tracked cursors after the action a was
+// applied as what, which differs from the type of a when it is reverted.
+func (b *Buffer) adjustMarks(a *Action, what ActionType) {
+	adjust := func(c *Cursor) {
+		switch what {
+		case ActionInsert:
+			c.OnInsertAdjust(a)
+		case ActionDelete:
+			c.OnDeleteAdjust(a)
+		}
+	}
+	for name, c := range b.marks {
+		adjust(&c)
+		b.marks[name] = c
+	}
+	for _, c := range b.tracked {
+		adjust(c)
+	}
+}
+
+// markChange sets the marks [ and ] on the first and the last character of
+// the text changed by the action a applied as what. The range of the marks
+// grows with the changes of the same action group, such as the keys typed in
+// insert mode.
+func (b *Buffer) markChange(a *Action, what ActionType) {
+	start, end := a.Cursor, a.Cursor
+	if what == ActionInsert && len(a.Data) > 0 {
+		if len(a.Lines) == 0 {
+			end.Boffset += len(a.Data)
+		} else {
+			end.Line = a.LastLine()
+			end.LineNum += len(a.Lines)
+			end.Boffset = a.lastLineAffectionLen()
+		}
+		end.PrevRune(true)
+	}
+
+	if b.changeGroup != nil && b.changeGroup == b.History {
+		// the marks have been adjusted to this change already
+		if first, ok := b.marks['[']; ok && first.Before(start) {
+			start = first
+		}
+		if last, ok := b.marks[']']; ok && last.After(end) {
+			end = last
+		}
+	}
+	b.SetMark('[', start)
+	b.SetMark(']', end)
+	b.changeGroup = b.History
+}
@@ -0,0 +1,75 @@
+package buffer
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMarkAdjust(t *testing.T) {
+	b, err := NewBuffer(strings.NewReader("foo\nbar\nbaz\n"))
+	if err != nil {
+		t.Fatal("Error creating buffer")
+	}
+	b.SetMark('a', Cursor{Line: b.FirstLine.Next, LineNum: 2, Boffset: 1})
+	if _, ok := b.Mark('b'); ok {
+		t.Error("unset mark reported as set")
+	}
+
+	b.Insert(Cursor{Line: b.FirstLine, LineNum: 1, Boffset: 0}, []byte("new\n"))
+	if c, _ := b.Mark('a'); c.LineNum != 3 || c.Boffset != 1 || string(c.Line.Data) != "bar" {
+		t.Errorf("after insert above: got line %d %q at %d", c.LineNum, c.Line.Data, c.Boffset)
+	}
+
+	b.Delete(Cursor{Line: b.FirstLine.Next.Next, LineNum: 3, Boffset: 0}, 1)
+	if c, _ := b.Mark('a'); c.LineNum != 3 || c.Boffset != 0 || string(c.Line.Data) != "ar" {
+		t.Errorf("after delete before: got line %d %q at %d", c.LineNum, c.Line.Data, c.Boffset)
+	}
+
+	b.Undo()
+	if c, _ := b.Mark('a'); c.LineNum != 2 || c.Boffset != 1 || string(c.Line.Data) != "bar" {
+		t.Errorf("after undo: got line %d %q at %d", c.LineNum, c.Line.Data, c.Boffset)
+	}
+}
+
+func TestTrackCursor(t *testing.T) {
+	b, err := NewBuffer(strings.NewReader("foo\nbar\n"))
+	if err != nil {
+		t.Fatal("Error creating buffer")
+	}
+	c := &Cursor{Line: b.FirstLine.Next, LineNum: 2, Boffset: 2}
+	b.TrackCursor(c)
+	b.Insert(Cursor{Line: b.FirstLine, LineNum: 1, Boffset: 0}, []byte("new\n"))
+	if c.LineNum != 3 || c.Boffset != 2 || string(c.Line.Data) != "bar" {
+		t.Errorf("after insert above: got line %d %q at %d", c.LineNum, c.Line.Data, c.Boffset)
+	}
+
+	b.UntrackCursor(c)
+	b.Insert(Cursor{Line: b.FirstLine, LineNum: 1, Boffset: 0}, []byte("new\n"))
+	if c.LineNum != 3 {
+		t.Errorf("untracked cursor moved to line %d", c.LineNum)
+	}
+}
+
+func TestChangeMarks(t *testing.T) {
+	b, err := NewBuffer(strings.NewReader("foo\nbar\n"))
+	if err != nil {
+		t.Fatal("Error creating buffer")
+	}
+	c := Cursor{Line: b.FirstLine, LineNum: 1, Boffset: 1}
+	b.InsertRune(c, 'x')
+	c.Boffset++
+	b.InsertRune(c, 'y')
+	start, _ := b.Mark('[')
+	end, _ := b.Mark(']')
+	if start.LineNum != 1 || start.Boffset != 1 || end.LineNum != 1 || end.Boffset != 2 {
+		t.Errorf("after inserts: got %d:%d to %d:%d", start.LineNum, start.Boffset, end.LineNum, end.Boffset)
+	}
+
+	b.FinalizeActionGroup()
+	b.Insert(Cursor{Line: b.FirstLine.Next, LineNum: 2, Boffset: 0}, []byte("a\nb"))
+	start, _ = b.Mark('[')
+	end, _ = b.Mark(']')
+	if start.LineNum != 2 || start.Boffset != 0 || end.LineNum != 3 || end.Boffset != 0 || string(end.Line.Data) != "bbar" {
+		t.Errorf("after new insert: got %d:%d to %d:%d", start.LineNum, start.Boffset, end.LineNum, end.Boffset)
+	}
+}
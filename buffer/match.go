@@ -0,0 +1,72 @@
+package buffer
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// ParseMatchPairs parses pairs of characters written as for the matchpairs
+// option: the opening and closing characters of each pair separated by a
+// colon, and the pairs separated by commas, such as "(:),[:]". It returns a
+// map of the opening characters to the closing ones.
+func ParseMatchPairs(s string) (map[rune]rune, error) {
+	pairs := make(map[rune]rune)
+	if s == "" {
+		return pairs, nil
+	}
+	for _, p := range strings.Split(s, ",") {
+		o, size := utf8.DecodeRuneInString(p)
+		if !strings.HasPrefix(p[size:], ":") {
+			return nil, fmt.Errorf("invalid pair: %s", p)
+		}
+		rest := p[size+1:]
+		cl, size := utf8.DecodeRuneInString(rest)
+		if size == 0 || size != len(rest) || o == cl {
+			return nil, fmt.Errorf("invalid pair: %s", p)
+		}
+		pairs[o] = cl
+	}
+	return pairs, nil
+}
+
+// MatchPair returns a cursor on the character matching the first character
+// of a pair under or after the cursor on its line, as the % motion does.
+// pairs maps the opening characters to the closing ones, as returned by
+// ParseMatchPairs. The characters are matched across lines, taking nesting
+// into account. It reports whether there is such a character.
+func (c Cursor) MatchPair(pairs map[rune]rune) (Cursor, bool) {
+	for ; !c.EOL(); c.NextRune(false) {
+		r, _ := c.RuneUnder()
+		if cl, ok := pairs[r]; ok {
+			return c.findPair(r, cl, true)
+		}
+		for o, cl := range pairs {
+			if r == cl {
+				return c.findPair(cl, o, false)
+			}
+		}
+	}
+	return c, false
+}
+
+// findPair returns a cursor on the character other matching the character
+// this under the cursor, searching forward or backward.
+func (c Cursor) findPair(this, other rune, forward bool) (Cursor, bool) {
+	move := c.PrevRune
+	if forward {
+		move = c.NextRune
+	}
+	depth := 0
+	for move(true) {
+		switch r, _ := c.RuneUnder(); {
+		case r == this:
+			depth++
+		case r == other && depth == 0:
+			return c, true
+		case r == other:
+			depth--
+		}
+	}
+	return c, false
+}
@@ -0,0 +1,75 @@
+package buffer
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseMatchPairs(t *testing.T) {
+	for _, test := range []struct {
+		s     string
+		pairs map[rune]rune // nil if invalid
+	}{
+		{"(:),{:},[:],<:>", map[rune]rune{'(': ')', '{': '}', '[': ']', '<': '>'}},
+		{"«:»", map[rune]rune{'«': '»'}},
+		{"", map[rune]rune{}},
+		{"(", nil},
+		{"(:", nil},
+		{"(:)]", nil},
+		{"(-)", nil},
+		{"|:|", nil},
+	} {
+		pairs, err := ParseMatchPairs(test.s)
+		if (err != nil) != (test.pairs == nil) {
+			t.Errorf("%q: got error %v", test.s, err)
+			continue
+		}
+		if len(pairs) != len(test.pairs) {
+			t.Errorf("%q: got %v, want %v", test.s, pairs, test.pairs)
+			continue
+		}
+		for o, cl := range test.pairs {
+			if pairs[o] != cl {
+				t.Errorf("%q: got %v, want %v", test.s, pairs, test.pairs)
+				break
+			}
+		}
+	}
+}
+
+func TestMatchPair(t *testing.T) {
+	b, err := NewBuffer(strings.NewReader("if (a[1] < f(b)) {\n\tx <y>\n}\n"))
+	if err != nil {
+		t.Fatal("Error creating buffer")
+	}
+
+	for _, test := range []struct {
+		pairs string
+		from  [2]int // line and offset
+		want  [2]int // or 0, 0 if there is no match
+	}{
+		{"(:),[:],{:}", [2]int{1, 0}, [2]int{1, 15}},
+		{"(:),[:],{:}", [2]int{1, 15}, [2]int{1, 3}},
+		{"(:),[:],{:}", [2]int{1, 5}, [2]int{1, 7}},
+		{"(:),[:],{:}", [2]int{1, 16}, [2]int{3, 0}},
+		{"(:),[:],{:}", [2]int{3, 0}, [2]int{1, 17}},
+		{"(:),[:],{:}", [2]int{2, 0}, [2]int{0, 0}},
+		{"(:),[:],{:},<:>", [2]int{2, 0}, [2]int{2, 5}},
+		{"(:),[:],{:},<:>", [2]int{1, 8}, [2]int{0, 0}},
+	} {
+		pairs, err := ParseMatchPairs(test.pairs)
+		if err != nil {
+			t.Fatal(err)
+		}
+		c := b.LineCursor(test.from[0])
+		c.Boffset = test.from[1]
+		m, ok := c.MatchPair(pairs)
+		got := [2]int{m.LineNum, m.Boffset}
+		if !ok {
+			got = [2]int{0, 0}
+		}
+		if got != test.want {
+			t.Errorf("%s from %v: got %v, want %v", test.pairs, test.from, got, test.want)
+		}
+	}
+}
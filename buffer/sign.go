@@ -0,0 +1,44 @@
+package buffer
+
+// Sign is a glyph drawn next to a line in the sign column of the views, such
+// as for marks or diagnostics.
+type Sign struct {
+	Glyph rune
+	Color string // foreground color and attributes, as in theme files
+}
+
+// SetSign places a sign drawn as glyph in color on the line n, replacing the
+// sign of the line if there is one. It returns false if there is no line n.
+// Signs follow their line as the buffer is modified, and are removed with it.
+func (b *Buffer) SetSign(n int, glyph rune, color string) bool {
+	c := b.LineCursor(n)
+	if c.LineNum != n {
+		return false
+	}
+	if b.signs == nil {
+		b.signs = make(map[*Line]Sign)
+	}
+	b.signs[c.Line] = Sign{glyph, color}
+	b.Emit(BufferEvent{Type: BufferEventSign})
+	return true
+}
+
+// RemoveSign removes the sign of the line n, if there is one.
+func (b *Buffer) RemoveSign(n int) {
+	c := b.LineCursor(n)
+	if _, ok := b.signs[c.Line]; ok && c.LineNum == n {
+		delete(b.signs, c.Line)
+		b.Emit(BufferEvent{Type: BufferEventSign})
+	}
+}
+
+// Sign returns the sign of the line l, and false if it has none.
+func (b *Buffer) Sign(l *Line) (Sign, bool) {
+	s, ok := b.signs[l]
+	return s, ok
+}
+
+// HasSigns reports whether any line of the buffer has a sign.
+func (b *Buffer) HasSigns() bool {
+	return len(b.signs) > 0
+}
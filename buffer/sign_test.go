@@ -0,0 +1,40 @@
+package buffer
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSigns(t *testing.T) {
+	b, err := NewBuffer(strings.NewReader("foo\nbar\nbaz\n"))
+	if err != nil {
+		t.Fatal("Error creating buffer")
+	}
+	if b.SetSign(5, '>', "red") {
+		t.Error("sign set on a line out of the buffer")
+	}
+	if !b.SetSign(2, '>', "red") || !b.HasSigns() {
+		t.Fatal("sign not set")
+	}
+
+	b.Insert(Cursor{Line: b.FirstLine, LineNum: 1, Boffset: 0}, []byte("new\n"))
+	if s, ok := b.Sign(b.LineCursor(3).Line); !ok || s != (Sign{'>', "red"}) {
+		t.Errorf("after insert above: got sign %v %v, want > red", s, ok)
+	}
+
+	// joining the lines removes the second one
+	b.Delete(Cursor{Line: b.FirstLine.Next, LineNum: 2, Boffset: 3}, 1)
+	if b.HasSigns() {
+		t.Error("sign kept after its line was deleted")
+	}
+
+	b.SetSign(1, '!', "")
+	b.RemoveSign(2)
+	if !b.HasSigns() {
+		t.Error("sign of another line removed")
+	}
+	b.RemoveSign(1)
+	if b.HasSigns() {
+		t.Error("sign not removed")
+	}
+}
@@ -0,0 +1,80 @@
+package buffer
+
+import (
+	"unicode/utf8"
+
+	"github.com/kisielk/vigo/utils"
+)
+
+// Stats holds the line, word, character and byte counts of a buffer,
+// or of a part of it.
+type Stats struct {
+	Lines int
+	Words int
+	Chars int
+	Bytes int
+}
+
+func (s *Stats) add(o Stats) {
+	s.Lines += o.Lines
+	s.Words += o.Words
+	s.Chars += o.Chars
+	s.Bytes += o.Bytes
+}
+
+// dataStats counts the words, characters and bytes in data.
+func dataStats(data []byte) Stats {
+	s := Stats{
+		Chars: utf8.RuneCount(data),
+		Bytes: len(data),
+	}
+	utils.IterWords(data, func([]byte) {
+		s.Words++
+	})
+	return s
+}
+
+// lineStats returns the cumulative stats of the buffer, where the n-th element
+// holds the counts for all lines before line n+1. The result is cached until
+// the buffer is modified.
+func (b *Buffer) lineStats() []Stats {
+	if b.stats != nil {
+		return b.stats
+	}
+
+	stats := make([]Stats, 1, b.NumLines+1)
+	var total Stats
+	for l := b.FirstLine; l != nil; l = l.Next {
+		s := dataStats(l.Data)
+		s.Lines = 1
+		if l.Next != nil {
+			// count the newline
+			s.Chars++
+			s.Bytes++
+		}
+		total.add(s)
+		stats = append(stats, total)
+	}
+	b.stats = stats
+	return stats
+}
+
+// Stats returns the counts for the whole buffer.
+func (b *Buffer) Stats() Stats {
+	s := b.lineStats()
+	return s[len(s)-1]
+}
+
+// StatsAt returns the counts for the part of the buffer up to and including
+// the character under the cursor c.
+func (b *Buffer) StatsAt(c Cursor) Stats {
+	s := b.lineStats()[c.LineNum-1]
+	end := c.Boffset
+	if !c.EOL() {
+		_, rlen := c.RuneUnder()
+		end += rlen
+	}
+	s.add(dataStats(c.Line.Data[:end]))
+	s.Lines = c.LineNum
+	return s
+}
@@ -0,0 +1,65 @@
+package buffer
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestStats(t *testing.T) {
+	b, err := NewBuffer(strings.NewReader("hello world\nfoo_bar, baz\n\nħello"))
+	if err != nil {
+		t.Fatal("Error creating buffer")
+	}
+
+	want := Stats{Lines: 4, Words: 5, Chars: 31, Bytes: 32}
+	if s := b.Stats(); s != want {
+		t.Errorf("bad total stats: got %+v, want %+v", s, want)
+	}
+
+	tests := []struct {
+		line    *Line
+		lineNum int
+		boffset int
+		want    Stats
+	}{
+		// on the first character
+		{b.FirstLine, 1, 0, Stats{Lines: 1, Words: 1, Chars: 1, Bytes: 1}},
+		// in the middle of "world"
+		{b.FirstLine, 1, 8, Stats{Lines: 1, Words: 2, Chars: 9, Bytes: 9}},
+		// on the space after "foo_bar,"
+		{b.FirstLine.Next, 2, 8, Stats{Lines: 2, Words: 3, Chars: 21, Bytes: 21}},
+		// on the empty line
+		{b.LastLine.Prev, 3, 0, Stats{Lines: 3, Words: 4, Chars: 25, Bytes: 25}},
+		// on the multi-byte rune
+		{b.LastLine, 4, 0, Stats{Lines: 4, Words: 5, Chars: 27, Bytes: 28}},
+	}
+	for i, test := range tests {
+		c := Cursor{Line: test.line, LineNum: test.lineNum, Boffset: test.boffset}
+		if s := b.StatsAt(c); s != test.want {
+			t.Errorf("%d: got %+v, want %+v", i, s, test.want)
+		}
+	}
+}
+
+func TestStatsInvalidate(t *testing.T) {
+	b, err := NewBuffer(strings.NewReader("foo bar"))
+	if err != nil {
+		t.Fatal("Error creating buffer")
+	}
+	if s := b.Stats(); s.Words != 2 {
+		t.Errorf("bad word count: got %d, want 2", s.Words)
+	}
+
+	c := Cursor{Line: b.FirstLine, LineNum: 1, Boffset: 3}
+	b.Insert(c, []byte(" baz\nqux"))
+	want := Stats{Lines: 2, Words: 4, Chars: 15, Bytes: 15}
+	if s := b.Stats(); s != want {
+		t.Errorf("after insert got %+v, want %+v", s, want)
+	}
+
+	b.Undo()
+	want = Stats{Lines: 1, Words: 2, Chars: 7, Bytes: 7}
+	if s := b.Stats(); s != want {
+		t.Errorf("after undo got %+v, want %+v", s, want)
+	}
+}
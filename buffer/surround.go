@@ -0,0 +1,142 @@
+package buffer
+
+import (
+	"unicode"
+)
+
+// SurroundPair returns the strings opening and closing a pair named by ch.
+// Brackets are named by either of their characters, and b, B for () and {}.
+// Any other punctuation character surrounds text with itself, like quotes.
+func SurroundPair(ch rune) (open, close string, ok bool) {
+	switch ch {
+	case '(', ')', 'b':
+		return "(", ")", true
+	case '[', ']':
+		return "[", "]", true
+	case '{', '}', 'B':
+		return "{", "}", true
+	case '<', '>':
+		return "<", ">", true
+	}
+	if unicode.IsPunct(ch) || unicode.IsSymbol(ch) {
+		return string(ch), string(ch), true
+	}
+	return "", "", false
+}
+
+// FindSurrounding returns cursors on the opening and closing characters of
+// the innermost pair named by ch around the cursor c. Brackets are matched
+// across lines, taking nesting into account, while quotes are only matched
+// on the line of the cursor.
+func (b *Buffer) FindSurrounding(c Cursor, ch rune) (start, end Cursor, ok bool) {
+	open, close, ok := SurroundPair(ch)
+	if !ok {
+		return start, end, false
+	}
+	o := []rune(open)[0]
+	cl := []rune(close)[0]
+	if o == cl {
+		return findQuotes(c, o)
+	}
+
+	// find the unmatched opening bracket at or before the cursor
+	start = c
+	if r, _ := start.RuneUnder(); r != o {
+		depth := 0
+	back:
+		for {
+			if !start.PrevRune(true) {
+				return start, end, false
+			}
+			switch r, _ := start.RuneUnder(); {
+			case r == cl:
+				depth++
+			case r == o && depth == 0:
+				break back
+			case r == o:
+				depth--
+			}
+		}
+	}
+
+	// and the bracket matching it
+	end = start
+	depth := 0
+	for end.NextRune(true) {
+		switch r, _ := end.RuneUnder(); {
+		case r == o:
+			depth++
+		case r == cl && depth == 0:
+			return start, end, true
+		case r == cl:
+			depth--
+		}
+	}
+	return start, end, false
+}
+
+// findQuotes returns cursors on the quotes q around the cursor c. Quotes are
+// paired from the beginning of the line.
+func findQuotes(c Cursor, q rune) (start, end Cursor, ok bool) {
+	start, end = c, c
+	var quotes []int
+	for i, r := range string(c.Line.Data) {
+		if r == q {
+			quotes = append(quotes, i)
+		}
+	}
+	for i := 0; i+1 < len(quotes); i += 2 {
+		if quotes[i] <= c.Boffset && c.Boffset <= quotes[i+1] {
+			start.Boffset, end.Boffset = quotes[i], quotes[i+1]
+			return start, end, true
+		}
+	}
+	return start, end, false
+}
+
+// Surround inserts the strings of the pair named by ch around the text
+// between the cursors from and to, the latter excluded.
+func (b *Buffer) Surround(from, to Cursor, ch rune) bool {
+	open, close, ok := SurroundPair(ch)
+	if !ok {
+		return false
+	}
+	from, to = SortCursors(from, to)
+	// inserting at to first keeps from valid
+	b.Insert(to, []byte(close))
+	b.Insert(from, []byte(open))
+	return true
+}
+
+// DeleteSurrounding deletes the pair named by ch around the cursor c.
+func (b *Buffer) DeleteSurrounding(c Cursor, ch rune) (Cursor, bool) {
+	start, end, ok := b.FindSurrounding(c, ch)
+	if !ok {
+		return c, false
+	}
+	_, elen := end.RuneUnder()
+	b.Delete(end, elen)
+	_, slen := start.RuneUnder()
+	b.Delete(start, slen)
+	return start, true
+}
+
+// ChangeSurrounding replaces the pair named by old around the cursor c with
+// the one named by new.
+func (b *Buffer) ChangeSurrounding(c Cursor, old, new rune) (Cursor, bool) {
+	open, close, ok := SurroundPair(new)
+	if !ok {
+		return c, false
+	}
+	start, end, ok := b.FindSurrounding(c, old)
+	if !ok {
+		return c, false
+	}
+	_, elen := end.RuneUnder()
+	b.Delete(end, elen)
+	b.Insert(end, []byte(close))
+	_, slen := start.RuneUnder()
+	b.Delete(start, slen)
+	b.Insert(start, []byte(open))
+	return start, true
+}
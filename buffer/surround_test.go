@@ -0,0 +1,62 @@
+package buffer
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFindSurrounding(t *testing.T) {
+	b, err := NewBuffer(strings.NewReader("f(a, (b)\n  c) \"d\" \"e\"\n"))
+	if err != nil {
+		t.Fatal("Error creating buffer")
+	}
+	second := Cursor{Line: b.FirstLine.Next, LineNum: 2}
+	tests := []struct {
+		c          Cursor
+		ch         rune
+		start, end Cursor
+		ok         bool
+	}{
+		{Cursor{Line: b.FirstLine, LineNum: 1, Boffset: 3}, '(', Cursor{b.FirstLine, 1, 1}, Cursor{second.Line, 2, 3}, true},
+		{Cursor{Line: b.FirstLine, LineNum: 1, Boffset: 6}, 'b', Cursor{b.FirstLine, 1, 5}, Cursor{b.FirstLine, 1, 7}, true},
+		{Cursor{Line: b.FirstLine, LineNum: 1, Boffset: 7}, ')', Cursor{b.FirstLine, 1, 5}, Cursor{b.FirstLine, 1, 7}, true},
+		{Cursor{Line: second.Line, LineNum: 2, Boffset: 3}, ')', Cursor{b.FirstLine, 1, 1}, Cursor{second.Line, 2, 3}, true},
+		{Cursor{Line: second.Line, LineNum: 2, Boffset: 6}, '"', Cursor{second.Line, 2, 5}, Cursor{second.Line, 2, 7}, true},
+		{Cursor{Line: second.Line, LineNum: 2, Boffset: 10}, '"', Cursor{second.Line, 2, 9}, Cursor{second.Line, 2, 11}, true},
+		{Cursor{Line: b.FirstLine, LineNum: 1, Boffset: 0}, '(', Cursor{}, Cursor{}, false},
+		{Cursor{Line: b.FirstLine, LineNum: 1, Boffset: 3}, '[', Cursor{}, Cursor{}, false},
+	}
+	for i, tt := range tests {
+		start, end, ok := b.FindSurrounding(tt.c, tt.ch)
+		if ok != tt.ok {
+			t.Errorf("%d: got ok %v, want %v", i, ok, tt.ok)
+			continue
+		}
+		if ok && (!start.Equals(tt.start) || !end.Equals(tt.end)) {
+			t.Errorf("%d: got %d:%d-%d:%d, want %d:%d-%d:%d", i,
+				start.LineNum, start.Boffset, end.LineNum, end.Boffset,
+				tt.start.LineNum, tt.start.Boffset, tt.end.LineNum, tt.end.Boffset)
+		}
+	}
+}
+
+func TestSurround(t *testing.T) {
+	b, err := NewBuffer(strings.NewReader("foo bar\n"))
+	if err != nil {
+		t.Fatal("Error creating buffer")
+	}
+	c := Cursor{Line: b.FirstLine, LineNum: 1, Boffset: 4}
+	b.Surround(c, Cursor{Line: b.FirstLine, LineNum: 1, Boffset: 7}, 'b')
+	checkLineBytes(t, b, [][]byte{[]byte("foo (bar)"), []byte("")})
+
+	c.Boffset = 6
+	if _, ok := b.ChangeSurrounding(c, ')', '"'); !ok {
+		t.Fatal("surrounding parentheses not changed")
+	}
+	checkLineBytes(t, b, [][]byte{[]byte("foo \"bar\""), []byte("")})
+
+	if _, ok := b.DeleteSurrounding(c, '"'); !ok {
+		t.Fatal("surrounding quotes not deleted")
+	}
+	checkLineBytes(t, b, [][]byte{[]byte("foo bar"), []byte("")})
+}
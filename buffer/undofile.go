@@ -0,0 +1,128 @@
+package buffer
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+)
+
+var ErrUndoMismatch = errors.New("undo history doesn't match the buffer contents")
+
+// undoFile is the serialized form of the undo history of a buffer. Cursors
+// are stored as positions, as the lines they point to are recreated when the
+// history is read.
+type undoFile struct {
+	Sum    string         // checksum of the contents the history leads to
+	Groups [][]undoAction // from the oldest to the current one
+}
+
+type undoAction struct {
+	What    ActionType
+	LineNum int
+	Boffset int
+	Data    []byte
+}
+
+func contentsSum(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// WriteUndo writes the undo history of the buffer to w, up to the current
+// action group. Action groups which can only be redone are not written.
+func (b *Buffer) WriteUndo(w io.Writer) error {
+	var groups []*ActionGroup
+	for g := b.History; g.Prev != nil; g = g.Prev {
+		groups = append(groups, g)
+	}
+
+	u := undoFile{Sum: contentsSum(b.contents())}
+	for i := len(groups) - 1; i >= 0; i-- {
+		if len(groups[i].Actions) == 0 {
+			continue
+		}
+		actions := make([]undoAction, len(groups[i].Actions))
+		for j, a := range groups[i].Actions {
+			actions[j] = undoAction{a.What, a.Cursor.LineNum, a.Cursor.Boffset, a.Data}
+		}
+		u.Groups = append(u.Groups, actions)
+	}
+	return json.NewEncoder(w).Encode(&u)
+}
+
+// ReadUndo replaces the undo history of the buffer with the one read from r,
+// which must have been written by WriteUndo for the current contents of the
+// buffer. The buffer is left unchanged in case of an error.
+func (b *Buffer) ReadUndo(r io.Reader) error {
+	var u undoFile
+	if err := json.NewDecoder(r).Decode(&u); err != nil {
+		return err
+	}
+	data := b.contents()
+	if u.Sum != contentsSum(data) {
+		return ErrUndoMismatch
+	}
+
+	// Go back to the contents before the first action group in a copy of
+	// the buffer, then redo all the groups to recreate the history.
+	t, err := NewBuffer(bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	for i := len(u.Groups) - 1; i >= 0; i-- {
+		for j := len(u.Groups[i]) - 1; j >= 0; j-- {
+			a := u.Groups[i][j]
+			if err := t.checkUndoAction(a); err != nil {
+				return err
+			}
+			c := t.LineCursor(a.LineNum)
+			c.Boffset = a.Boffset
+			switch a.What {
+			case ActionInsert:
+				NewDeleteAction(c, len(a.Data)).Apply(t)
+			case ActionDelete:
+				NewInsertAction(c, a.Data).Apply(t)
+			}
+		}
+	}
+	t.initHistory()
+	for _, g := range u.Groups {
+		t.FinalizeActionGroup()
+		for _, a := range g {
+			c := t.LineCursor(a.LineNum)
+			c.Boffset = a.Boffset
+			switch a.What {
+			case ActionInsert:
+				t.Insert(c, a.Data)
+			case ActionDelete:
+				t.Delete(c, len(a.Data))
+			}
+		}
+	}
+
+	b.FirstLine, b.LastLine = t.FirstLine, t.LastLine
+	b.NumLines, b.numBytes = t.NumLines, t.numBytes
+	b.History, b.onDisk = t.History, t.History
+	b.stats = nil
+	return nil
+}
+
+// checkUndoAction returns an error if the action a, about to be reverted,
+// doesn't match the contents of the buffer.
+func (b *Buffer) checkUndoAction(a undoAction) error {
+	if a.LineNum < 1 || a.LineNum > b.NumLines {
+		return ErrUndoMismatch
+	}
+	c := b.LineCursor(a.LineNum)
+	if a.Boffset < 0 || a.Boffset > c.Line.Len() {
+		return ErrUndoMismatch
+	}
+	c.Boffset = a.Boffset
+	if a.What == ActionInsert && !bytes.Equal(c.ExtractBytes(len(a.Data)), a.Data) {
+		return ErrUndoMismatch
+	}
+	return nil
+}
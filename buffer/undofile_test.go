@@ -0,0 +1,86 @@
+package buffer
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestUndoFile(t *testing.T) {
+	b, err := NewBuffer(strings.NewReader("foo\nbar\n"))
+	if err != nil {
+		t.Fatal("Error creating buffer")
+	}
+	b.Insert(Cursor{Line: b.FirstLine, LineNum: 1, Boffset: 3}, []byte(" baz\nqux"))
+	b.FinalizeActionGroup()
+	b.Delete(Cursor{Line: b.LastLine.Prev, LineNum: 3, Boffset: 0}, 2)
+	b.FinalizeActionGroup()
+
+	var undo bytes.Buffer
+	if err := b.WriteUndo(&undo); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := NewBuffer(bytes.NewReader(b.contents()))
+	if err != nil {
+		t.Fatal("Error creating buffer")
+	}
+	if err := r.ReadUndo(bytes.NewReader(undo.Bytes())); err != nil {
+		t.Fatal(err)
+	}
+	if !r.SyncedWithDisk() {
+		t.Error("buffer with restored history is not synced with disk")
+	}
+	checkLineBytes(t, r, [][]byte{
+		[]byte("foo baz"),
+		[]byte("qux"),
+		[]byte("r"),
+		[]byte(""),
+	})
+
+	r.Undo()
+	checkLineBytes(t, r, [][]byte{
+		[]byte("foo baz"),
+		[]byte("qux"),
+		[]byte("bar"),
+		[]byte(""),
+	})
+	r.Undo()
+	checkLineBytes(t, r, [][]byte{
+		[]byte("foo"),
+		[]byte("bar"),
+		[]byte(""),
+	})
+	r.Redo()
+	r.Redo()
+	checkLineBytes(t, r, [][]byte{
+		[]byte("foo baz"),
+		[]byte("qux"),
+		[]byte("r"),
+		[]byte(""),
+	})
+}
+
+func TestUndoFileMismatch(t *testing.T) {
+	b, err := NewBuffer(strings.NewReader("foo\n"))
+	if err != nil {
+		t.Fatal("Error creating buffer")
+	}
+	b.Insert(Cursor{Line: b.FirstLine, LineNum: 1, Boffset: 3}, []byte("bar"))
+	var undo bytes.Buffer
+	if err := b.WriteUndo(&undo); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := NewBuffer(strings.NewReader("foo\n"))
+	if err != nil {
+		t.Fatal("Error creating buffer")
+	}
+	if err := r.ReadUndo(&undo); err != ErrUndoMismatch {
+		t.Errorf("got error %v, want %v", err, ErrUndoMismatch)
+	}
+	checkLineBytes(t, r, [][]byte{
+		[]byte("foo"),
+		[]byte(""),
+	})
+}
@@ -0,0 +1,193 @@
+package commands
+
+import (
+	"bytes"
+
+	"github.com/kisielk/vigo/buffer"
+	"github.com/kisielk/vigo/editor"
+	"github.com/kisielk/vigo/utils"
+)
+
+// YankLines stores Count lines starting at the cursor line in the anonymous
+// cut buffer.
+type YankLines struct {
+	Count int
+}
+
+func (y YankLines) Apply(e *editor.Editor) {
+	c := e.ActiveView().Cursor()
+	c.Boffset = 0
+	end := c
+	n := 1
+	for ; n < y.Count && end.NextLine(); n++ {
+	}
+	end.MoveEOL()
+
+	s := c.ExtractBytes(c.Distance(end))
+	e.Yank('"', append(s, '\n'), true)
+	b := e.ActiveView().Buffer()
+	b.SetMark('[', c)
+	b.SetMark(']', end)
+	if n > 2 {
+		e.SetStatus("%d lines yanked", n)
+	}
+}
+
+// CutRunes deletes Count characters after the cursor, or before it if Dir is
+// Backward, as x and X do, and stores them in a cut buffer. It stops at the
+// end or the beginning of the line, and does nothing at the end of an empty
+// line.
+type CutRunes struct {
+	Dir      Dir
+	Count    int
+	Register byte // Name of the cut buffer, the anonymous one if 0.
+}
+
+func (x CutRunes) Apply(e *editor.Editor) {
+	v := e.ActiveView()
+	from := v.Cursor()
+	to := from
+	for i := 0; i < x.Count; i++ {
+		if x.Dir == Backward {
+			if !from.PrevRune(false) {
+				break
+			}
+		} else if to.EOL() || !to.NextRune(false) {
+			break
+		}
+	}
+	if from == to {
+		return
+	}
+
+	reg := x.Register
+	if reg == 0 {
+		reg = '"'
+	}
+	if !editor.IsCutBufferName(reg) {
+		e.SetStatus("Invalid cut buffer: %c", reg)
+		return
+	}
+	e.Yank(reg, from.ExtractBytes(from.Distance(to)), false)
+	b := v.Buffer()
+	b.FinalizeActionGroup()
+	b.DeleteRange(from, to)
+	b.FinalizeActionGroup()
+	v.Sync()
+	v.MoveCursorTo(from)
+}
+
+// Paste inserts the contents of a cut buffer Count times. Whole lines are put
+// below the cursor line, or above it if Dir is Backward, and the cursor is
+// moved to the first non-blank character of the first pasted line. Other text
+// is inserted after the cursor, or before it if Dir is Backward.
+type Paste struct {
+	Dir      Dir
+	Count    int
+	Register byte // Name of the cut buffer, the anonymous one if 0.
+	Indent   bool // Adjust the indentation of whole lines to the cursor line.
+}
+
+func (p Paste) Apply(e *editor.Editor) {
+	reg := p.Register
+	if reg == 0 {
+		reg = '"'
+	}
+	s, ok := e.CutBuffer(reg)
+	if !ok {
+		e.SetStatus("Invalid cut buffer: %c", reg)
+		return
+	}
+	if len(s) == 0 {
+		e.SetStatus("Nothing in cut buffer %c", reg)
+		return
+	}
+	count := p.Count
+	if count < 1 {
+		count = 1
+	}
+
+	v := e.ActiveView()
+	b := v.Buffer()
+	c := v.Cursor()
+
+	// Make the paste a single undo step.
+	b.FinalizeActionGroup()
+	defer b.FinalizeActionGroup()
+
+	if e.CutBufferLinewise(reg) {
+		if s[len(s)-1] != '\n' {
+			s = append(utils.CloneByteSlice(s), '\n')
+		}
+		if p.Indent {
+			ts := e.Config.View.TabStop
+			s = reindentLines(s, utils.IndentWidth(c.Line.Data, ts), ts, e.Config.ExpandTab)
+		}
+		c = pasteLines(b, c, bytes.Repeat(s, count), p.Dir)
+		c.Boffset = utils.IndexFirstNonSpace(c.Line.Data)
+	} else {
+		if p.Dir == Forward && !c.EOL() {
+			_, rlen := c.RuneUnder()
+			c.Boffset += rlen
+		}
+		data := bytes.Repeat(s, count)
+		b.Insert(c, data)
+		if bytes.IndexByte(data, '\n') == -1 {
+			// leave the cursor on the last pasted character
+			c.Boffset += len(data)
+			c.PrevRune(false)
+		}
+	}
+
+	v.Sync()
+	v.MoveCursorTo(c)
+}
+
+// pasteLines inserts data, made of whole lines, below the line of the cursor
+// c, or above it if dir is Backward. It returns a cursor on the first
+// inserted line.
+func pasteLines(b *buffer.Buffer, c buffer.Cursor, data []byte, dir Dir) buffer.Cursor {
+	c.Boffset = 0
+	switch {
+	case dir == Backward:
+		b.Insert(c, data)
+	case c.LastLine():
+		// there is no line to insert before, open a new one instead
+		c.MoveEOL()
+		b.Insert(c, append([]byte{'\n'}, data[:len(data)-1]...))
+		c.NextLine()
+		c.Boffset = 0
+	default:
+		c.NextLine()
+		c.Boffset = 0
+		b.Insert(c, data)
+	}
+	return c
+}
+
+// reindentLines returns a copy of data, made of whole lines, with the common
+// indentation of its non-blank lines replaced by whitespace taking width cells,
+// with tabstop cells between tab stops.
+func reindentLines(data []byte, width, tabstop int, expandTab bool) []byte {
+	lines := bytes.SplitAfter(data, []byte{'\n'})
+	common := -1
+	for _, l := range lines {
+		if len(bytes.TrimSpace(l)) == 0 {
+			continue
+		}
+		if w := utils.IndentWidth(l, tabstop); common == -1 || w < common {
+			common = w
+		}
+	}
+
+	var buf bytes.Buffer
+	for _, l := range lines {
+		if len(bytes.TrimSpace(l)) == 0 {
+			buf.Write(l)
+			continue
+		}
+		buf.Write(utils.MakeIndent(utils.IndentWidth(l, tabstop)-common+width, tabstop, expandTab))
+		buf.Write(l[utils.IndexFirstNonSpace(l):])
+	}
+	return buf.Bytes()
+}
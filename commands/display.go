@@ -1,7 +1,12 @@
 package commands
 
 import (
+	"fmt"
+	"unicode/utf8"
+
+	"github.com/kisielk/vigo/buffer"
 	"github.com/kisielk/vigo/editor"
+	"github.com/kisielk/vigo/view"
 )
 
 type DisplayFileStatus struct{}
@@ -16,3 +21,60 @@ func (r DisplayFileStatus) Apply(e *editor.Editor) {
 
 	v.SetStatus("\"%s\" %d lines --%d%%--", path, numLines, int(pc))
 }
+
+// DisplayWordCount shows the position of the cursor in terms of columns,
+// lines, words, characters and bytes, along with the totals for the buffer.
+type DisplayWordCount struct{}
+
+func (r DisplayWordCount) Apply(e *editor.Editor) {
+	v := e.ActiveView()
+	b := v.Buffer()
+	c := v.Cursor()
+
+	total := b.Stats()
+	pos := b.StatsAt(c)
+	v.SetStatus("Col %d of %d; Line %d of %d; Word %d of %d; Char %d of %d; Byte %d of %d",
+		c.Boffset+1, c.Line.Len(), pos.Lines, total.Lines, pos.Words, total.Words,
+		pos.Chars, total.Chars, pos.Bytes, total.Bytes)
+}
+
+// DisplaySelectionSize shows the size of the visual selection of the active
+// view: the number of lines for line-wise selections, the number of characters
+// for character-wise ones and the dimensions of block selections.
+type DisplaySelectionSize struct{}
+
+func (r DisplaySelectionSize) Apply(e *editor.Editor) {
+	v := e.ActiveView()
+	sel := v.Selection()
+	start, end := buffer.SortCursors(sel.Start, sel.End)
+	lines := end.LineNum - start.LineNum + 1
+
+	switch sel.Type {
+	case view.SelectionChar:
+		r := sel.EffectiveRange()
+		chars := utf8.RuneCount(r.Start.ExtractBytes(r.Start.Distance(r.End)))
+		if lines == 1 {
+			v.SetStatus("Visual: %s", pluralize(chars, "character"))
+		} else {
+			v.SetStatus("Visual: %s, %s", pluralize(lines, "line"), pluralize(chars, "character"))
+		}
+	case view.SelectionLine:
+		v.SetStatus("Visual Line: %s", pluralize(lines, "line"))
+	case view.SelectionBlock:
+		svo, _ := sel.Start.VoffsetCoffset(v.TabStop())
+		evo, _ := sel.End.VoffsetCoffset(v.TabStop())
+		cols := evo - svo
+		if cols < 0 {
+			cols = -cols
+		}
+		v.SetStatus("Visual Block: %dx%d", lines, cols+1)
+	}
+}
+
+// pluralize returns n followed by word, adding an "s" unless n is one.
+func pluralize(n int, word string) string {
+	if n == 1 {
+		return fmt.Sprintf("%d %s", n, word)
+	}
+	return fmt.Sprintf("%d %ss", n, word)
+}
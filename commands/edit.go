@@ -0,0 +1,76 @@
+package commands
+
+import (
+	"github.com/kisielk/vigo/buffer"
+	"github.com/kisielk/vigo/editor"
+	"github.com/kisielk/vigo/utils"
+)
+
+// Indent adds one shiftwidth to the indentation of Count lines starting at
+// the cursor line, as >> does. Empty lines stay empty. The cursor goes to the
+// first non-blank character of the first line.
+type Indent struct {
+	Count int
+}
+
+func (i Indent) Apply(e *editor.Editor) {
+	if n := indentLines(e, i.Count, e.Config.ShiftWidth); n > 0 {
+		e.ReportLines(n, "lines >ed 1 time")
+	}
+}
+
+// Deindent removes one shiftwidth from the indentation of Count lines
+// starting at the cursor line, as << does. Lines with less indentation lose
+// all of it, and lines without any are left as they are. The cursor goes to
+// the first non-blank character of the first line.
+type Deindent struct {
+	Count int
+}
+
+func (d Deindent) Apply(e *editor.Editor) {
+	if n := indentLines(e, d.Count, -e.Config.ShiftWidth); n > 0 {
+		e.ReportLines(n, "lines <ed 1 time")
+	}
+}
+
+// indentLines adds n cells, or removes them if n is negative, to the
+// indentation of count lines starting at the cursor line, at most up to the
+// end of buffer. It returns the number of lines shifted, none if n is 0.
+func indentLines(e *editor.Editor, count, n int) int {
+	if n == 0 {
+		return 0
+	}
+	v := e.ActiveView()
+	b := v.Buffer()
+	c := v.Cursor()
+	c.Boffset = 0
+
+	b.FinalizeActionGroup()
+	lines := 0
+	for d := c; lines < count; {
+		// empty lines stay empty
+		if len(d.Line.Data) > 0 {
+			addIndent(b, d, n, e.Config.View.TabStop, e.Config.ExpandTab)
+		}
+		lines++
+		if !d.NextLine() {
+			break
+		}
+	}
+	b.FinalizeActionGroup()
+
+	c.Boffset = utils.IndexFirstNonSpace(c.Line.Data)
+	v.Sync()
+	v.MoveCursorTo(c)
+	return lines
+}
+
+// addIndent adds n cells, or removes them if n is negative, to the
+// indentation of the line of the cursor c, which doesn't go below none.
+func addIndent(b *buffer.Buffer, c buffer.Cursor, n, tabstop int, expandTab bool) {
+	w := utils.IndentWidth(c.Line.Data, tabstop) + n
+	if w < 0 {
+		w = 0
+	}
+	setIndent(b, c, utils.MakeIndent(w, tabstop, expandTab))
+}
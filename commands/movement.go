@@ -1,6 +1,10 @@
 package commands
 
 import (
+	"bytes"
+	"fmt"
+	"unicode/utf8"
+
 	"github.com/kisielk/vigo/buffer"
 	"github.com/kisielk/vigo/editor"
 	"github.com/kisielk/vigo/utils"
@@ -40,6 +44,100 @@ func (m MoveRune) Apply(e *editor.Editor) {
 	v.MoveCursorTo(c)
 }
 
+// FindChar moves the cursor to the Count-th occurrence of Char on the cursor
+// line, after the cursor or before it if Dir is Backward. Till stops next to
+// the character instead, as the t and T commands. The cursor stays where it
+// is if there aren't enough occurrences.
+type FindChar struct {
+	Dir   Dir
+	Till  bool
+	Char  rune
+	Count int
+}
+
+func (f FindChar) Apply(e *editor.Editor) {
+	e.LastFind = editor.CharFind{Char: f.Char, Backward: f.Dir == Backward, Till: f.Till}
+	f.find(e, false)
+}
+
+// find moves the cursor as described by f. A repeated search until a
+// character starts past the one next to the cursor, so that it doesn't stop
+// where the last one did.
+func (f FindChar) find(e *editor.Editor, repeat bool) {
+	v := e.ActiveView()
+	c := v.Cursor()
+	data := c.Line.Data
+	needle := []byte(string(f.Char))
+
+	count := f.Count
+	if count < 1 {
+		count = 1
+	}
+	off := c.Boffset
+	if repeat && f.Till {
+		switch f.Dir {
+		case Forward:
+			_, size := utf8.DecodeRune(data[off:])
+			off += size
+		case Backward:
+			_, size := utf8.DecodeLastRune(data[:off])
+			off -= size
+		}
+	}
+	for i := 0; i < count; i++ {
+		var n int
+		switch f.Dir {
+		case Forward:
+			_, size := utf8.DecodeRune(data[off:])
+			if n = bytes.Index(data[off+size:], needle); n != -1 {
+				n += off + size
+			}
+		case Backward:
+			n = bytes.LastIndex(data[:off], needle)
+		}
+		if n == -1 {
+			v.SetStatus("Character not found: %c", f.Char)
+			return
+		}
+		off = n
+	}
+
+	if f.Till {
+		switch f.Dir {
+		case Forward:
+			_, size := utf8.DecodeLastRune(data[:off])
+			off -= size
+		case Backward:
+			off += len(needle)
+		}
+	}
+	c.Boffset = off
+	v.MoveCursorTo(c)
+}
+
+// RepeatFind repeats the last FindChar Count times, in the opposite direction
+// if Reverse is set.
+type RepeatFind struct {
+	Reverse bool
+	Count   int
+}
+
+func (r RepeatFind) Apply(e *editor.Editor) {
+	last := e.LastFind
+	if last.Char == 0 {
+		e.SetStatus("No previous find")
+		return
+	}
+	dir := Forward
+	if last.Backward != r.Reverse {
+		dir = Backward
+	}
+	FindChar{Dir: dir, Till: last.Till, Char: last.Char, Count: r.Count}.find(e, true)
+}
+
+// MoveWord moves the cursor to the start of the next or the previous word.
+// Going forward from the last word of the buffer, the cursor goes to its last
+// character, unless the cpoptions flag editor.CpoWordEOF is set.
 type MoveWord struct {
 	Dir Dir
 }
@@ -51,12 +149,16 @@ func (m MoveWord) Apply(e *editor.Editor) {
 
 	switch m.Dir {
 	case Forward:
-		if !c.NextWord() {
-			v.SetStatus("End of file")
-			return
+		last := c
+		if !c.NextWordFunc(e.Config.WordClass()) {
+			// the cursor is left at the end of buffer
+			if e.Config.CpOption(editor.CpoWordEOF) || !c.PrevRune(false) || c == last {
+				v.SetStatus("End of file")
+				return
+			}
 		}
 	case Backward:
-		if !c.PrevWord() {
+		if !c.PrevWordFunc(e.Config.WordClass()) {
 			v.SetStatus("Beginning of file")
 			return
 		}
@@ -70,12 +172,11 @@ type MoveWordEnd struct{}
 func (m MoveWordEnd) Apply(e *editor.Editor) {
 	v := e.ActiveView()
 	c := v.Cursor()
-	ok := c.EndWord()
-	v.MoveCursorTo(c)
-	// FIXME Message is never printed
-	if !ok {
+	if !c.EndWordFunc(e.Config.WordClass()) {
 		v.SetStatus("End of buffer")
+		return
 	}
+	v.MoveCursorTo(c)
 }
 
 type MoveLine struct {
@@ -122,6 +223,18 @@ func (m MoveFOL) Apply(e *editor.Editor) {
 	v.MoveCursorTo(c)
 }
 
+// MovePair moves the cursor to the character matching the first character of
+// a pair under or after the cursor on its line, as set by the matchpairs
+// option.
+type MovePair struct{}
+
+func (m MovePair) Apply(e *editor.Editor) {
+	v := e.ActiveView()
+	if c, ok := v.Cursor().MatchPair(e.Config.Pairs()); ok {
+		v.MoveCursorTo(c)
+	}
+}
+
 type MoveEOL struct{}
 
 func (m MoveEOL) Apply(e *editor.Editor) {
@@ -144,6 +257,27 @@ func (m MoveEOF) Apply(e *editor.Editor) {
 	v.MoveCursorTo(c)
 }
 
+// GoToLine moves the cursor to the start of the line numbered Line, or of the
+// last line if Last is set, and centers the view on it. Line numbers past the
+// end of the buffer go to the last line.
+type GoToLine struct {
+	Line int
+	Last bool
+}
+
+func (g GoToLine) Apply(e *editor.Editor) {
+	v := e.ActiveView()
+	n := g.Line
+	if last := v.Buffer().NumLines; g.Last || n > last {
+		n = last
+	}
+	if n < 1 {
+		n = 1
+	}
+	e.PushJump()
+	v.MoveCursorToLine(n)
+}
+
 type MoveView struct {
 	// TODO use Repeat{} rather than lines argument?
 	Lines int
@@ -195,3 +329,329 @@ func (m NearestVSplit) Apply(e *editor.Editor) {
 		e.SetActiveViewNode(k)
 	}
 }
+
+// GotoDeclaration moves the cursor to the first occurrence of the word under
+// the cursor in the current function, a crude way to find the declaration of
+// a local variable. The function is assumed to start at the last line above
+// the cursor which isn't indented.
+type GotoDeclaration struct{}
+
+func (g GotoDeclaration) Apply(e *editor.Editor) {
+	v := e.ActiveView()
+	d, ok := findDeclaration(e, v.Cursor())
+	if !ok {
+		return
+	}
+	v.MoveCursorTo(d)
+	v.Center()
+}
+
+// PreviewDeclaration shows the lines starting at the declaration found by
+// GotoDeclaration in a popup, leaving the cursor where it is, as Ctrl-W }
+// does.
+type PreviewDeclaration struct{}
+
+func (p PreviewDeclaration) Apply(e *editor.Editor) {
+	v := e.ActiveView()
+	d, ok := findDeclaration(e, v.Cursor())
+	if !ok {
+		return
+	}
+	e.ShowPreview(fmt.Sprintf("%s:%d", v.Buffer().Name, d.LineNum), d)
+}
+
+// findDeclaration returns the first occurrence of the word under the cursor c
+// in the current function, found by searching backward from the cursor, or
+// reports why there is none in the status line.
+func findDeclaration(e *editor.Editor, c buffer.Cursor) (buffer.Cursor, bool) {
+	word := c.WordUnderCursorFunc(e.Config.WordClass())
+	if word == nil {
+		e.SetStatus("No identifier under cursor")
+		return c, false
+	}
+
+	top := c
+	for len(top.Line.Data) == 0 || top.Line.Data[0] == ' ' || top.Line.Data[0] == '\t' {
+		if !top.PrevLine() {
+			break
+		}
+	}
+	re := literal(string(word))
+	// from the end of the word under the cursor, which may be the only one
+	d, found := c, false
+	d.Boffset += len(word)
+	for {
+		next, ok := d.SearchBackward(re)
+		if !ok || next.LineNum < top.LineNum {
+			break
+		}
+		d = next
+		if isWordAt(d.Line.Data, d.Boffset, len(word)) {
+			c, found = d, true
+		}
+	}
+	if !found {
+		e.SetStatus("Declaration not found: %s", word)
+	}
+	return c, found
+}
+
+// ScrollPos is the place of the cursor line in the view after a Scroll, or of
+// the cursor in its line for ScrollLeftEdge and ScrollRightEdge.
+type ScrollPos int
+
+const (
+	ScrollTop ScrollPos = iota
+	ScrollCenter
+	ScrollBottom
+	ScrollLeftEdge
+	ScrollRightEdge
+)
+
+// Scroll scrolls the active view to put the cursor line at its top, middle or
+// bottom, as zt, zz and zb do, or the cursor at its left or right edge, as zs
+// and ze do.
+type Scroll struct {
+	Pos ScrollPos
+	// Line, if not 0, is the line the cursor is moved to first. It is clamped
+	// to the lines of the buffer.
+	Line int
+	// FirstNonBlank moves the cursor to the first non-blank character of the
+	// line, as z<CR>, z. and z- do.
+	FirstNonBlank bool
+}
+
+func (s Scroll) Apply(e *editor.Editor) {
+	v := e.ActiveView()
+	c := v.Cursor()
+	if s.Line > 0 {
+		n := s.Line
+		if n > v.Buffer().NumLines {
+			n = v.Buffer().NumLines
+		}
+		c = v.Buffer().LineCursor(n)
+		// keep the column
+		c.Boffset = -1
+	}
+	if s.FirstNonBlank {
+		c.Boffset = utils.IndexFirstNonSpace(c.Line.Data)
+	}
+	v.MoveCursorTo(c)
+
+	switch s.Pos {
+	case ScrollTop:
+		v.ScrollTop()
+	case ScrollCenter:
+		v.Center()
+	case ScrollBottom:
+		v.ScrollBottom()
+	case ScrollLeftEdge:
+		v.ScrollLeftEdge()
+	case ScrollRightEdge:
+		v.ScrollRightEdge()
+	}
+}
+
+// ScreenPos is a line of the lines shown in a view, which MoveScreen moves
+// the cursor to.
+type ScreenPos int
+
+const (
+	ScreenTop ScreenPos = iota
+	ScreenMiddle
+	ScreenBottom
+)
+
+// MoveScreen moves the cursor to the first non-blank character of the top,
+// middle or bottom line shown in the active view, as H, M and L do. Count
+// moves it to the line Count-1 lines below the top or above the bottom. The
+// cursor stays within the vertical threshold, so that the view doesn't
+// scroll.
+type MoveScreen struct {
+	Pos   ScreenPos
+	Count int
+}
+
+func (m MoveScreen) Apply(e *editor.Editor) {
+	v := e.ActiveView()
+	b := v.Buffer()
+	top, height := v.VisibleRange()
+	bottom := top + height - 1
+	if bottom > b.NumLines {
+		bottom = b.NumLines
+	}
+
+	count := m.Count
+	if count < 1 {
+		count = 1
+	}
+	var n int
+	switch m.Pos {
+	case ScreenTop:
+		n = top + count - 1
+	case ScreenMiddle:
+		n = (top + bottom) / 2
+	case ScreenBottom:
+		n = bottom - count + 1
+	}
+
+	// the view only scrolls up when the first line isn't shown
+	vt := v.VerticalThreshold()
+	if lo := top + vt; top > 1 && n < lo {
+		n = lo
+	}
+	if hi := top + height - 1 - vt; n > hi {
+		n = hi
+	}
+	if n > bottom {
+		n = bottom
+	}
+	if n < top {
+		n = top
+	}
+
+	c := b.LineCursor(n)
+	c.Boffset = utils.IndexFirstNonSpace(c.Line.Data)
+	v.MoveCursorTo(c)
+}
+
+// GotoFile opens the file whose name is under the cursor, in the active view
+// or in a new one if Split is set.
+type GotoFile struct {
+	Split bool
+}
+
+func (g GotoFile) Apply(e *editor.Editor) {
+	c := e.ActiveView().Cursor()
+	name := c.FilenameUnderCursor()
+	if name == nil {
+		e.SetStatus("No file name under cursor")
+		return
+	}
+	open := e.Edit
+	if g.Split {
+		open = e.SplitEdit
+	}
+	if err := open(string(name)); err != nil {
+		e.SetStatus("Can't open %s: %s", name, err)
+	}
+}
+
+// isWordAt reports whether the n bytes of data at i aren't part of a longer
+// word.
+func isWordAt(data []byte, i, n int) bool {
+	before, _ := utf8.DecodeLastRune(data[:i])
+	after, _ := utf8.DecodeRune(data[i+n:])
+	return (i == 0 || !utils.IsWord(before)) && (i+n == len(data) || !utils.IsWord(after))
+}
+
+// MoveMark moves the cursor to the mark named Mark, or to the first
+// non-blank character of its line if Linewise is set.
+type MoveMark struct {
+	Mark     rune
+	Linewise bool
+}
+
+func (m MoveMark) Apply(e *editor.Editor) {
+	v := e.ActiveView()
+	c, ok := v.Buffer().Mark(m.Mark)
+	if !ok {
+		e.SetStatus("Mark not set: %c", m.Mark)
+		return
+	}
+	if m.Linewise {
+		c.Boffset = utils.IndexFirstNonSpace(c.Line.Data)
+	}
+	e.PushJump()
+	v.MoveCursorTo(c)
+}
+
+// Jump moves the cursor Count positions back in the jump list, or forward if
+// Dir is Forward, as Ctrl-O and Ctrl-I do. The positions may be in other
+// buffers, which are shown in the active view.
+type Jump struct {
+	Dir   Dir
+	Count int
+}
+
+func (j Jump) Apply(e *editor.Editor) {
+	count := j.Count
+	if j.Dir == Backward {
+		count = -count
+	}
+	if err := e.Jump(count); err != nil {
+		e.SetStatus("%s", err)
+	}
+}
+
+// MoveChange moves the cursor Count positions back in the change list of the
+// active buffer, or forward if Dir is Forward, as g; and g, do.
+type MoveChange struct {
+	Dir   Dir
+	Count int
+}
+
+func (m MoveChange) Apply(e *editor.Editor) {
+	v := e.ActiveView()
+	count := m.Count
+	if m.Dir == Forward {
+		count = -count
+	}
+	c, ok := v.Buffer().Change(count)
+	if !ok {
+		if m.Dir == Forward {
+			e.SetStatus("At end of changelist")
+		} else {
+			e.SetStatus("At start of changelist")
+		}
+		return
+	}
+	v.MoveCursorTo(c)
+}
+
+// MoveMisspelling moves the cursor to the next misspelled word in the
+// direction Dir, when spell checking is enabled.
+type MoveMisspelling struct {
+	Dir Dir
+}
+
+func (m MoveMisspelling) Apply(e *editor.Editor) {
+	d := e.Config.View.Dictionary
+	if !e.Config.View.Spell || d == nil {
+		e.SetStatus("Spell checking is not enabled")
+		return
+	}
+
+	v := e.ActiveView()
+	c := v.Cursor()
+	from := c.Boffset
+	for {
+		found := -1
+		d.IterMisspellings(c.Line.Data, func(start, end int) {
+			switch {
+			case m.Dir == Forward && start > from && found == -1:
+				found = start
+			case m.Dir == Backward && start < from:
+				found = start
+			}
+		})
+		if found != -1 {
+			c.Boffset = found
+			v.MoveCursorTo(c)
+			return
+		}
+
+		if m.Dir == Forward {
+			if !c.NextLine() {
+				break
+			}
+			from = -1
+		} else {
+			if !c.PrevLine() {
+				break
+			}
+			from = len(c.Line.Data) + 1
+		}
+	}
+	e.SetStatus("No more misspelled words")
+}
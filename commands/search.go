@@ -1,69 +1,67 @@
 package commands
 
 import (
-	"bytes"
+	"regexp"
+
+	"github.com/kisielk/vigo/buffer"
 	"github.com/kisielk/vigo/editor"
 )
 
 type Search struct {
-	Dir  Dir
+	Dir Dir
 }
 
 func (s Search) Apply(e *editor.Editor) {
 	v := e.ActiveView()
-	c := v.Cursor()
 
 	if e.LastSearchTerm == "" {
 		e.SetStatus("Nothing to search for.")
 		return
 	}
-	word := []byte(e.LastSearchTerm)
+	re := literal(e.LastSearchTerm)
 
+	var c buffer.Cursor
+	var ok bool
 	switch s.Dir {
 	case Forward:
 		e.SetStatus("Search forward for: %s", e.LastSearchTerm)
-		for {
-
-			// move the cursor one run forward.
-			// this allows us to move to the next match.
-			// without this, if the word under the cursor is a match,
-			// then we won't be able to advance to the next match
-			c.NextRune(false)
-
-			i := bytes.Index(c.Line.Data[c.Boffset:], word)
-			if i != -1 {
-				c.Boffset += i
-				break
-			}
-
-			c.Line = c.Line.Next
-			if c.Line == nil {
-				e.SetStatus("No more results")
-				return
-			}
-
-			c.LineNum++
-			c.Boffset = 0
+		if c, ok = v.Cursor().SearchForward(re); !ok {
+			e.SetStatus("No more results")
+			return
 		}
 	case Backward:
 		e.SetStatus("Search backward for: %s", e.LastSearchTerm)
-		for {
-			i := bytes.LastIndex(c.Line.Data[:c.Boffset], word)
-
-			if i != -1 {
-				c.Boffset = i
-				break
-			}
-
-			c.Line = c.Line.Prev
-			if c.Line == nil {
-				e.SetStatus("No previous results")
-				return
-			}
-			c.LineNum--
-			c.Boffset = len(c.Line.Data)
+		if c, ok = v.Cursor().SearchBackward(re); !ok {
+			e.SetStatus("No previous results")
+			return
 		}
 	}
 
+	e.PushJump()
 	v.MoveCursorTo(c)
 }
+
+// SearchPreview moves the cursor to the first match of the search term being
+// typed, after From, as set by the incsearch option. The cursor is put back
+// at From if there is none.
+type SearchPreview struct {
+	From buffer.Cursor
+	Term string
+}
+
+func (s SearchPreview) Apply(e *editor.Editor) {
+	v := e.ActiveView()
+	v.MoveCursorTo(s.From)
+	if s.Term == "" {
+		return
+	}
+	if c, ok := s.From.SearchForward(literal(s.Term)); ok {
+		v.MoveCursorTo(c)
+	}
+}
+
+// literal returns the regular expression matching word as it is, searches
+// being literal.
+func literal(word string) *regexp.Regexp {
+	return regexp.MustCompile(regexp.QuoteMeta(word))
+}
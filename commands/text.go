@@ -1,7 +1,14 @@
 package commands
 
 import (
+	"bytes"
+	"strconv"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/kisielk/vigo/buffer"
 	"github.com/kisielk/vigo/editor"
+	"github.com/kisielk/vigo/utils"
 )
 
 type InsertRune struct {
@@ -10,7 +17,174 @@ type InsertRune struct {
 
 func (r InsertRune) Apply(e *editor.Editor) {
 	view := e.ActiveView()
-	view.Buffer().InsertRune(view.Cursor(), r.Rune)
+	c := view.Cursor()
+	if e.Config.Paste && r.Rune == '\n' {
+		// '\r' inserts a new line without autoindent
+		r.Rune = '\r'
+	}
+	view.Buffer().InsertRune(c, r.Rune)
+
+	if r.Rune == ' ' && e.Config.TextWidth > 0 && !e.Config.Paste {
+		c.Boffset++
+		wrapLine(view.Buffer(), c, e.Config.TextWidth, e.Config.View.TabStop)
+	}
+}
+
+// wrapLine breaks the line of the cursor c at the last word boundary that
+// keeps the text before the cursor within width visual cells, with tabstop
+// cells between tab stops. The overflowing words are moved to a new line,
+// indented like the original one.
+func wrapLine(b *buffer.Buffer, c buffer.Cursor, width, tabstop int) {
+	data := c.Line.Data
+	indent := utils.IndexFirstNonSpace(data)
+
+	// Trailing whitespace is never wrapped.
+	end := c.Boffset
+	for end > indent && unicode.IsSpace(rune(data[end-1])) {
+		end--
+	}
+	if visualWidth(c, end, tabstop) <= width {
+		return
+	}
+
+	// Find the whitespace to replace with the line break. Prefer the last
+	// one that fits, otherwise break after the first word.
+	from, to := -1, -1
+	for i := indent; i < end; i++ {
+		if !unicode.IsSpace(rune(data[i])) {
+			continue
+		}
+		j := i
+		for j < end && unicode.IsSpace(rune(data[j])) {
+			j++
+		}
+		if from != -1 && visualWidth(c, i, tabstop) > width {
+			break
+		}
+		from, to = i, j
+		i = j
+	}
+	if from == -1 {
+		// a single long word, nothing to break
+		return
+	}
+
+	c.Boffset = from
+	b.Delete(c, to-from)
+	b.Insert(c, append([]byte{'\n'}, data[:indent]...))
+}
+
+// visualWidth returns the number of cells taken by the line of the
+// cursor c up to the byte offset boffset.
+func visualWidth(c buffer.Cursor, boffset, tabstop int) int {
+	c.Boffset = boffset
+	vo, _ := c.VoffsetCoffset(tabstop)
+	return vo
+}
+
+// ShiftLine changes the indentation of the cursor line by one shiftwidth,
+// adding it when Dir is Forward and removing it when Backward. The new
+// indentation is rounded to a multiple of the shiftwidth.
+type ShiftLine struct {
+	Dir Dir
+}
+
+func (s ShiftLine) Apply(e *editor.Editor) {
+	v := e.ActiveView()
+	c := v.Cursor()
+	sw := e.Config.ShiftWidth
+	if sw <= 0 {
+		return
+	}
+
+	shiftIndent(v.Buffer(), c, s.Dir, sw, e.Config.View.TabStop, e.Config.ExpandTab)
+}
+
+// shiftIndent adds one shiftwidth sw to the indentation of the line of the
+// cursor c when dir is Forward, and removes one when Backward, rounding it to
+// a multiple of sw as Ctrl-T and Ctrl-D do. Removing the indentation of a line
+// which has none leaves it as it is.
+func shiftIndent(b *buffer.Buffer, c buffer.Cursor, dir Dir, sw, tabstop int, expandTab bool) {
+	w := utils.IndentWidth(c.Line.Data, tabstop)
+	switch dir {
+	case Forward:
+		w = (w/sw + 1) * sw
+	case Backward:
+		w = (w+sw-1)/sw*sw - sw
+	}
+	setIndent(b, c, utils.MakeIndent(w, tabstop, expandTab))
+}
+
+// setIndent replaces the leading whitespace of the line of the cursor c
+// with indent.
+func setIndent(b *buffer.Buffer, c buffer.Cursor, indent []byte) {
+	n := utils.IndexFirstNonSpace(c.Line.Data)
+	if bytes.Equal(c.Line.Data[:n], indent) {
+		return
+	}
+	c.Boffset = 0
+	if n > 0 {
+		b.Delete(c, n)
+	}
+	if len(indent) > 0 {
+		b.Insert(c, indent)
+	}
+}
+
+// Increment adds Delta to the first number of each line between Start and
+// End, both included. On the line of Start, only numbers at or after the
+// cursor are considered. If Progressive is set, the n-th changed number gets
+// n times Delta instead.
+type Increment struct {
+	Start, End  buffer.Cursor
+	Delta       int
+	Progressive bool
+}
+
+func (inc Increment) Apply(e *editor.Editor) {
+	v := e.ActiveView()
+	b := v.Buffer()
+	b.FinalizeActionGroup()
+	defer b.FinalizeActionGroup()
+
+	c := inc.Start
+	delta := inc.Delta
+	for {
+		if i, j := utils.FindNumber(c.Line.Data, c.Boffset); i != -1 {
+			n, err := strconv.Atoi(string(c.Line.Data[i:j]))
+			if err == nil {
+				c.Boffset = i
+				b.Delete(c, j-i)
+				b.Insert(c, []byte(strconv.Itoa(n+delta)))
+				if inc.Progressive {
+					delta += inc.Delta
+				}
+			}
+		}
+		if c.LineNum >= inc.End.LineNum || !c.NextLine() {
+			break
+		}
+		c.Boffset = 0
+	}
+
+	v.Sync()
+	v.MoveCursorTo(inc.Start)
+}
+
+// InsertText inserts Text at the cursor.
+type InsertText struct {
+	Text []byte
+}
+
+func (t InsertText) Apply(e *editor.Editor) {
+	if len(t.Text) == 0 {
+		return
+	}
+	view := e.ActiveView()
+	view.Buffer().Insert(view.Cursor(), t.Text)
+	// the cursor is after the text for the keys typed next, such as the
+	// one ending a character code after Ctrl-V
+	view.Sync()
 }
 
 type DeleteRune struct{}
@@ -37,17 +211,348 @@ func (_ DeleteEOL) Apply(e *editor.Editor) {
 	v.Buffer().Delete(c, len(l.Data)-len(d))
 }
 
+// DeleteWordBackward deletes the word before the cursor. At the beginning of
+// a line it joins the line with the previous one instead.
+type DeleteWordBackward struct{}
+
+func (_ DeleteWordBackward) Apply(e *editor.Editor) {
+	v := e.ActiveView()
+	c := v.Cursor()
+	if c.BOL() {
+		v.Buffer().DeleteRuneBackward(c)
+		return
+	}
+	from := c
+	from.PrevWordFunc(e.Config.WordClass())
+	if from.Line != c.Line {
+		// only whitespace before the cursor
+		from = c
+		from.MoveBOL()
+	}
+	v.Buffer().Delete(from, c.Boffset-from.Boffset)
+}
+
+// DeleteBOL deletes the text between the first non-blank character of the
+// line and the cursor, or the indentation if the cursor is within it.
+type DeleteBOL struct{}
+
+func (_ DeleteBOL) Apply(e *editor.Editor) {
+	v := e.ActiveView()
+	c := v.Cursor()
+	from := c
+	from.Boffset = utils.IndexFirstNonSpace(c.Line.Data)
+	if from.Boffset >= c.Boffset {
+		from.Boffset = 0
+	}
+	if n := c.Boffset - from.Boffset; n > 0 {
+		v.Buffer().Delete(from, n)
+	}
+}
+
+// ReplaceChar replaces Count characters from the cursor with Char, as r does,
+// leaving the cursor on the last one. Nothing is replaced if the line has
+// fewer characters left. A newline replaces them all with a single line
+// break, and the cursor goes to the start of the new line.
+type ReplaceChar struct {
+	Char  rune
+	Count int
+}
+
+func (r ReplaceChar) Apply(e *editor.Editor) {
+	v := e.ActiveView()
+	b := v.Buffer()
+	c := v.Cursor()
+	end := c
+	for i := 0; i < r.Count; i++ {
+		if end.EOL() {
+			return
+		}
+		end.NextRune(false)
+	}
+
+	data := []byte(string(r.Char))
+	if r.Char != '\n' {
+		data = bytes.Repeat(data, r.Count)
+	}
+	b.FinalizeActionGroup()
+	defer b.FinalizeActionGroup()
+	b.Delete(c, c.Distance(end))
+	b.Insert(c, data)
+
+	if r.Char == '\n' {
+		c = b.LineCursor(c.LineNum + 1)
+	} else {
+		c.Boffset += len(data)
+		c.PrevRune(false)
+	}
+	v.Sync()
+	v.MoveCursorTo(c)
+}
+
+// OverwriteRune replaces the character under the cursor with Rune, or appends
+// it at the end of the line, and moves the cursor after it, as typing does in
+// replace mode. A newline breaks the line instead. The replaced character, or
+// 0 if there is none, is pushed on Replaced for RestoreRune.
+type OverwriteRune struct {
+	Rune     rune
+	Replaced *[]rune
+}
+
+func (o OverwriteRune) Apply(e *editor.Editor) {
+	v := e.ActiveView()
+	b := v.Buffer()
+	c := v.Cursor()
+	var old rune
+	if !c.EOL() && o.Rune != '\n' {
+		r, rlen := c.RuneUnder()
+		old = r
+		b.Delete(c, rlen)
+	}
+	*o.Replaced = append(*o.Replaced, old)
+	if o.Rune == '\n' {
+		// '\r' doesn't autoindent the new line
+		b.InsertRune(c, '\r')
+	} else {
+		b.InsertRune(c, o.Rune)
+	}
+	// the next key moves on from the cursor after the character
+	v.Sync()
+}
+
+// RestoreRune moves the cursor back over the last character typed in replace
+// mode, putting back the one it replaced, which is popped from Replaced.
+// Before the characters typed, the cursor just moves back.
+type RestoreRune struct {
+	Replaced *[]rune
+}
+
+func (r RestoreRune) Apply(e *editor.Editor) {
+	v := e.ActiveView()
+	b := v.Buffer()
+	c := v.Cursor()
+	n := len(*r.Replaced)
+	if n == 0 {
+		if c.PrevRune(false) {
+			v.MoveCursorTo(c)
+		}
+		return
+	}
+	old := (*r.Replaced)[n-1]
+	*r.Replaced = (*r.Replaced)[:n-1]
+	if old == 0 {
+		b.DeleteRuneBackward(c)
+		v.Sync()
+		return
+	}
+	c.PrevRune(false)
+	_, rlen := c.RuneUnder()
+	b.Delete(c, rlen)
+	b.InsertRune(c, old)
+	v.Sync()
+	v.MoveCursorTo(c)
+}
+
+// ChangeWord deletes the text changed by cw, before insert mode is entered:
+// Count words from the cursor without the blanks after the last one, or the
+// blanks under the cursor. The cpoptions flags editor.CpoChangeBlank and
+// editor.CpoChangeWordEnd select the Vi behaviors.
+type ChangeWord struct {
+	Count int
+}
+
+func (cw ChangeWord) Apply(e *editor.Editor) {
+	v := e.ActiveView()
+	b := v.Buffer()
+	from := v.Cursor()
+	to := from
+	class := e.Config.WordClass()
+	r, _ := from.RuneUnder()
+	switch {
+	case from.EOL():
+		return
+	case unicode.IsSpace(r) && e.Config.CpOption(editor.CpoChangeBlank):
+		to.NextRune(false)
+	case unicode.IsSpace(r):
+		for r, _ := to.RuneUnder(); !to.EOL() && unicode.IsSpace(r); r, _ = to.RuneUnder() {
+			to.NextRune(false)
+		}
+	case e.Config.CpOption(editor.CpoChangeWordEnd):
+		for i := 0; i < cw.Count; i++ {
+			if (i > 0 || !to.AtWordEndFunc(class)) && !to.EndWordFunc(class) {
+				break
+			}
+		}
+		// include the last rune of the word
+		to.NextRune(false)
+	default:
+		// as dw, but the last word ends at the end of its line
+		for i := 0; i < cw.Count; i++ {
+			next := to
+			if !next.NextWordFunc(class) || next.Line != to.Line && i == cw.Count-1 {
+				to.MoveEOL()
+				break
+			}
+			to = next
+		}
+	}
+
+	e.Yank('"', from.ExtractBytes(from.Distance(to)), false)
+	b.FinalizeActionGroup()
+	b.DeleteRange(from, to)
+	v.Sync()
+	v.MoveCursorTo(from)
+}
+
+// JoinLines joins Count lines, at least two, starting at the cursor line.
+// Unless Raw is set, the whitespace between the lines is replaced by a single
+// space, as described by buffer.JoinLines.
+type JoinLines struct {
+	Count int
+	Raw   bool
+}
+
+func (j JoinLines) Apply(e *editor.Editor) {
+	v := e.ActiveView()
+	b := v.Buffer()
+	b.FinalizeActionGroup()
+	lines := b.NumLines
+	c, ok := b.JoinLines(v.Cursor(), j.Count, !j.Raw)
+	b.FinalizeActionGroup()
+	if !ok {
+		return
+	}
+	v.Sync()
+	v.MoveCursorTo(c)
+	e.ReportLines(lines-b.NumLines, "fewer lines")
+}
+
 type NewLine struct {
 	Dir Dir
 }
 
 func (t NewLine) Apply(e *editor.Editor) {
 	// FIXME: Using Repeat{} results in added lines being separated.
+	v := e.ActiveView()
+	b := v.Buffer()
+	c := v.Cursor()
+	var indent []byte
+	if !e.Config.Paste {
+		indent = utils.CloneByteSlice(c.Line.Data[:utils.IndexFirstNonSpace(c.Line.Data)])
+	}
 	switch t.Dir {
 	case Forward:
+		c = b.InsertLineBelow(c, indent)
 	case Backward:
-		MoveLine{Backward}.Apply(e)
+		c = b.InsertLineAbove(c, indent)
+	}
+	v.Sync()
+	v.MoveCursorTo(c)
+}
+
+// Surround puts the pair named by Char around the text between Start and End,
+// the latter excluded. Indentation at Start is skipped.
+type Surround struct {
+	Start, End buffer.Cursor
+	Char       rune
+}
+
+func (s Surround) Apply(e *editor.Editor) {
+	v := e.ActiveView()
+	b := v.Buffer()
+	start := s.Start
+	if start.Boffset == 0 {
+		start.Boffset = utils.IndexFirstNonSpace(start.Line.Data)
+	}
+
+	b.FinalizeActionGroup()
+	defer b.FinalizeActionGroup()
+	if !b.Surround(start, s.End, s.Char) {
+		e.SetStatus("Invalid surrounding: %c", s.Char)
+		return
+	}
+	v.Sync()
+	v.MoveCursorTo(start)
+}
+
+// DeleteSurround deletes the pair named by Char around the cursor.
+type DeleteSurround struct {
+	Char rune
+}
+
+func (d DeleteSurround) Apply(e *editor.Editor) {
+	v := e.ActiveView()
+	b := v.Buffer()
+	b.FinalizeActionGroup()
+	defer b.FinalizeActionGroup()
+	c, ok := b.DeleteSurrounding(v.Cursor(), d.Char)
+	if !ok {
+		e.SetStatus("No surrounding %c found", d.Char)
+		return
+	}
+	v.Sync()
+	v.MoveCursorTo(c)
+}
+
+// ChangeSurround replaces the pair named by Old around the cursor with the
+// one named by New.
+type ChangeSurround struct {
+	Old, New rune
+}
+
+func (ch ChangeSurround) Apply(e *editor.Editor) {
+	v := e.ActiveView()
+	b := v.Buffer()
+	if _, _, ok := buffer.SurroundPair(ch.New); !ok {
+		e.SetStatus("Invalid surrounding: %c", ch.New)
+		return
 	}
-	MoveEOL{}.Apply(e)
-	InsertRune{'\n'}.Apply(e)
+	b.FinalizeActionGroup()
+	defer b.FinalizeActionGroup()
+	c, ok := b.ChangeSurrounding(v.Cursor(), ch.Old, ch.New)
+	if !ok {
+		e.SetStatus("No surrounding %c found", ch.Old)
+		return
+	}
+	v.Sync()
+	v.MoveCursorTo(c)
+}
+
+// ReplaceWord replaces the word under the cursor with Text.
+type ReplaceWord struct {
+	Text []byte
+}
+
+func (r ReplaceWord) Apply(e *editor.Editor) {
+	v := e.ActiveView()
+	b := v.Buffer()
+	c := v.Cursor()
+	data := c.Line.Data
+
+	start, end := c.Boffset, c.Boffset
+	for start > 0 {
+		ch, rlen := utf8.DecodeLastRune(data[:start])
+		if !utils.IsWord(ch) {
+			break
+		}
+		start -= rlen
+	}
+	for end < len(data) {
+		ch, rlen := utf8.DecodeRune(data[end:])
+		if !utils.IsWord(ch) {
+			break
+		}
+		end += rlen
+	}
+	if start == end {
+		e.SetStatus("No word under cursor")
+		return
+	}
+
+	b.FinalizeActionGroup()
+	defer b.FinalizeActionGroup()
+	c.Boffset = start
+	b.Delete(c, end-start)
+	b.Insert(c, r.Text)
+	v.Sync()
+	v.MoveCursorTo(c)
 }
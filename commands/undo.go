@@ -15,3 +15,11 @@ type Redo struct{}
 func (r Redo) Apply(e *editor.Editor) {
 	e.ActiveView().Buffer().Redo()
 }
+
+// BreakUndo starts a new undo step, so that the changes made after it are
+// undone separately from the ones made before.
+type BreakUndo struct{}
+
+func (u BreakUndo) Apply(e *editor.Editor) {
+	e.ActiveView().Buffer().FinalizeActionGroup()
+}
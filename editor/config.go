@@ -1,6 +1,374 @@
 package editor
 
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/kisielk/vigo/buffer"
+	"github.com/kisielk/vigo/utils"
+	"github.com/kisielk/vigo/view"
+)
+
+// Config holds the editor options which can be changed at runtime with the
+// :set command.
+type Config struct {
+	TextWidth    int    // Maximum width of inserted text before it is wrapped, 0 disables wrapping.
+	ShiftWidth   int    // Number of cells added or removed by a change of indentation.
+	ExpandTab    bool   // Indent with spaces instead of tabs.
+	Paste        bool   // Insert text as typed, without auto-indent or wrapping.
+	EqualPrg     string // External program used by the = operator.
+	UndoFile     bool   // Keep the undo history of files across sessions.
+	SplitBelow   bool   // Focus the bottom view after a horizontal split.
+	SplitRight   bool   // Focus the right view after a vertical split.
+	Mouse        string // Mouse support is enabled if it contains 'a'.
+	ShowCmd      bool   // Display the keys of a partially typed command.
+	WildMenu     bool   // Display the candidates of command line completion.
+	SpellFile    string // Word list used by the spell checker, one word per line.
+	UnicodeWords bool   // Split words where the script changes, as in Japanese text.
+	Colors       int    // Number of colors of the terminal, 8 or 256.
+	IncSearch    bool   // Move the cursor to the first match while typing a search.
+	HLSearch     bool   // Highlight the matches of the last search.
+	UndoBreak    int    // Number of keys typed in insert mode in one undo step, 0 for no limit.
+	UndoTime     int    // Milliseconds of idle typing which start a new undo step, 0 never.
+	UndoLevels   int    // Maximum number of changes which can be undone, 0 for no limit.
+	MatchPairs   string // Pairs of characters matched by %, such as (:),[:].
+	Confirm      bool   // Ask whether to save changes which would be lost, instead of failing.
+	ListChars    string // Characters drawn by the list option, such as eol:$,tab:>-,trail:~.
+	FillChars    string // Characters drawn around views, such as vert:|,stl:-.
+	AutoChdir    bool   // Change the working directory to the one of the file of the active view.
+	Report       int    // Number of changed lines above which commands report them.
+	CpOptions    string // Flags choosing Vi behaviors over Vim ones, see CpOption.
+	WhichWrap    string // Keys moving the cursor across lines, see Wraps.
+
+	View view.Options // Options affecting the display of views.
+}
+
+// Flags of the cpoptions option, a subset of the ones of Vim and W. The
+// default, "_", behaves as Vim does, "wW" as Vi does.
 const (
-	ConfigWrapLeft  = true // Allow wrapping cursor to the previous line with 'h' motion.
-	ConfigWrapRight = true // Allow wrapping cursor to the next line with 'l' motion.
+	// cw on a blank changes only that character, instead of all the blanks
+	// up to the next word.
+	CpoChangeBlank = 'w'
+	// cw on a word changes up to its end as ce does, instead of including
+	// the blanks after it as dw does.
+	CpoChangeWordEnd = '_'
+	// w on the last word of the buffer fails, leaving the cursor where it
+	// is, instead of moving it to the last character.
+	CpoWordEOF = 'W'
 )
+
+// CpOption reports whether the flag of the cpoptions option is set.
+func (c *Config) CpOption(flag rune) bool {
+	return strings.ContainsRune(c.CpOptions, flag)
+}
+
+// Wraps reports whether the key moving the cursor left or right goes on to
+// the previous or the next line at the ends of lines, as set by the whichwrap
+// option: b for backspace, s for space, h or l.
+func (c *Config) Wraps(key rune) bool {
+	return strings.ContainsRune(c.WhichWrap, key)
+}
+
+// WordClass returns the function splitting words for word motions, as chosen
+// by the unicodewords option.
+func (c *Config) WordClass() func(rune) int {
+	if c.UnicodeWords {
+		return buffer.UnicodeWordClass
+	}
+	return buffer.LowercaseWordClass
+}
+
+// Pairs returns the pairs of characters matched by %, set by the matchpairs
+// option, mapping the opening characters to the closing ones.
+func (c *Config) Pairs() map[rune]rune {
+	// the value was checked by Set
+	pairs, _ := buffer.ParseMatchPairs(c.MatchPairs)
+	return pairs
+}
+
+func newConfig() *Config {
+	return &Config{
+		Colors:     defaultColors(),
+		CpOptions:  string(CpoChangeWordEnd),
+		FillChars:  view.DefaultFillChars.String(),
+		HLSearch:   true,
+		ListChars:  view.DefaultListChars.String(),
+		MatchPairs: "(:),[:],{:}",
+		Report:     2,
+		ShiftWidth: 8,
+		SpellFile:  "/usr/share/dict/words",
+		UndoBreak:  100,
+		UndoLevels: 1000,
+		UndoTime:   2000,
+		WhichWrap:  "b,s",
+		View: view.Options{
+			SplitKeep:  "cursor",
+			LastStatus: 2,
+			ScrollJump: 1,
+			TabStop:    utils.TabstopLength,
+			ListChars:  view.DefaultListChars,
+			FillChars:  view.DefaultFillChars,
+			Theme:      view.DefaultTheme,
+		},
+	}
+}
+
+// option describes a single option of the configuration. value points to
+// the bool, int or string field holding the option.
+type option struct {
+	name  string
+	short string
+	value interface{}
+}
+
+func (c *Config) options() []option {
+	return []option{
+		{"autochdir", "acd", &c.AutoChdir},
+		{"colors", "co", &c.Colors},
+		{"confirm", "cf", &c.Confirm},
+		{"cpoptions", "cpo", &c.CpOptions},
+		{"cursorcolumn", "cuc", &c.View.CursorColumn},
+		{"equalprg", "ep", &c.EqualPrg},
+		{"expandtab", "et", &c.ExpandTab},
+		{"fillchars", "fcs", &c.FillChars},
+		{"hlsearch", "hls", &c.HLSearch},
+		{"incsearch", "is", &c.IncSearch},
+		{"laststatus", "ls", &c.View.LastStatus},
+		{"list", "", &c.View.List},
+		{"listchars", "lcs", &c.ListChars},
+		{"matchpairs", "mps", &c.MatchPairs},
+		{"mouse", "", &c.Mouse},
+		{"paste", "", &c.Paste},
+		{"report", "", &c.Report},
+		{"scrolljump", "sj", &c.View.ScrollJump},
+		{"shiftwidth", "sw", &c.ShiftWidth},
+		{"showcmd", "sc", &c.ShowCmd},
+		{"spell", "", &c.View.Spell},
+		{"spellfile", "spf", &c.SpellFile},
+		{"splitbelow", "sb", &c.SplitBelow},
+		{"splitkeep", "spk", &c.View.SplitKeep},
+		{"splitright", "spr", &c.SplitRight},
+		{"tabstop", "ts", &c.View.TabStop},
+		{"textwidth", "tw", &c.TextWidth},
+		{"undobreak", "ub", &c.UndoBreak},
+		{"undofile", "udf", &c.UndoFile},
+		{"undolevels", "ul", &c.UndoLevels},
+		{"undotime", "ut", &c.UndoTime},
+		{"unicodewords", "uw", &c.UnicodeWords},
+		{"whichwrap", "ww", &c.WhichWrap},
+		{"wildmenu", "wmnu", &c.WildMenu},
+	}
+}
+
+func (c *Config) lookup(name string) (option, error) {
+	for _, o := range c.options() {
+		if name == o.name || o.short != "" && name == o.short {
+			return o, nil
+		}
+	}
+	return option{}, fmt.Errorf("unknown option: %s", name)
+}
+
+// Set changes an option as described by arg, which takes one of the forms
+// understood by the :set command:
+//
+//	name      switch a boolean option on, or show the value of any other
+//	noname    switch a boolean option off
+//	invname   toggle a boolean option, same as name!
+//	name=val  set the value of a number or string option
+//	name?     show the value of the option
+//
+// It returns a description of the option when its value was queried.
+func (c *Config) Set(arg string) (string, error) {
+	tabStop, colors, lastStatus := c.View.TabStop, c.Colors, c.View.LastStatus
+	matchPairs, listChars, fillChars := c.MatchPairs, c.ListChars, c.FillChars
+	scrollJump, splitKeep := c.View.ScrollJump, c.View.SplitKeep
+	value, err := c.set(arg)
+	if c.View.TabStop < 1 {
+		c.View.TabStop = tabStop
+		return "", fmt.Errorf("tabstop must be at least 1")
+	}
+	if c.Colors != 8 && c.Colors != 256 {
+		c.Colors = colors
+		return "", fmt.Errorf("colors must be 8 or 256")
+	}
+	if c.View.LastStatus < 0 || c.View.LastStatus > 2 {
+		c.View.LastStatus = lastStatus
+		return "", fmt.Errorf("laststatus must be 0, 1 or 2")
+	}
+	if c.View.ScrollJump < 1 {
+		c.View.ScrollJump = scrollJump
+		return "", fmt.Errorf("scrolljump must be at least 1")
+	}
+	switch c.View.SplitKeep {
+	case "cursor", "screen", "topline":
+	default:
+		c.View.SplitKeep = splitKeep
+		return "", fmt.Errorf("splitkeep must be cursor, screen or topline")
+	}
+	if _, perr := buffer.ParseMatchPairs(c.MatchPairs); perr != nil {
+		c.MatchPairs = matchPairs
+		return "", perr
+	}
+	if _, perr := view.ParseListChars(c.ListChars); perr != nil {
+		c.ListChars = listChars
+		return "", perr
+	}
+	if _, perr := view.ParseFillChars(c.FillChars); perr != nil {
+		c.FillChars = fillChars
+		return "", perr
+	}
+	return value, err
+}
+
+func (c *Config) set(arg string) (string, error) {
+	if i := strings.IndexByte(arg, '='); i != -1 {
+		o, err := c.lookup(arg[:i])
+		if err != nil {
+			return "", err
+		}
+		return "", o.set(arg[i+1:])
+	}
+
+	if strings.HasSuffix(arg, "?") {
+		o, err := c.lookup(strings.TrimSuffix(arg, "?"))
+		if err != nil {
+			return "", err
+		}
+		return o.String(), nil
+	}
+
+	name, toggle := arg, false
+	if strings.HasSuffix(name, "!") {
+		name, toggle = strings.TrimSuffix(name, "!"), true
+	}
+	if o, err := c.lookup(name); err == nil {
+		v, ok := o.value.(*bool)
+		switch {
+		case !ok && toggle:
+			return "", fmt.Errorf("not a boolean option: %s", arg)
+		case !ok:
+			return o.String(), nil
+		case toggle:
+			*v = !*v
+		default:
+			*v = true
+		}
+		return "", nil
+	}
+
+	for _, prefix := range []string{"no", "inv"} {
+		if !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		o, err := c.lookup(strings.TrimPrefix(name, prefix))
+		if err != nil {
+			continue
+		}
+		v, ok := o.value.(*bool)
+		if !ok {
+			return "", fmt.Errorf("not a boolean option: %s", arg)
+		}
+		*v = prefix == "inv" && !*v
+		return "", nil
+	}
+	return "", fmt.Errorf("unknown option: %s", name)
+}
+
+// String returns all options with their values.
+func (c *Config) String() string {
+	opts := c.options()
+	s := make([]string, len(opts))
+	for i, o := range opts {
+		s[i] = o.String()
+	}
+	return strings.Join(s, " ")
+}
+
+func (o option) set(s string) error {
+	switch v := o.value.(type) {
+	case *int:
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 0 {
+			return fmt.Errorf("invalid number for %s: %s", o.name, s)
+		}
+		*v = n
+	case *string:
+		*v = s
+	default:
+		return fmt.Errorf("not a number or string option: %s", o.name)
+	}
+	return nil
+}
+
+func (o option) String() string {
+	switch v := o.value.(type) {
+	case *bool:
+		if *v {
+			return o.name
+		}
+		return "no" + o.name
+	case *int:
+		return fmt.Sprintf("%s=%d", o.name, *v)
+	case *string:
+		return fmt.Sprintf("%s=%s", o.name, *v)
+	}
+	panic("unreachable")
+}
+
+// ApplyConfig puts into effect the options which need more than a change of
+// their value, once they have been set.
+func (e *Editor) ApplyConfig() error {
+	e.SetInputMode()
+	e.SetOutputMode()
+	for _, b := range e.buffers {
+		b.UndoLevels = e.Config.UndoLevels
+	}
+	if e.Config.HLSearch != e.hlSearch {
+		e.applyHLSearch()
+	}
+	lc, err := view.ParseListChars(e.Config.ListChars)
+	if err != nil {
+		return err
+	}
+	fc, err := view.ParseFillChars(e.Config.FillChars)
+	if err != nil {
+		return err
+	}
+	e.Config.View.ListChars, e.Config.View.FillChars = lc, fc
+	// the status lines shown depend on laststatus
+	e.Resize()
+
+	c := e.Config
+	if c.View.Spell && (c.View.Dictionary == nil || c.SpellFile != e.spellFile) {
+		f, err := os.Open(c.SpellFile)
+		if err != nil {
+			c.View.Spell = false
+			return err
+		}
+		defer f.Close()
+		d, err := utils.ReadDictionary(f)
+		if err != nil {
+			c.View.Spell = false
+			return err
+		}
+		c.View.Dictionary, e.spellFile = d, c.SpellFile
+	}
+	return nil
+}
+
+// applyHLSearch highlights the matches of the last search in all the views if
+// the hlsearch option is set, and removes them otherwise.
+func (e *Editor) applyHLSearch() {
+	e.hlSearch = e.Config.HLSearch
+	var term []byte
+	if e.hlSearch {
+		term = []byte(e.LastSearchTerm)
+	}
+	e.views.Walk(func(t *view.Tree) {
+		t.Leaf().SetHighlightBytes(term)
+		t.Leaf().ShowHighlights(e.hlSearch)
+	})
+}
@@ -1,49 +1,144 @@
 package editor
 
 import (
+	"bytes"
 	"fmt"
+	"strings"
 )
 
-type cutBuffers map[byte][]byte
+type cutBuffers struct {
+	data     map[byte][]byte
+	linewise map[byte]bool // buffers holding whole lines
+}
 
 func newCutBuffers() *cutBuffers {
-	c := make(cutBuffers, 36)
-	return &c
+	return &cutBuffers{
+		data:     make(map[byte][]byte, 36),
+		linewise: make(map[byte]bool, 36),
+	}
 }
 
 // UpdateAnon the contents of the anonymous cut buffer 1
 // with the given byte slice s, and rotates the rest of the buffers
-func (bs *cutBuffers) updateAnon(s []byte) {
-	bufs := *bs
+func (bs *cutBuffers) updateAnon(s []byte, linewise bool) {
 	for i := byte('9'); i > '1'; i-- {
-		bufs[i] = bufs[i-1]
+		bs.data[i] = bs.data[i-1]
+		bs.linewise[i] = bs.linewise[i-1]
 	}
-	bufs['1'] = s
-	*bs = bufs
+	bs.data['1'] = s
+	bs.linewise['1'] = linewise
+}
+
+// isCutBuffer reports whether b is a valid cut buffer name:
+// a character between a-z, 1-9, or .
+func isCutBuffer(b byte) bool {
+	return b == '.' || b >= '1' && b <= '9' || b >= 'a' && b <= 'z'
+}
+
+// IsCutBufferName reports whether b names a cut buffer for Yank: a valid cut
+// buffer name, " for the anonymous cut buffer, or A-Z appending to a-z.
+func IsCutBufferName(b byte) bool {
+	return b == '"' || b >= 'A' && b <= 'Z' || isCutBuffer(b)
 }
 
 // validCutBuffer panics if b is not a valid cut buffer name
 // b must a character between a-z, 1-9, or .
 func validCutBuffer(b byte) {
-	if b != '.' && b < '1' || b > '9' && b < 'a' || b > 'z' {
+	if !isCutBuffer(b) {
 		panic(fmt.Errorf("invalid cut buffer: %q", b))
 	}
 }
 
-// Set updates the contents of the cut buffer b with the byte slice s
-func (bs *cutBuffers) set(b byte, s []byte) {
+// Set updates the contents of the cut buffer b with the byte slice s,
+// holding whole lines if linewise is set
+func (bs *cutBuffers) set(b byte, s []byte, linewise bool) {
 	validCutBuffer(b)
-	(*bs)[b] = s
+	bs.data[b] = s
+	bs.linewise[b] = linewise
 }
 
-// Append appends the byte slice s to the contents of buffer b
-func (bs *cutBuffers) append(b byte, s []byte) {
+// Append appends the byte slice s to the contents of buffer b. Appending
+// whole lines makes the buffer hold whole lines, starting on a new one.
+func (bs *cutBuffers) append(b byte, s []byte, linewise bool) {
 	validCutBuffer(b)
-	(*bs)[b] = append((*bs)[b], s...)
+	data := bs.data[b]
+	if linewise && len(data) > 0 && data[len(data)-1] != '\n' {
+		data = append(data, '\n')
+	}
+	bs.data[b] = append(data, s...)
+	bs.linewise[b] = bs.linewise[b] || linewise
+}
+
+// isLinewise reports whether the buffer b holds whole lines
+func (bs *cutBuffers) isLinewise(b byte) bool {
+	validCutBuffer(b)
+	return bs.linewise[b]
 }
 
 // Get returns the contents of the cut buffer b
 func (bs *cutBuffers) get(b byte) []byte {
 	validCutBuffer(b)
-	return (*bs)[b]
+	return bs.data[b]
+}
+
+// CutBuffer returns the contents of the cut buffer b. The name " refers to
+// the anonymous cut buffer 1. ok is false if b is not a valid cut buffer name.
+func (e *Editor) CutBuffer(b byte) (s []byte, ok bool) {
+	if b == '"' {
+		b = '1'
+	}
+	if !isCutBuffer(b) {
+		return nil, false
+	}
+	return e.cutBuffers.get(b), true
+}
+
+// CutBufferLinewise reports whether the cut buffer b holds whole lines,
+// as opposed to a run of characters.
+func (e *Editor) CutBufferLinewise(b byte) bool {
+	if b == '"' {
+		b = '1'
+	}
+	return isCutBuffer(b) && e.cutBuffers.isLinewise(b)
+}
+
+// Yank stores s in the cut buffer b, marking it as whole lines if linewise
+// is set, in which case it ends with a newline. The name " stores s in the
+// anonymous cut buffer 1, rotating the previous contents to 2-9, and the
+// names A-Z append s to the cut buffers a-z.
+func (e *Editor) Yank(b byte, s []byte, linewise bool) {
+	if linewise && (len(s) == 0 || s[len(s)-1] != '\n') {
+		s = append(s[:len(s):len(s)], '\n')
+	}
+	switch {
+	case b == '"':
+		e.cutBuffers.updateAnon(s, linewise)
+	case 'A' <= b && b <= 'Z':
+		e.cutBuffers.append(b-'A'+'a', s, linewise)
+	default:
+		e.cutBuffers.set(b, s, linewise)
+	}
+}
+
+// DescribeCutBuffers returns the names and the contents of the cut buffers
+// which aren't empty, with control characters such as newlines shown as ^J.
+func (e *Editor) DescribeCutBuffers() string {
+	var s []string
+	for _, b := range []byte("123456789abcdefghijklmnopqrstuvwxyz.") {
+		data := e.cutBuffers.get(b)
+		if len(data) == 0 {
+			continue
+		}
+		var buf bytes.Buffer
+		for _, r := range string(data) {
+			if r < 32 {
+				buf.WriteByte('^')
+				buf.WriteByte(byte(r) + '@')
+			} else {
+				buf.WriteRune(r)
+			}
+		}
+		s = append(s, fmt.Sprintf("\"%c %s", b, buf.String()))
+	}
+	return strings.Join(s, "  ")
 }
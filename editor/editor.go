@@ -7,6 +7,8 @@ import (
 	"os"
 	"path/filepath"
 	"strconv"
+	"strings"
+	"unicode/utf8"
 
 	"github.com/kisielk/vigo/buffer"
 	"github.com/kisielk/vigo/view"
@@ -20,6 +22,15 @@ type Mode interface {
 	Exit()
 }
 
+// CommandReporter is implemented by modes which can report the keys of a
+// partially typed command, displayed when the showcmd option is set.
+type CommandReporter interface {
+	PendingCommand() string
+}
+
+// showCmdWidth is the number of cells used to display a pending command.
+const showCmdWidth = 10
+
 // this is a structure which represents a key press, used for keyboard macros
 type keyEvent struct {
 	mod termbox.Modifier
@@ -47,6 +58,17 @@ func (k keyEvent) toTermboxEvent() termbox.Event {
 
 var ErrQuit = errors.New("quit")
 
+// ErrUnsaved is returned when quitting would lose the changes of buffers.
+var ErrUnsaved = errors.New("unsaved changes, add ! to quit anyway")
+
+// CharFind is a search of a character on the cursor line, as done by f, F, t
+// and T.
+type CharFind struct {
+	Char     rune // 0 until there is a search
+	Backward bool
+	Till     bool
+}
+
 type Command interface {
 	Apply(*Editor)
 }
@@ -54,6 +76,7 @@ type Command interface {
 type Editor struct {
 	uiBuf       tulib.Buffer
 	active      *view.Tree // this one is always a leaf node
+	previous    *view.View // focused before the active one, for Ctrl-W p
 	views       *view.Tree // a root node
 	buffers     []*buffer.Buffer
 	statusBuf   bytes.Buffer
@@ -61,6 +84,27 @@ type Editor struct {
 	killBuffer_ []byte
 
 	LastSearchTerm string
+	LastFind       CharFind // repeated by ; and ,
+
+	jumps jumpList // positions left by jumps, for Ctrl-O and Ctrl-I
+
+	quickfix quickfixList // set by :make and :grep
+
+	paste  bracketedPaste
+	mouse  mouseState
+	macros macros
+
+	// Options changed with :set
+	Config *Config
+
+	spellFile string // word list loaded in the dictionary of Config
+	dir       string // working directory last set by the autochdir option
+	hlSearch  bool   // hlsearch option last applied to the views
+
+	colorScheme *view.Theme // loaded by :colorscheme, nil for the default
+
+	// Keys of normal mode changed with :nmap
+	NormalMap *Keymap
 
 	// Event channels
 	UIEvents chan termbox.Event
@@ -82,6 +126,12 @@ func (e *Editor) ActiveViewNode() *view.Tree {
 }
 
 func (e *Editor) Quit() {
+	var views []*view.View
+	e.views.Walk(func(t *view.Tree) {
+		views = append(views, t.Leaf())
+	})
+	// quit even if the positions can't be saved, there would be no way out
+	e.SavePositions(views...)
 	e.SetStatus("Quit")
 	// Signals event loop to quit on next iteration.
 	e.quitFlag = true
@@ -91,6 +141,10 @@ func NewEditor(filenames []string) *Editor {
 	e := new(Editor)
 	e.buffers = make([]*buffer.Buffer, 0, 20)
 	e.cutBuffers = newCutBuffers()
+	e.Config = newConfig()
+	e.hlSearch = e.Config.HLSearch
+	e.NormalMap = newKeymap()
+	e.quickfix.name = "[Quickfix List]"
 
 	for _, filename := range filenames {
 		//TODO: Check errors here
@@ -99,11 +153,12 @@ func NewEditor(filenames []string) *Editor {
 	if len(e.buffers) == 0 {
 		buf := buffer.NewEmptyBuffer()
 		buf.Name = e.bufferName("unnamed")
-		e.buffers = append(e.buffers, buf)
+		e.addBuffer(buf)
 	}
-	e.redraw = make(chan struct{})
+	e.redraw = make(chan struct{}, 1)
 	e.views = view.NewTree(view.NewView(e.viewContext(), e.buffers[0], e.redraw))
 	e.active = e.views
+	restoreCursor(e.ActiveView())
 	e.UIEvents = make(chan termbox.Event, 20)
 	e.Commands = make(chan Command, 20)
 	return e
@@ -169,37 +224,219 @@ func (e *Editor) NewBufferFromFile(filename string) (*buffer.Buffer, error) {
 		return nil, err
 	}
 	buf.Path = fullpath
+	if e.Config.UndoFile {
+		if err := e.readUndoFile(buf); err != nil {
+			e.SetStatus("Undo history not restored: %s", err)
+		}
+	}
+	if err := restorePosition(buf); err != nil {
+		e.SetStatus("Cursor position not restored: %s", err)
+	}
 
 	buf.Name = e.bufferName(filename)
-	e.buffers = append(e.buffers, buf)
+	e.addBuffer(buf)
 	return buf, nil
 }
 
+// addBuffer adds buf to the open buffers, with the options set for them.
+func (e *Editor) addBuffer(buf *buffer.Buffer) {
+	buf.UndoLevels = e.Config.UndoLevels
+	e.buffers = append(e.buffers, buf)
+}
+
+// Edit opens the file filename in the active view, with the cursor where it
+// was when the file was last left.
+func (e *Editor) Edit(filename string) error {
+	buf, err := e.NewBufferFromFile(filename)
+	if err != nil {
+		return err
+	}
+	v := e.ActiveView()
+	if v.Buffer() == buf {
+		return nil
+	}
+	if err := e.SavePositions(v); err != nil {
+		e.SetStatus("%s", err)
+	}
+	e.PushJump()
+	v.Attach(buf)
+	restoreCursor(v)
+	return nil
+}
+
+// SplitEdit opens the file filename in a new view above the active one, or
+// below it with the splitbelow option. The views are left as they are if the
+// file can't be opened.
+func (e *Editor) SplitEdit(filename string) error {
+	buf, err := e.NewBufferFromFile(filename)
+	if err != nil {
+		return err
+	}
+	node := e.active
+	e.SplitHorizontally()
+	if e.active == node {
+		return errors.New("not enough room")
+	}
+	v := e.ActiveView()
+	v.Attach(buf)
+	restoreCursor(v)
+	return nil
+}
+
+// SplitNew splits the active view as SplitHorizontally does, and shows a new
+// empty buffer in the new view.
+func (e *Editor) SplitNew() error {
+	node := e.active
+	e.SplitHorizontally()
+	if e.active == node {
+		return errors.New("not enough room")
+	}
+	buf := buffer.NewEmptyBuffer()
+	buf.Name = e.bufferName("unnamed")
+	e.addBuffer(buf)
+	e.ActiveView().Attach(buf)
+	return nil
+}
+
+// CloseView closes the active view, unless it is the last one.
+func (e *Editor) CloseView() error {
+	if e.active.Parent() == nil {
+		return errors.New("cannot close the last view")
+	}
+	// closing the view even if the position can't be saved, as Quit does
+	if err := e.SavePositions(e.ActiveView()); err != nil {
+		e.SetStatus("%s", err)
+	}
+	e.killActiveView()
+	return nil
+}
+
+// QuitView closes the active view, or quits if it is the last one. Quitting
+// is refused while buffers have unsaved changes, unless force is set.
+func (e *Editor) QuitView(force bool) error {
+	if e.active.Parent() != nil {
+		return e.CloseView()
+	}
+	if !force && e.hasUnsavedBuffers() {
+		return ErrUnsaved
+	}
+	e.Quit()
+	return nil
+}
+
 func (e *Editor) SetStatus(format string, args ...interface{}) {
 	e.statusBuf.Reset()
 	fmt.Fprintf(&e.statusBuf, format, args...)
 }
 
+// ReportLines shows that n lines were changed as told by what, such as
+// "lines changed", when there are more than the report option allows.
+func (e *Editor) ReportLines(n int, what string) {
+	if n <= e.Config.Report {
+		return
+	}
+	if n == 1 {
+		what = strings.Replace(what, "lines", "line", 1)
+	}
+	e.SetStatus("%d %s", n, what)
+}
+
 func (e *Editor) SetActiveViewNode(node *view.Tree) {
+	if node != e.active {
+		e.previous = e.active.Leaf()
+	}
 	e.active = node
 }
 
+// FocusPreviousView focuses the view which was focused before the active one,
+// or any other view if that one has been closed.
+func (e *Editor) FocusPreviousView() {
+	var prev, other *view.Tree
+	e.views.Walk(func(t *view.Tree) {
+		switch {
+		case t == e.active:
+		case t.Leaf() == e.previous:
+			prev = t
+		case other == nil:
+			other = t
+		}
+	})
+	if prev == nil {
+		prev = other
+	}
+	if prev != nil {
+		e.SetActiveViewNode(prev)
+	}
+}
+
+// SplitVertically splits the active view in two side by side views of the
+// same buffer. The left one stays active, unless the splitright option is set.
 func (e *Editor) SplitVertically() {
 	if e.active.Width == 0 {
 		return
 	}
 	e.active.SplitVertically()
-	e.active = e.active.Left()
+	if e.Config.SplitRight {
+		e.active = e.active.Right()
+	} else {
+		e.active = e.active.Left()
+	}
+	e.previous = e.active.Sibling().Leaf()
 	e.Resize()
 }
 
+// SplitHorizontally splits the active view in two views of the same buffer,
+// one above the other. The top one stays active, unless the splitbelow option
+// is set.
 func (e *Editor) SplitHorizontally() {
 	if e.active.Height == 0 {
 		return
 	}
 	e.active.SplitHorizontally()
-	e.active = e.active.Top()
+	if e.Config.SplitBelow {
+		e.active = e.active.Bottom()
+	} else {
+		e.active = e.active.Top()
+	}
+	e.previous = e.active.Sibling().Leaf()
+	e.Resize()
+}
+
+// MoveActiveViewToEdge moves the active view to the left or right edge of the
+// screen, taking its whole height, if vertical is set, or else to the top or
+// bottom edge, taking its whole width. first selects the left or top edge.
+func (e *Editor) MoveActiveViewToEdge(vertical, first bool) {
+	e.views = e.active.MoveToEdge(e.views, vertical, first)
+	e.Resize()
+}
+
+// RotateViews moves each view of the row or column of views containing the
+// active one to the next place, or to the previous one if reverse is set.
+func (e *Editor) RotateViews(reverse bool) error {
+	t, ok := e.active.Rotate(reverse)
+	if !ok {
+		return fmt.Errorf("cannot rotate when another view is split")
+	}
+	e.active = t
 	e.Resize()
+	return nil
+}
+
+// ExchangeViews swaps the active view with the next one in its row or column
+// of views, or the previous one if it is the last.
+func (e *Editor) ExchangeViews() error {
+	t, ok := e.active.Exchange()
+	if !ok {
+		return fmt.Errorf("cannot exchange with a split view")
+	}
+	e.active = t
+	e.Resize()
+	return nil
+}
+
+// EqualizeViews gives all the views the same size, as far as possible.
+func (e *Editor) EqualizeViews() {
+	e.views.Equalize()
 }
 
 func (e *Editor) killActiveView() {
@@ -211,6 +448,9 @@ func (e *Editor) killActiveView() {
 	pp := p.Parent()
 	sib := e.active.Sibling()
 	e.active.Leaf().Detach()
+	if e.previous == e.active.Leaf() {
+		e.previous = nil
+	}
 
 	*p = *sib
 	p.Reparent(pp)
@@ -228,6 +468,7 @@ func (e *Editor) killAllViewsButActive() {
 			leaf.Detach()
 		}
 	})
+	e.previous = nil
 	e.views = e.active
 	e.views.SetParent(nil)
 	e.Resize()
@@ -250,8 +491,14 @@ func (e *Editor) Draw() {
 	// draw everything
 	e.views.Draw()
 	e.compositeRecursively(e.views)
-	e.fixEdges(e.views)
+	if e.Config.View.FillChars == view.DefaultFillChars {
+		// the junctions only join the default lines
+		e.fixEdges(e.views)
+	}
 	e.DrawStatus(e.statusBuf.Bytes())
+	if e.Config.ShowCmd {
+		e.drawPendingCommand()
+	}
 
 	// draw overlay if any
 	if e.overlay != nil {
@@ -278,6 +525,67 @@ func (e *Editor) DrawStatus(text []byte) {
 	e.uiBuf.DrawLabel(r, &lp, text)
 }
 
+// DrawMenu draws the items on the line above the status line, highlighting
+// the selected one. The menu is scrolled so that the selected item is visible.
+func (e *Editor) DrawMenu(items []string, selected int) {
+	r := e.uiBuf.Rect
+	r.Y = r.Height - 2
+	r.Height = 1
+	if r.Y < 0 {
+		return
+	}
+	t := &e.Config.View.Theme
+	lp := tulib.DefaultLabelParams
+	lp.Fg = t.Menu.Fg
+	lp.Bg = t.Menu.Bg
+	e.uiBuf.Fill(r, termbox.Cell{Fg: lp.Fg, Bg: lp.Bg, Ch: ' '})
+
+	// find the first item to draw, keeping the selected one on the line
+	first, width := 0, 0
+	for i := 0; i <= selected && i < len(items); i++ {
+		width += utf8.RuneCountInString(items[i]) + 2
+		for width > r.Width && first < i {
+			width -= utf8.RuneCountInString(items[first]) + 2
+			first++
+		}
+	}
+
+	for i := first; i < len(items) && r.Width > 0; i++ {
+		item := lp
+		if i == selected {
+			item.Fg, item.Bg = t.MenuSelected.Fg, t.MenuSelected.Bg
+		}
+		e.uiBuf.DrawLabel(r, &item, []byte(items[i]))
+		n := utf8.RuneCountInString(items[i]) + 2
+		r.X += n
+		r.Width -= n
+	}
+}
+
+// drawPendingCommand displays the keys of the command being typed in the
+// bottom right corner, keeping the last ones if they don't fit.
+func (e *Editor) drawPendingCommand() {
+	r, ok := e.mode.(CommandReporter)
+	if !ok {
+		return
+	}
+	text := []rune(r.PendingCommand())
+	if len(text) > showCmdWidth {
+		text = text[len(text)-showCmdWidth:]
+	}
+	rect := e.uiBuf.Rect
+	rect.X = rect.Width - showCmdWidth - 1
+	rect.Y = rect.Height - 1
+	rect.Width = showCmdWidth
+	rect.Height = 1
+	if rect.X < 0 {
+		return
+	}
+	lp := tulib.DefaultLabelParams
+	e.uiBuf.Fill(rect, termbox.Cell{Fg: lp.Fg, Bg: lp.Bg, Ch: ' '})
+	e.uiBuf.DrawLabel(rect, &lp, []byte(string(text)))
+}
+
 func (e *Editor) compositeRecursively(v *view.Tree) {
 	if leaf := v.Leaf(); leaf != nil {
 		buf := v.Leaf().UIBuf()
@@ -293,17 +601,21 @@ func (e *Editor) compositeRecursively(v *view.Tree) {
 		splitter.X -= 1
 		splitter.Width = 1
 		uiBuf := e.uiBuf
+		status := e.Config.View.Theme.Status
+		fc := e.Config.View.FillChars
 		uiBuf.Fill(splitter, termbox.Cell{
-			Fg: termbox.AttrReverse,
-			Bg: termbox.AttrReverse,
-			Ch: '│',
+			Fg: status.Fg,
+			Bg: status.Bg,
+			Ch: fc.Vert,
 		})
-		uiBuf.Set(splitter.X, splitter.Y+splitter.Height-1,
-			termbox.Cell{
-				Fg: termbox.AttrReverse,
-				Bg: termbox.AttrReverse,
-				Ch: '┴',
-			})
+		if v.HasStatus() && fc == view.DefaultFillChars {
+			uiBuf.Set(splitter.X, splitter.Y+splitter.Height-1,
+				termbox.Cell{
+					Fg: status.Fg,
+					Bg: status.Bg,
+					Ch: '┴',
+				})
+		}
 	} else {
 		e.compositeRecursively(v.Top())
 		e.compositeRecursively(v.Bottom())
@@ -314,6 +626,9 @@ func (e *Editor) fixEdges(v *view.Tree) {
 	var x, y int
 	var cell *termbox.Cell
 	if leaf := v.Leaf(); leaf != nil {
+		if !v.HasStatus() {
+			return
+		}
 		y = v.Y + v.Height - 1
 		x = v.X - 1
 		cell = e.uiBuf.Get(x, y)
@@ -399,23 +714,48 @@ func (e *Editor) Loop() error {
 			}
 		case command := <-e.Commands:
 			command.Apply(e)
+		case <-e.paste.timeout:
+			for _, ev := range e.flushPaste() {
+				if err := e.handleKey(&ev); err != nil {
+					return err
+				}
+			}
 		case <-e.redraw:
 		}
+		e.autoChdir()
 		e.Draw()
 		termbox.Flush()
 	}
 }
 
+// autoChdir changes the working directory to the one of the file of the
+// active view, when the autochdir option is set and the buffer has a file.
+func (e *Editor) autoChdir() {
+	path := e.ActiveView().Buffer().Path
+	if !e.Config.AutoChdir || path == "" {
+		return
+	}
+	dir := filepath.Dir(path)
+	if dir == e.dir {
+		return
+	}
+	if err := os.Chdir(dir); err != nil {
+		e.SetStatus("%s", err)
+	}
+	// not tried again until another directory is needed
+	e.dir = dir
+}
+
 func (e *Editor) handleUIEvent(ev *termbox.Event) error {
 	switch ev.Type {
 	case termbox.EventKey:
-		e.SetStatus("") // reset status on every key event
-		e.onSysKey(ev)
-		e.mode.OnKey(ev)
-
-		if e.quitFlag {
-			return ErrQuit
+		for _, ev := range e.filterPaste(*ev) {
+			if err := e.handleKey(&ev); err != nil {
+				return err
+			}
 		}
+	case termbox.EventMouse:
+		e.handleMouse(ev)
 	case termbox.EventResize:
 		termbox.Clear(termbox.ColorDefault, termbox.ColorDefault)
 		e.Resize()
@@ -429,6 +769,28 @@ func (e *Editor) handleUIEvent(ev *termbox.Event) error {
 	return nil
 }
 
+func (e *Editor) handleKey(ev *termbox.Event) error {
+	e.SetStatus("") // reset status on every key event
+	e.recordKey(ev)
+	e.onSysKey(ev)
+	if _, ok := e.overlay.(*popup); ok {
+		// the key only hides the popup
+		e.overlay = nil
+		return nil
+	}
+	e.mode.OnKey(ev)
+
+	if e.quitFlag {
+		return ErrQuit
+	}
+	return nil
+}
+
+// Mode returns the active editor mode.
+func (e *Editor) Mode() Mode {
+	return e.mode
+}
+
 // SetMode sets active editor mode.
 // The specified mode instance will react to keys and other user input until
 // another mode is set.
@@ -446,7 +808,26 @@ func (e *Editor) SetMode(m Mode) {
 }
 
 func (e *Editor) viewContext() view.Context {
-	return view.NewContext(e.SetStatus, &e.killBuffer_, &e.buffers)
+	return view.NewContext(e.SetStatus, &e.killBuffer_, &e.buffers, &e.Config.View)
+}
+
+// WriteAll saves the buffers which have unsaved changes, as :w does.
+func (e *Editor) WriteAll() error {
+	for _, buf := range e.buffers {
+		if buf.SyncedWithDisk() {
+			continue
+		}
+		if buf.Path == "" {
+			return fmt.Errorf("no file name for %s", buf.Name)
+		}
+		if err := buf.Save(); err != nil {
+			return err
+		}
+		if err := e.WriteUndoFile(buf); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 func (e *Editor) hasUnsavedBuffers() bool {
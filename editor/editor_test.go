@@ -1,6 +1,17 @@
 package editor
 
-import "testing"
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/kisielk/vigo/buffer"
+	"github.com/nsf/termbox-go"
+	"github.com/nsf/tulib"
+)
 
 func TestValidCutBuffer(t *testing.T) {
 	// 1-9 are the anonymous buffers
@@ -39,6 +50,19 @@ func TestValidCutBuffer(t *testing.T) {
 	}
 }
 
+func TestIsCutBufferName(t *testing.T) {
+	for _, b := range []byte(`"aA1.`) {
+		if !IsCutBufferName(b) {
+			t.Errorf("%q is not a cut buffer name", b)
+		}
+	}
+	for _, b := range []byte("0_@ \xe9") {
+		if IsCutBufferName(b) {
+			t.Errorf("%q is a cut buffer name", b)
+		}
+	}
+}
+
 func TestNamedCutBuffers(t *testing.T) {
 	bufs := newCutBuffers()
 	for i := byte('a'); i <= 'z'; i++ {
@@ -50,7 +74,7 @@ func TestNamedCutBuffers(t *testing.T) {
 
 	in := "foobar"
 	for i := byte('a'); i <= 'z'; i++ {
-		bufs.set(i, []byte(in+string(i)))
+		bufs.set(i, []byte(in+string(i)), false)
 	}
 	for i := byte('a'); i <= 'z'; i++ {
 		expected := in + string(i)
@@ -60,8 +84,8 @@ func TestNamedCutBuffers(t *testing.T) {
 		}
 	}
 
-	bufs.set('a', []byte("foo"))
-	bufs.append('a', []byte("bar"))
+	bufs.set('a', []byte("foo"), false)
+	bufs.append('a', []byte("bar"), false)
 	if out := string(bufs.get('a')); out != "foobar" {
 		t.Logf("after append got %q, want %q", out, "foobar")
 		t.Fail()
@@ -79,7 +103,7 @@ func TestAnonCutBuffers(t *testing.T) {
 
 	in := "hello"
 	for i := byte('9'); i >= '1'; i-- {
-		bufs.updateAnon([]byte(in + string(i)))
+		bufs.updateAnon([]byte(in+string(i)), i%2 == 0)
 	}
 	for i := byte('1'); i <= '9'; i++ {
 		expected := in + string(i)
@@ -87,5 +111,592 @@ func TestAnonCutBuffers(t *testing.T) {
 			t.Logf("%s: got %q, want %q", string(i), out, expected)
 			t.Fail()
 		}
+		if linewise := bufs.isLinewise(i); linewise != (i%2 == 0) {
+			t.Errorf("%s: got linewise %v after rotation", string(i), linewise)
+		}
+	}
+}
+
+func TestConfigSet(t *testing.T) {
+	c := newConfig()
+	if _, err := c.Set("tw=72"); err != nil {
+		t.Fatal(err)
+	}
+	if c.TextWidth != 72 {
+		t.Errorf("bad textwidth: got %d, want 72", c.TextWidth)
+	}
+	for _, arg := range []string{"textwidth", "tw?"} {
+		if s, err := c.Set(arg); err != nil || s != "textwidth=72" {
+			t.Errorf("%s: got %q, %v", arg, s, err)
+		}
+	}
+	for _, arg := range []string{"paste", "invpaste", "paste!"} {
+		if _, err := c.Set(arg); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if !c.Paste {
+		t.Error("paste not set")
+	}
+	if s, _ := c.Set("paste?"); s != "paste" {
+		t.Errorf("bad paste query: got %q", s)
+	}
+	if _, err := c.Set("nopaste"); err != nil || c.Paste {
+		t.Errorf("nopaste failed: %v", err)
+	}
+	for _, arg := range []string{"tw=-1", "tw=x", "notw", "tw!", "foo", "no", "=1", "spk=bogus"} {
+		if _, err := c.Set(arg); err == nil {
+			t.Errorf("%s: expected an error", arg)
+		}
+	}
+	if c.TextWidth != 72 {
+		t.Errorf("textwidth changed by invalid values: %d", c.TextWidth)
+	}
+	if c.View.SplitKeep != "cursor" {
+		t.Errorf("splitkeep changed by an invalid value: %s", c.View.SplitKeep)
+	}
+}
+
+func TestCutBuffer(t *testing.T) {
+	e := &Editor{cutBuffers: newCutBuffers()}
+	e.cutBuffers.updateAnon([]byte("foo"), false)
+	e.cutBuffers.set('a', []byte("bar"), false)
+
+	tests := []struct {
+		name byte
+		want string
+		ok   bool
+	}{
+		{'1', "foo", true},
+		{'"', "foo", true},
+		{'a', "bar", true},
+		{'b', "", true},
+		{'A', "", false},
+	}
+	for _, test := range tests {
+		s, ok := e.CutBuffer(test.name)
+		if string(s) != test.want || ok != test.ok {
+			t.Errorf("%q: got %q, %v, want %q, %v", test.name, s, ok, test.want, test.ok)
+		}
+	}
+}
+
+func TestYank(t *testing.T) {
+	e := &Editor{cutBuffers: newCutBuffers()}
+	e.Yank('"', []byte("foo\n"), true)
+	e.Yank('"', []byte("bar"), false)
+	e.Yank('a', []byte("baz\n"), true)
+	e.Yank('b', []byte("one"), false)
+	e.Yank('B', []byte("two"), true)
+	e.Yank('c', []byte("last line"), true)
+
+	tests := []struct {
+		name     byte
+		want     string
+		linewise bool
+	}{
+		{'"', "bar", false},
+		{'1', "bar", false},
+		{'2', "foo\n", true},
+		{'a', "baz\n", true},
+		{'b', "one\ntwo\n", true},
+		{'c', "last line\n", true},
+	}
+	for _, test := range tests {
+		s, _ := e.CutBuffer(test.name)
+		linewise := e.CutBufferLinewise(test.name)
+		if string(s) != test.want || linewise != test.linewise {
+			t.Errorf("%q: got %q, %v, want %q, %v", test.name, s, linewise, test.want, test.linewise)
+		}
+	}
+}
+
+func TestConfigTabStop(t *testing.T) {
+	c := newConfig()
+	if _, err := c.Set("ts=4"); err != nil {
+		t.Fatal(err)
+	}
+	if c.View.TabStop != 4 {
+		t.Errorf("tabstop not applied: got %d, want 4", c.View.TabStop)
+	}
+	if _, err := c.Set("ts=0"); err == nil {
+		t.Error("expected an error for a zero tabstop")
+	}
+	if c.View.TabStop != 4 {
+		t.Errorf("tabstop changed by invalid value: %d", c.View.TabStop)
+	}
+}
+
+func TestFilterPaste(t *testing.T) {
+	e := &Editor{Commands: make(chan Command, 1)}
+	var events []termbox.Event
+	feed := func(s string) {
+		for _, r := range s {
+			ev := termbox.Event{Type: termbox.EventKey, Ch: r}
+			switch r {
+			case '\x1b':
+				ev = termbox.Event{Type: termbox.EventKey, Key: termbox.KeyEsc}
+			case '\r':
+				ev = termbox.Event{Type: termbox.EventKey, Key: termbox.KeyEnter}
+			}
+			events = append(events, e.filterPaste(ev)...)
+		}
+	}
+
+	feed("i\x1b[200~foo\r\tbar\x1b[201~")
+	if len(events) != 1 || events[0].Ch != 'i' {
+		t.Errorf("bad typed events: %v", events)
+	}
+	select {
+	case c := <-e.Commands:
+		if p, ok := c.(insertPaste); !ok || string(p.text) != "foo\n\tbar" {
+			t.Errorf("bad paste command: %#v", c)
+		}
+	default:
+		t.Error("no paste command")
+	}
+
+	// an escape not followed by a marker is handled as typed
+	events = nil
+	feed("\x1bx\x1b[")
+	events = append(events, e.flushPaste()...)
+	want := []termbox.Key{termbox.KeyEsc, 0, termbox.KeyEsc, 0}
+	if len(events) != len(want) {
+		t.Fatalf("bad number of events: got %d, want %d", len(events), len(want))
+	}
+	for i, ev := range events {
+		if ev.Key != want[i] {
+			t.Errorf("%d: got key %v, want %v", i, ev.Key, want[i])
+		}
+	}
+}
+
+func TestParseKeys(t *testing.T) {
+	events, err := ParseKeys("d<C-w><lt><Esc>x")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []termbox.Event{
+		{Type: termbox.EventKey, Ch: 'd'},
+		{Type: termbox.EventKey, Key: termbox.KeyCtrlW},
+		{Type: termbox.EventKey, Ch: '<'},
+		{Type: termbox.EventKey, Key: termbox.KeyEsc},
+		{Type: termbox.EventKey, Ch: 'x'},
+	}
+	if !reflect.DeepEqual(events, want) {
+		t.Errorf("got %v, want %v", events, want)
+	}
+	if _, err := ParseKeys("<foo>"); err == nil {
+		t.Error("expected an error for an unknown key")
+	}
+}
+
+func TestFormatKeys(t *testing.T) {
+	const keys = "ia<lt>b<Space><CR><C-r>x<Esc>"
+	events, err := ParseKeys(keys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := FormatKeys(events); s != keys {
+		t.Errorf("got %q, want %q", s, keys)
+	}
+}
+
+func TestKeymap(t *testing.T) {
+	k := newKeymap()
+	if err := k.Map("<C-A>", "*"); err != nil {
+		t.Fatal(err)
+	}
+	if err := k.Map("ab", "x"); err == nil {
+		t.Error("expected an error mapping several keys")
+	}
+	ev := termbox.Event{Type: termbox.EventKey, Key: termbox.KeyCtrlA}
+	keys, ok := k.Lookup(&ev)
+	if !ok || len(keys) != 1 || keys[0].Ch != '*' {
+		t.Errorf("bad mapping for Ctrl-A: %v, %v", keys, ok)
+	}
+	if s := k.String(); s != "<C-A> *" {
+		t.Errorf("bad keymap description: %q", s)
+	}
+	if err := k.Unmap("<c-a>"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := k.Lookup(&ev); ok {
+		t.Error("Ctrl-A still mapped")
+	}
+}
+
+func TestRestorePosition(t *testing.T) {
+	dir, err := ioutil.TempDir("", "vigo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	defer os.Setenv("HOME", os.Getenv("HOME"))
+	os.Setenv("HOME", dir)
+
+	path := filepath.Join(dir, "file")
+	if err := ioutil.WriteFile(path, []byte("one\ntwo\nthree\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	e := NewEditor([]string{path})
+	v := e.ActiveView()
+	c := v.Buffer().LineCursor(2)
+	c.Boffset = 1
+	v.MoveCursorTo(c)
+	if err := e.SavePositions(v); err != nil {
+		t.Fatal(err)
+	}
+	v.Detach()
+
+	e = NewEditor([]string{path})
+	v = e.ActiveView()
+	if c := v.Cursor(); c.LineNum != 2 || c.Boffset != 1 {
+		t.Errorf("got cursor at %d:%d, want 2:1", c.LineNum, c.Boffset)
+	}
+	v.Detach()
+
+	// positions out of the file are left out
+	if err := writePositions([]filePosition{{path, 4, 0}}); err != nil {
+		t.Fatal(err)
+	}
+	b := buffer.NewEmptyBuffer()
+	b.Path = path
+	if err := restorePosition(b); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := b.Mark('"'); ok {
+		t.Errorf("mark set for a line out of the file")
+	}
+}
+
+func TestFocusPreviousView(t *testing.T) {
+	e := NewEditor(nil)
+	e.views.SplitVertically()
+	left, right := e.views.Left(), e.views.Right()
+	e.active = left
+
+	e.SetActiveViewNode(right)
+	e.FocusPreviousView()
+	if e.active != left {
+		t.Error("the left view is not active")
+	}
+	e.FocusPreviousView()
+	if e.active != right {
+		t.Error("the right view is not active")
+	}
+
+	// the previous view was closed
+	e.previous = nil
+	e.FocusPreviousView()
+	if e.active != left {
+		t.Error("the other view is not active")
+	}
+}
+
+func TestParseQuickfix(t *testing.T) {
+	output := []byte(`# github.com/kisielk/vigo/editor
+editor/editor.go:12:5: undefined: foo
+main.go:3: syntax error: unexpected newline
+not an error
+Makefile:x: nor this
+/tmp/a b.c:7:1: error: expected ';': got '}'
+`)
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []QuickfixEntry{
+		{filepath.Join(wd, "editor/editor.go"), 12, 5, "undefined: foo"},
+		{filepath.Join(wd, "main.go"), 3, 0, "syntax error: unexpected newline"},
+		{"/tmp/a b.c", 7, 1, "error: expected ';': got '}'"},
+	}
+	if got := ParseQuickfix(output); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestParseGrep(t *testing.T) {
+	output := []byte("a.go:3:10: x := 1\nb.go:12:\tfoo()\n")
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []QuickfixEntry{
+		{filepath.Join(wd, "a.go"), 3, 0, "10: x := 1"},
+		{filepath.Join(wd, "b.go"), 12, 0, "\tfoo()"},
+	}
+	if got := ParseGrep(output); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestLocationList(t *testing.T) {
+	dir, err := ioutil.TempDir("", "vigo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "file")
+	if err := ioutil.WriteFile(path, []byte("one\ntwo\nthree\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	e := NewEditor(nil)
+	e.views.SplitVertically()
+	left, right := e.views.Left(), e.views.Right()
+	e.active = left
+	entries := []QuickfixEntry{{path, 2, 2, "first"}, {path, 3, 0, "second"}}
+	if err := e.SetLocationList(entries, true); err != nil {
+		t.Fatal(err)
+	}
+	if c := e.ActiveView().Cursor(); c.LineNum != 2 || c.Boffset != 1 {
+		t.Errorf("got cursor at %d:%d, want 2:1", c.LineNum, c.Boffset)
+	}
+	if err := e.NextLocation(1); err != nil {
+		t.Fatal(err)
+	}
+	if c := e.ActiveView().Cursor(); c.LineNum != 3 || c.Boffset != 0 {
+		t.Errorf("got cursor at %d:%d, want 3:0", c.LineNum, c.Boffset)
+	}
+	if err := e.NextLocation(1); err == nil {
+		t.Error("jumped past the last location")
+	}
+	// the jumps in the same file are kept
+	if err := e.Jump(-1); err != nil || e.ActiveView().Cursor().LineNum != 2 {
+		t.Errorf("got back to line %d, want 2: %v", e.ActiveView().Cursor().LineNum, err)
+	}
+
+	e.SetActiveViewNode(right)
+	if err := e.NextLocation(-1); err == nil {
+		t.Error("location list shared with another view")
+	}
+	if err := e.NextQuickfix(1); err == nil {
+		t.Error("location list shared with the quickfix list")
+	}
+
+	// a view split from another one gets a copy of its list
+	left.SplitHorizontally()
+	e.SetActiveViewNode(left.Bottom())
+	if err := e.NextLocation(-1); err != nil {
+		t.Fatal(err)
+	}
+	e.SetActiveViewNode(left.Top())
+	if err := e.NextLocation(-1); err != nil {
+		t.Errorf("location list shared with the split view: %v", err)
+	}
+}
+
+func TestKillViewsDetach(t *testing.T) {
+	e := NewEditor(nil)
+	b := e.ActiveView().Buffer()
+	for i := 0; i < 10; i++ {
+		if i%2 == 0 {
+			e.active.SplitHorizontally()
+			e.active = e.active.Bottom()
+		} else {
+			e.active.SplitVertically()
+			e.active = e.active.Left()
+		}
+	}
+	if n := b.NumListeners(); n != 11 {
+		t.Fatalf("got %d listeners after splitting, want 11", n)
+	}
+
+	for i := 0; i < 5; i++ {
+		e.killActiveView()
+	}
+	if n := b.NumListeners(); n != 6 {
+		t.Errorf("got %d listeners after killing 5 views, want 6", n)
+	}
+	e.killAllViewsButActive()
+	if n := b.NumListeners(); n != 1 {
+		t.Errorf("got %d listeners after killing all views but one, want 1", n)
+	}
+	if e.previous != nil {
+		t.Error("the previous view is a killed one")
+	}
+}
+
+func TestQuitView(t *testing.T) {
+	dir, err := ioutil.TempDir("", "vigo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	defer os.Setenv("HOME", os.Getenv("HOME"))
+	os.Setenv("HOME", dir)
+
+	e := NewEditor(nil)
+	e.active.SplitHorizontally()
+	e.active = e.active.Top()
+	b := e.ActiveView().Buffer()
+	b.Insert(b.LineCursor(1), []byte("changed"))
+
+	if err := e.QuitView(false); err != nil || e.quitFlag {
+		t.Fatalf("closing a split view: got error %v, quit %v", err, e.quitFlag)
+	}
+	if e.active.Parent() != nil {
+		t.Fatal("the view wasn't closed")
+	}
+	if err := e.CloseView(); err == nil {
+		t.Error("closed the last view")
+	}
+	if err := e.QuitView(false); err == nil || e.quitFlag {
+		t.Error("quit with unsaved changes")
+	}
+	if err := e.QuitView(true); err != nil || !e.quitFlag {
+		t.Errorf("forcing to quit: got error %v, quit %v", err, e.quitFlag)
+	}
+}
+
+func TestAutoChdir(t *testing.T) {
+	dir, err := ioutil.TempDir("", "vigo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	defer os.Setenv("HOME", os.Getenv("HOME"))
+	os.Setenv("HOME", dir)
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+
+	sub := filepath.Join(dir, "sub")
+	if err := os.Mkdir(sub, 0700); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(sub, "file")
+	if err := ioutil.WriteFile(path, []byte("one\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	e := NewEditor([]string{path})
+	defer e.ActiveView().Detach()
+	e.autoChdir()
+	if got, _ := os.Getwd(); got != wd {
+		t.Errorf("changed directory to %s without autochdir", got)
+	}
+	e.Config.AutoChdir = true
+	e.autoChdir()
+	got, _ := os.Getwd()
+	if want, _ := filepath.EvalSymlinks(sub); got != want {
+		t.Errorf("got working directory %s, want %s", got, want)
+	}
+}
+
+func TestReportLines(t *testing.T) {
+	e := NewEditor(nil)
+	defer e.ActiveView().Detach()
+	for _, test := range []struct {
+		report, n int
+		want      string
+	}{
+		{2, 2, ""},
+		{2, 3, "3 lines changed"},
+		{0, 1, "1 line changed"},
+	} {
+		e.SetStatus("")
+		e.Config.Report = test.report
+		e.ReportLines(test.n, "lines changed")
+		if got := e.statusBuf.String(); got != test.want {
+			t.Errorf("report=%d, %d lines: got %q, want %q", test.report, test.n, got, test.want)
+		}
+	}
+}
+
+func TestJumpList(t *testing.T) {
+	dir, err := ioutil.TempDir("", "vigo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	defer os.Setenv("HOME", os.Getenv("HOME"))
+	os.Setenv("HOME", dir)
+	one, two := filepath.Join(dir, "one"), filepath.Join(dir, "two")
+	for _, path := range []string{one, two} {
+		if err := ioutil.WriteFile(path, []byte("a\nb\nc\n"), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	e := NewEditor([]string{one})
+	defer e.ActiveView().Detach()
+	v := e.ActiveView()
+	e.PushJump()
+	v.MoveCursorToLine(3)
+	if err := e.Edit(two); err != nil {
+		t.Fatal(err)
+	}
+	v.MoveCursorToLine(2)
+
+	check := func(count int, path string, line int) {
+		t.Helper()
+		if err := e.Jump(count); err != nil {
+			t.Fatalf("jump %d: %s", count, err)
+		}
+		if got := v.Buffer().Path; got != path || v.Cursor().LineNum != line {
+			t.Errorf("jump %d: got %s:%d, want %s:%d", count, got, v.Cursor().LineNum, path, line)
+		}
+	}
+	// the positions follow the changes of their buffer
+	first := e.buffers[0]
+	first.Insert(first.LineCursor(1), []byte("new\n"))
+	check(-1, one, 4)
+	check(-1, one, 2)
+	if err := e.Jump(-1); err == nil {
+		t.Error("jumped before the first position")
+	}
+	check(2, two, 2)
+
+	// the file of a closed buffer is opened again
+	closed := e.buffers[1]
+	e.buffers = e.buffers[:1]
+	check(-1, one, 4)
+	check(1, two, 2)
+	if v.Buffer() == closed || !e.hasBuffer(v.Buffer()) {
+		t.Error("the closed buffer wasn't opened again")
+	}
+}
+
+func TestPopup(t *testing.T) {
+	view := tulib.Rect{X: 10, Y: 2, Width: 40, Height: 20}
+	for _, test := range []struct {
+		cy, height int
+		want       tulib.Rect
+	}{
+		// below the cursor row
+		{2, 8, tulib.Rect{10, 3, 40, 8}},
+		// above it, when there is more room there
+		{18, 8, tulib.Rect{10, 10, 40, 8}},
+		// cut to the rows left
+		{5, 18, tulib.Rect{10, 6, 40, 16}},
+		{18, 18, tulib.Rect{10, 2, 40, 16}},
+	} {
+		if got := findPlaceForRect(view, test.cy, test.height); got != test.want {
+			t.Errorf("cursor row %d, %d rows: got %v, want %v", test.cy, test.height, got, test.want)
+		}
+	}
+
+	b, err := buffer.NewBuffer(strings.NewReader("a\nb\nc\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := previewLines(b.LineCursor(2), 5)
+	if want := [][]byte{[]byte("b"), []byte("c"), []byte("")}; !reflect.DeepEqual(lines, want) {
+		t.Errorf("got preview lines %q, want %q", lines, want)
+	}
+	if lines := previewLines(b.LineCursor(1), 2); len(lines) != 2 {
+		t.Errorf("got %d preview lines, want 2", len(lines))
+	}
+
+	e := NewEditor(nil)
+	defer e.ActiveView().Detach()
+	e.ShowPopup("title", lines)
+	if err := e.handleKey(&termbox.Event{Type: termbox.EventKey, Ch: 'x'}); err != nil || e.overlay != nil {
+		t.Errorf("the key didn't hide the popup: %v", err)
 	}
 }
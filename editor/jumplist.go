@@ -0,0 +1,136 @@
+package editor
+
+import (
+	"errors"
+
+	"github.com/kisielk/vigo/buffer"
+)
+
+// maxJumps is the number of positions kept in the jump list.
+const maxJumps = 100
+
+// jump is a position of the jump list, which follows the changes of its
+// buffer. The path of the buffer is kept to open the file again if the buffer
+// was closed.
+type jump struct {
+	buf  *buffer.Buffer
+	path string
+	pos  *buffer.Cursor
+}
+
+// newJump returns a jump to the position c of the buffer b, tracked by it.
+func newJump(b *buffer.Buffer, c buffer.Cursor) jump {
+	j := jump{b, b.Path, &c}
+	b.TrackCursor(j.pos)
+	return j
+}
+
+// drop stops tracking the position of the jump, which is left.
+func (j jump) drop() {
+	j.buf.UntrackCursor(j.pos)
+}
+
+// jumpList holds the positions left by jumps, such as searches, G or opening
+// another file, which Ctrl-O and Ctrl-I go back and forth through.
+type jumpList struct {
+	jumps   []jump
+	current int // index of the position jumped to, len(jumps) after a new jump
+}
+
+// PushJump adds the cursor position of the active view to the jump list,
+// before the cursor jumps elsewhere. An older position on the same line is
+// removed.
+func (e *Editor) PushJump() {
+	l := &e.jumps
+	v := e.ActiveView()
+	j := newJump(v.Buffer(), v.Cursor())
+
+	jumps := l.jumps[:0]
+	for _, old := range l.jumps {
+		if old.buf != j.buf || old.pos.LineNum != j.pos.LineNum {
+			jumps = append(jumps, old)
+		} else {
+			old.drop()
+		}
+	}
+	jumps = append(jumps, j)
+	if n := len(jumps) - maxJumps; n > 0 {
+		for _, old := range jumps[:n] {
+			old.drop()
+		}
+		jumps = jumps[n:]
+	}
+	l.jumps = jumps
+	l.current = len(jumps)
+}
+
+// Jump moves the cursor count positions forward in the jump list, or back if
+// count is negative, showing the buffer of the position in the active view.
+// The file of a buffer which was closed is opened again.
+func (e *Editor) Jump(count int) error {
+	l := &e.jumps
+	if count < 0 && l.current == len(l.jumps) {
+		// keep the position left, for Ctrl-I to come back to it
+		e.PushJump()
+		l.current--
+	}
+	n := l.current + count
+	if n < 0 || n >= len(l.jumps) {
+		return errors.New("no more jumps")
+	}
+
+	j := l.jumps[n]
+	b := j.buf
+	if !e.hasBuffer(b) {
+		if j.path == "" {
+			l.jumps = append(l.jumps[:n], l.jumps[n+1:]...)
+			return errors.New("buffer was closed")
+		}
+		var err error
+		if b, err = e.NewBufferFromFile(j.path); err != nil {
+			return err
+		}
+		// move all the positions of the file to the new buffer
+		for i, old := range l.jumps {
+			if old.buf == j.buf {
+				l.jumps[i] = newJump(b, lineCursor(b, old.pos.LineNum, old.pos.Boffset))
+			}
+		}
+		j = l.jumps[n]
+	}
+	l.current = n
+
+	v := e.ActiveView()
+	if v.Buffer() != b {
+		if err := e.SavePositions(v); err != nil {
+			e.SetStatus("%s", err)
+		}
+		v.Attach(b)
+	}
+	v.MoveCursorTo(*j.pos)
+	return nil
+}
+
+// lineCursor returns a cursor at the byte offset boffset of the line n of the
+// buffer b, moved to the end of the line or of the buffer if they are before.
+func lineCursor(b *buffer.Buffer, n, boffset int) buffer.Cursor {
+	if n > b.NumLines {
+		n = b.NumLines
+	}
+	c := b.LineCursor(n)
+	c.Boffset = boffset
+	if c.Boffset > len(c.Line.Data) {
+		c.Boffset = len(c.Line.Data)
+	}
+	return c
+}
+
+// hasBuffer reports whether b is one of the open buffers.
+func (e *Editor) hasBuffer(b *buffer.Buffer) bool {
+	for _, buf := range e.buffers {
+		if buf == b {
+			return true
+		}
+	}
+	return false
+}
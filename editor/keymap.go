@@ -0,0 +1,173 @@
+package editor
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/nsf/termbox-go"
+)
+
+// Keymap maps single keys to the sequences of keys they are replaced with,
+// as set by the :nmap command.
+type Keymap struct {
+	keys map[keyEvent]mapping
+}
+
+type mapping struct {
+	lhs, rhs string // as given to Map
+	events   []termbox.Event
+}
+
+func newKeymap() *Keymap {
+	return &Keymap{keys: make(map[keyEvent]mapping)}
+}
+
+// Map replaces the key lhs with the keys rhs, both written in the notation
+// understood by ParseKeys.
+func (k *Keymap) Map(lhs, rhs string) error {
+	key, err := parseSingleKey(lhs)
+	if err != nil {
+		return err
+	}
+	events, err := ParseKeys(rhs)
+	if err != nil {
+		return err
+	}
+	k.keys[key] = mapping{lhs, rhs, events}
+	return nil
+}
+
+// Unmap removes the mapping of the key lhs.
+func (k *Keymap) Unmap(lhs string) error {
+	key, err := parseSingleKey(lhs)
+	if err != nil {
+		return err
+	}
+	if _, ok := k.keys[key]; !ok {
+		return fmt.Errorf("no such mapping: %s", lhs)
+	}
+	delete(k.keys, key)
+	return nil
+}
+
+// Lookup returns the keys replacing the key of the event ev, if it is mapped.
+func (k *Keymap) Lookup(ev *termbox.Event) ([]termbox.Event, bool) {
+	m, ok := k.keys[createKeyEvent(ev)]
+	return m.events, ok
+}
+
+// String returns all the mappings, sorted by key.
+func (k *Keymap) String() string {
+	var s []string
+	for _, m := range k.keys {
+		s = append(s, m.lhs+" "+m.rhs)
+	}
+	sort.Strings(s)
+	return strings.Join(s, ", ")
+}
+
+// specialKeys are the names of the keys written <name> by ParseKeys.
+var specialKeys = map[string]termbox.Key{
+	"bs":    termbox.KeyBackspace2,
+	"cr":    termbox.KeyEnter,
+	"del":   termbox.KeyDelete,
+	"down":  termbox.KeyArrowDown,
+	"end":   termbox.KeyEnd,
+	"enter": termbox.KeyEnter,
+	"esc":   termbox.KeyEsc,
+	"home":  termbox.KeyHome,
+	"left":  termbox.KeyArrowLeft,
+	"right": termbox.KeyArrowRight,
+	"space": termbox.KeySpace,
+	"tab":   termbox.KeyTab,
+	"up":    termbox.KeyArrowUp,
+}
+
+// ParseKeys parses a sequence of keys. Keys are written as themselves,
+// except for <C-x> for a control key, <lt> for <, and names such as <Esc>,
+// <CR> or <Space> for special keys. Names are case insensitive.
+func ParseKeys(s string) ([]termbox.Event, error) {
+	var events []termbox.Event
+	for len(s) > 0 {
+		if s[0] == '<' {
+			if i := strings.IndexByte(s, '>'); i > 1 {
+				k, err := parseKeyName(s[1:i])
+				if err != nil {
+					return nil, err
+				}
+				events = append(events, k.toTermboxEvent())
+				s = s[i+1:]
+				continue
+			}
+		}
+		r, n := utf8.DecodeRuneInString(s)
+		events = append(events, termbox.Event{Type: termbox.EventKey, Ch: r})
+		s = s[n:]
+	}
+	return events, nil
+}
+
+// keyNames are the names used by FormatKeys for special keys.
+var keyNames = map[termbox.Key]string{
+	termbox.KeyBackspace2: "BS",
+	termbox.KeyEnter:      "CR",
+	termbox.KeyDelete:     "Del",
+	termbox.KeyArrowDown:  "Down",
+	termbox.KeyEnd:        "End",
+	termbox.KeyEsc:        "Esc",
+	termbox.KeyHome:       "Home",
+	termbox.KeyArrowLeft:  "Left",
+	termbox.KeyArrowRight: "Right",
+	termbox.KeySpace:      "Space",
+	termbox.KeyTab:        "Tab",
+	termbox.KeyArrowUp:    "Up",
+}
+
+// FormatKeys returns the key events as understood by ParseKeys. Keys which
+// can't be written, such as function keys, are left out.
+func FormatKeys(events []termbox.Event) string {
+	var buf bytes.Buffer
+	for _, ev := range events {
+		switch {
+		case ev.Ch == '<':
+			buf.WriteString("<lt>")
+		case ev.Ch != 0:
+			buf.WriteRune(ev.Ch)
+		case keyNames[ev.Key] != "":
+			fmt.Fprintf(&buf, "<%s>", keyNames[ev.Key])
+		case termbox.KeyCtrlA <= ev.Key && ev.Key <= termbox.KeyCtrlZ:
+			fmt.Fprintf(&buf, "<C-%c>", 'a'+rune(ev.Key-termbox.KeyCtrlA))
+		}
+	}
+	return buf.String()
+}
+
+func parseKeyName(name string) (keyEvent, error) {
+	lower := strings.ToLower(name)
+	if lower == "lt" {
+		return keyEvent{ch: '<'}, nil
+	}
+	if k, ok := specialKeys[lower]; ok {
+		return keyEvent{key: k}, nil
+	}
+	if strings.HasPrefix(lower, "c-") && len(lower) == 3 {
+		if c := lower[2]; 'a' <= c && c <= 'z' {
+			return keyEvent{key: termbox.KeyCtrlA + termbox.Key(c-'a')}, nil
+		}
+	}
+	return keyEvent{}, fmt.Errorf("unknown key: <%s>", name)
+}
+
+func parseSingleKey(s string) (keyEvent, error) {
+	events, err := ParseKeys(s)
+	if err != nil {
+		return keyEvent{}, err
+	}
+	if len(events) != 1 {
+		return keyEvent{}, fmt.Errorf("only single keys can be mapped: %s", s)
+	}
+	return createKeyEvent(&events[0]), nil
+}
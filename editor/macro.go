@@ -0,0 +1,115 @@
+package editor
+
+import (
+	"fmt"
+
+	"github.com/nsf/termbox-go"
+)
+
+// maxMacroDepth limits the nesting of macros executing other macros, which
+// could otherwise run forever.
+const maxMacroDepth = 100
+
+// macros holds the state of the recording and execution of keyboard macros.
+// Macros are stored as text in the cut buffers, in the notation of
+// FormatKeys, so they can be viewed and edited like any other text.
+type macros struct {
+	recording byte // name of the cut buffer being recorded, or 0
+	keys      []termbox.Event
+	last      byte // last executed cut buffer, for @@
+	depth     int  // number of macros being executed
+}
+
+// StartRecording starts recording the typed keys into the cut buffer b.
+func (e *Editor) StartRecording(b byte) error {
+	if !isCutBuffer(b) || b == '.' {
+		return fmt.Errorf("invalid cut buffer: %c", b)
+	}
+	e.macros.recording = b
+	e.macros.keys = nil
+	e.SetStatus("recording @%c", b)
+	return nil
+}
+
+// Recording reports whether keys are being recorded.
+func (e *Editor) Recording() bool {
+	return e.macros.recording != 0
+}
+
+// StopRecording stores the keys recorded so far in the cut buffer given to
+// StartRecording. The last key is the one stopping the recording, and is
+// left out.
+func (e *Editor) StopRecording() {
+	m := &e.macros
+	keys := m.keys
+	if len(keys) > 0 {
+		keys = keys[:len(keys)-1]
+	}
+	e.Yank(m.recording, []byte(FormatKeys(keys)), false)
+	m.recording = 0
+	m.keys = nil
+}
+
+// recordKey adds the key event ev to the macro being recorded, if any.
+func (e *Editor) recordKey(ev *termbox.Event) {
+	if e.macros.recording != 0 {
+		e.macros.keys = append(e.macros.keys, *ev)
+	}
+}
+
+// ExecuteMacro handles the keys stored in the cut buffer b count times, as if
+// they were typed. The name @ refers to the last executed cut buffer.
+func (e *Editor) ExecuteMacro(b byte, count int) error {
+	m := &e.macros
+	if b == '@' {
+		if m.last == 0 {
+			return fmt.Errorf("no previous macro")
+		}
+		b = m.last
+	}
+	s, ok := e.CutBuffer(b)
+	if !ok {
+		return fmt.Errorf("invalid cut buffer: %c", b)
+	}
+	keys, err := ParseKeys(string(s))
+	if err != nil {
+		return err
+	}
+	m.last = b
+
+	for i := 0; i < count; i++ {
+		if err := e.ExecuteKeys(keys); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ExecuteKeys handles the key events as if they were typed, in the active
+// mode and the modes they switch to.
+func (e *Editor) ExecuteKeys(keys []termbox.Event) error {
+	m := &e.macros
+	if m.depth >= maxMacroDepth {
+		return fmt.Errorf("macros nested too deeply")
+	}
+	m.depth++
+	defer func() { m.depth-- }()
+	for i := range keys {
+		e.mode.OnKey(&keys[i])
+		// the next keys may depend on the effect of this one
+		e.applyCommands()
+	}
+	return nil
+}
+
+// applyCommands applies the queued commands.
+func (e *Editor) applyCommands() {
+	for {
+		select {
+		case c := <-e.Commands:
+			c.Apply(e)
+		default:
+			return
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package editor
+
+import (
+	"strings"
+
+	"github.com/nsf/termbox-go"
+)
+
+// mouseScrollLines is the number of lines scrolled by a mouse wheel event.
+const mouseScrollLines = 3
+
+// Dragger is implemented by modes which select text when the mouse is dragged.
+type Dragger interface {
+	// StartDrag is called when the mouse starts being dragged, with the
+	// cursor where the mouse button was pressed.
+	StartDrag()
+}
+
+// mouseState tracks the left mouse button between a press and its release.
+type mouseState struct {
+	down     bool
+	dragging bool
+	x, y     int // position of the last event
+}
+
+// SetInputMode sets the input mode of the terminal, reporting mouse events
+// only when the mouse option enables them.
+func (e *Editor) SetInputMode() {
+	mode := termbox.InputEsc
+	if e.mouseEnabled() {
+		mode |= termbox.InputMouse
+	}
+	termbox.SetInputMode(mode)
+}
+
+func (e *Editor) mouseEnabled() bool {
+	return strings.ContainsRune(e.Config.Mouse, 'a')
+}
+
+// handleMouse focuses the view under the mouse pointer and moves its cursor
+// to the pointed character on a click, selects text on a drag, or scrolls the
+// view with the wheel.
+func (e *Editor) handleMouse(ev *termbox.Event) {
+	if !e.mouseEnabled() || e.overlay != nil {
+		return
+	}
+	if ev.Key == termbox.MouseRelease {
+		e.mouse = mouseState{}
+		return
+	}
+	if ev.Key == termbox.MouseLeft && e.mouse.down {
+		if ev.MouseX != e.mouse.x || ev.MouseY != e.mouse.y {
+			e.dragMouse(ev)
+		}
+		return
+	}
+
+	node := e.views.LeafAt(ev.MouseX, ev.MouseY)
+	if node == nil {
+		return
+	}
+	v := node.Leaf()
+	switch ev.Key {
+	case termbox.MouseLeft:
+		e.mouse = mouseState{down: true, x: ev.MouseX, y: ev.MouseY}
+		e.SetActiveViewNode(node)
+		v.MoveCursorToPosition(ev.MouseX-node.X, ev.MouseY-node.Y)
+	case termbox.MouseWheelUp:
+		v.MoveViewLines(-mouseScrollLines)
+	case termbox.MouseWheelDown:
+		v.MoveViewLines(mouseScrollLines)
+	}
+}
+
+// dragMouse extends the selection in the active view to the mouse pointer,
+// scrolling the view when the pointer is past its top or bottom edge.
+func (e *Editor) dragMouse(ev *termbox.Event) {
+	e.mouse.x, e.mouse.y = ev.MouseX, ev.MouseY
+	if !e.mouse.dragging {
+		e.mouse.dragging = true
+		if d, ok := e.mode.(Dragger); ok {
+			d.StartDrag()
+		}
+	}
+
+	node := e.active
+	v := node.Leaf()
+	x, y := ev.MouseX-node.X, ev.MouseY-node.Y
+	h := node.Height
+	if node.HasStatus() {
+		// the last line of the view is its status bar
+		h--
+	}
+	switch {
+	case y < 0:
+		v.MoveViewLines(-1)
+		y = 0
+	case y >= h:
+		v.MoveViewLines(1)
+		y = h - 1
+	}
+	if x < 0 {
+		x = 0
+	}
+	v.MoveCursorToPosition(x, y)
+}
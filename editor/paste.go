@@ -0,0 +1,134 @@
+package editor
+
+import (
+	"os"
+	"time"
+	"unicode/utf8"
+
+	"github.com/nsf/termbox-go"
+)
+
+// Sequences sent by the terminal around pasted text in bracketed paste mode.
+const (
+	pasteStart = "\x1b[200~"
+	pasteEnd   = "\x1b[201~"
+)
+
+// pasteTimeout is how long to wait for the rest of a paste marker before
+// handling the keys received so far as typed ones.
+const pasteTimeout = 25 * time.Millisecond
+
+// EnableBracketedPaste asks the terminal to mark the start and the end of
+// pasted text, so it can be inserted verbatim.
+func EnableBracketedPaste() {
+	os.Stdout.WriteString("\x1b[?2004h")
+}
+
+// DisableBracketedPaste restores the normal paste behaviour of the terminal.
+func DisableBracketedPaste() {
+	os.Stdout.WriteString("\x1b[?2004l")
+}
+
+// bracketedPaste holds the state of a paste in the terminal. termbox reports
+// the markers as an escape key followed by the rest of the marker as runes.
+type bracketedPaste struct {
+	pending []termbox.Event  // events matching the beginning of a marker
+	timeout <-chan time.Time // set while there are pending events
+	active  bool             // between the start and the end markers
+	text    []byte           // pasted text received so far
+}
+
+// filterPaste consumes the key event ev if it is part of a paste marker or of
+// pasted text. It returns the events which must be handled as typed keys.
+func (e *Editor) filterPaste(ev termbox.Event) []termbox.Event {
+	p := &e.paste
+	marker := pasteStart
+	if p.active {
+		marker = pasteEnd
+	}
+
+	if isMarkerByte(ev, marker[len(p.pending)]) {
+		p.pending = append(p.pending, ev)
+		if len(p.pending) < len(marker) {
+			if p.timeout == nil {
+				p.timeout = time.After(pasteTimeout)
+			}
+			return nil
+		}
+		p.pending, p.timeout = nil, nil
+		if p.active {
+			// queued after the commands of the keys typed before
+			e.Commands <- insertPaste{p.text}
+			p.text = nil
+		}
+		p.active = !p.active
+		return nil
+	}
+
+	if len(p.pending) > 0 {
+		// not a marker after all, ev may start a new one though
+		return append(e.flushPaste(), e.filterPaste(ev)...)
+	}
+	if p.active {
+		p.text = appendKey(p.text, ev)
+		return nil
+	}
+	return []termbox.Event{ev}
+}
+
+// flushPaste gives up on the pending events forming a paste marker, and
+// returns the ones which must be handled as typed keys.
+func (e *Editor) flushPaste() []termbox.Event {
+	p := &e.paste
+	events := p.pending
+	p.pending, p.timeout = nil, nil
+	if p.active {
+		for _, ev := range events {
+			p.text = appendKey(p.text, ev)
+		}
+		return nil
+	}
+	return events
+}
+
+// insertPaste inserts the pasted text at the cursor as a single undo step.
+type insertPaste struct {
+	text []byte
+}
+
+func (p insertPaste) Apply(e *Editor) {
+	if len(p.text) == 0 {
+		return
+	}
+	v := e.ActiveView()
+	b := v.Buffer()
+	b.FinalizeActionGroup()
+	b.Insert(v.Cursor(), p.text)
+	b.FinalizeActionGroup()
+}
+
+func isMarkerByte(ev termbox.Event, b byte) bool {
+	if b == '\x1b' {
+		return ev.Ch == 0 && ev.Key == termbox.KeyEsc
+	}
+	return ev.Ch == rune(b)
+}
+
+// appendKey appends the text of the key event ev to s.
+func appendKey(s []byte, ev termbox.Event) []byte {
+	switch {
+	case ev.Ch != 0:
+		var buf [utf8.UTFMax]byte
+		n := utf8.EncodeRune(buf[:], ev.Ch)
+		return append(s, buf[:n]...)
+	case ev.Key == termbox.KeyEnter:
+		// terminals send a carriage return for line breaks
+		return append(s, '\n')
+	case ev.Key == termbox.KeySpace:
+		return append(s, ' ')
+	case ev.Key < utf8.RuneSelf:
+		// other control characters, including tabs
+		return append(s, byte(ev.Key))
+	}
+	return s
+}
@@ -0,0 +1,119 @@
+package editor
+
+import (
+	"bytes"
+
+	"github.com/kisielk/vigo/buffer"
+	"github.com/nsf/termbox-go"
+	"github.com/nsf/tulib"
+)
+
+// popup is an overlay showing a few lines of text in a box over the active
+// view, such as the preview of a declaration, until the next key is typed.
+type popup struct {
+	editor *Editor
+	title  string
+	lines  [][]byte
+}
+
+// ShowPopup shows the lines below a title in a box next to the cursor, over
+// the active view. The next key hides it, and does nothing else.
+func (e *Editor) ShowPopup(title string, lines [][]byte) {
+	e.overlay = &popup{editor: e, title: title, lines: lines}
+}
+
+func (p *popup) NeedsCursor() bool {
+	return false
+}
+
+func (p *popup) CursorPosition() (int, int) {
+	return 0, 0
+}
+
+func (p *popup) OnResize(ev *termbox.Event) {
+}
+
+func (p *popup) Draw() {
+	e := p.editor
+	_, cy := e.CursorPosition()
+	r := findPlaceForRect(e.active.Rect, cy, len(p.lines)+1)
+	if r.IsEmpty() {
+		return
+	}
+	t := &e.Config.View.Theme
+
+	lp := tulib.DefaultLabelParams
+	lp.Fg, lp.Bg = t.StatusName.Fg, t.StatusName.Bg
+	row := r
+	row.Height = 1
+	e.uiBuf.Fill(row, termbox.Cell{Fg: lp.Fg, Bg: lp.Bg, Ch: ' '})
+	e.uiBuf.DrawLabel(row, &lp, []byte(p.title))
+
+	lp.Fg, lp.Bg = t.Menu.Fg, t.Menu.Bg
+	for _, line := range p.lines {
+		row.Y++
+		if row.Y >= r.Y+r.Height {
+			break
+		}
+		e.uiBuf.Fill(row, termbox.Cell{Fg: lp.Fg, Bg: lp.Bg, Ch: ' '})
+		e.uiBuf.DrawLabel(row, &lp, expandTabs(line, e.Config.View.TabStop))
+	}
+}
+
+// previewHeight is the number of lines shown by ShowPreview.
+const previewHeight = 5
+
+// ShowPreview shows the lines starting at the line of the cursor c in a
+// popup, as ShowPopup does.
+func (e *Editor) ShowPreview(title string, c buffer.Cursor) {
+	e.ShowPopup(title, previewLines(c, previewHeight))
+}
+
+// previewLines returns up to n lines starting at the line of the cursor c.
+func previewLines(c buffer.Cursor, n int) [][]byte {
+	var lines [][]byte
+	for l := c.Line; l != nil && len(lines) < n; l = l.Next {
+		lines = append(lines, l.Data)
+	}
+	return lines
+}
+
+// findPlaceForRect returns the rectangle of height rows spanning the area of
+// a view below the row cy of its cursor, or above it if there is no room
+// below. It is cut to the rows of the view if they are not enough.
+func findPlaceForRect(view tulib.Rect, cy, height int) tulib.Rect {
+	r := tulib.Rect{X: view.X, Y: cy + 1, Width: view.Width, Height: height}
+	if below := view.Y + view.Height - r.Y; below < height {
+		above := cy - view.Y
+		if above > below {
+			if height > above {
+				height = above
+			}
+			r.Y, r.Height = cy-height, height
+		} else {
+			r.Height = below
+		}
+	}
+	return r
+}
+
+// expandTabs replaces the tabs of line with the spaces up to the next tab
+// stop, tabstop cells apart, for drawing it as a label.
+func expandTabs(line []byte, tabstop int) []byte {
+	if bytes.IndexByte(line, '\t') == -1 {
+		return line
+	}
+	var buf bytes.Buffer
+	col := 0
+	for _, r := range string(line) {
+		if r == '\t' {
+			n := tabstop - col%tabstop
+			buf.Write(bytes.Repeat([]byte{' '}, n))
+			col += n
+			continue
+		}
+		buf.WriteRune(r)
+		col++
+	}
+	return buf.Bytes()
+}
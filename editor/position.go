@@ -0,0 +1,163 @@
+package editor
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/kisielk/vigo/buffer"
+	"github.com/kisielk/vigo/view"
+)
+
+// maxPositions is the number of files whose last cursor position is
+// remembered.
+const maxPositions = 100
+
+// filePosition is the cursor position in a file when it was last left.
+type filePosition struct {
+	path          string
+	line, boffset int
+}
+
+// positionsFilePath returns the path of the file holding the last cursor
+// positions in files, ~/.vigo/positions.
+func positionsFilePath() (string, error) {
+	home := os.Getenv("HOME")
+	if home == "" {
+		return "", errors.New("HOME is not set")
+	}
+	return filepath.Join(home, ".vigo", "positions"), nil
+}
+
+// readPositions returns the positions saved by writePositions, the most
+// recent first. Lines which can't be parsed are skipped.
+func readPositions() ([]filePosition, error) {
+	path, err := positionsFilePath()
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var positions []filePosition
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		// line, byte offset and path, which may contain spaces
+		fields := strings.SplitN(s.Text(), " ", 3)
+		if len(fields) != 3 {
+			continue
+		}
+		line, err := strconv.Atoi(fields[0])
+		if err != nil {
+			continue
+		}
+		boffset, err := strconv.Atoi(fields[1])
+		if err != nil {
+			continue
+		}
+		positions = append(positions, filePosition{fields[2], line, boffset})
+	}
+	return positions, s.Err()
+}
+
+// writePositions saves the positions, one per line.
+func writePositions(positions []filePosition) error {
+	path, err := positionsFilePath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	for _, p := range positions {
+		fmt.Fprintf(w, "%d %d %s\n", p.line, p.boffset, p.path)
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// SavePositions remembers the cursor positions of the views in their files,
+// to restore them when the files are opened again.
+func (e *Editor) SavePositions(views ...*view.View) error {
+	var saved []filePosition
+	for _, v := range views {
+		b := v.Buffer()
+		if b.Path == "" {
+			continue
+		}
+		c := v.Cursor()
+		saved = append(saved, filePosition{b.Path, c.LineNum, c.Boffset})
+	}
+	if len(saved) == 0 {
+		return nil
+	}
+
+	positions, err := readPositions()
+	if err != nil {
+		return err
+	}
+	for _, p := range positions {
+		if len(saved) == maxPositions {
+			break
+		}
+		if !hasPosition(saved, p.path) {
+			saved = append(saved, p)
+		}
+	}
+	return writePositions(saved)
+}
+
+func hasPosition(positions []filePosition, path string) bool {
+	for _, p := range positions {
+		if p.path == path {
+			return true
+		}
+	}
+	return false
+}
+
+// restorePosition sets the mark " of the buffer b to the cursor position in
+// its file when it was last left, if the position is still in the file.
+func restorePosition(b *buffer.Buffer) error {
+	positions, err := readPositions()
+	if err != nil {
+		return err
+	}
+	for _, p := range positions {
+		if p.path != b.Path {
+			continue
+		}
+		c := b.LineCursor(p.line)
+		if c.LineNum == p.line && p.boffset <= len(c.Line.Data) {
+			c.Boffset = p.boffset
+			b.SetMark('"', c)
+		}
+		return nil
+	}
+	return nil
+}
+
+// restoreCursor moves the cursor of the view v to the mark " of its buffer,
+// if it is set.
+func restoreCursor(v *view.View) {
+	if c, ok := v.Buffer().Mark('"'); ok {
+		v.MoveCursorTo(c)
+	}
+}
@@ -0,0 +1,238 @@
+package editor
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/kisielk/vigo/buffer"
+	"github.com/kisielk/vigo/view"
+)
+
+// QuickfixEntry is a location in a file of the quickfix list, such as an
+// error reported by a build command.
+type QuickfixEntry struct {
+	Path string
+	Line int
+	Col  int // 0 if the location has no column
+	Text string
+}
+
+func (q QuickfixEntry) String() string {
+	if q.Col == 0 {
+		return fmt.Sprintf("%s:%d: %s", q.Path, q.Line, q.Text)
+	}
+	return fmt.Sprintf("%s:%d:%d: %s", q.Path, q.Line, q.Col, q.Text)
+}
+
+// quickfixList holds the entries of the quickfix list, or of the location
+// list of a view, and the one jumped to.
+type quickfixList struct {
+	name    string // of the buffer showing the list
+	entries []QuickfixEntry
+	current int
+}
+
+// Copy returns a copy of the list, as the location list of a split view.
+func (l *quickfixList) Copy() view.LocationList {
+	c := *l
+	return &c
+}
+
+// ParseQuickfix returns the locations of the lines of output written as
+// file:line:col: message or file:line: message, as printed by compilers.
+// Other lines are skipped. Relative paths are taken from the working
+// directory.
+func ParseQuickfix(output []byte) []QuickfixEntry {
+	return parseQuickfix(output, true)
+}
+
+// ParseGrep returns the locations of the lines of output written as
+// file:line:text, as printed by grep -n. The text is never taken for a
+// column.
+func ParseGrep(output []byte) []QuickfixEntry {
+	return parseQuickfix(output, false)
+}
+
+func parseQuickfix(output []byte, columns bool) []QuickfixEntry {
+	var entries []QuickfixEntry
+	s := bufio.NewScanner(bytes.NewReader(output))
+	for s.Scan() {
+		if q, ok := parseQuickfixLine(s.Text(), columns); ok {
+			entries = append(entries, q)
+		}
+	}
+	return entries
+}
+
+func parseQuickfixLine(s string, columns bool) (QuickfixEntry, bool) {
+	fields := strings.SplitN(s, ":", 4)
+	if len(fields) < 3 || fields[0] == "" {
+		return QuickfixEntry{}, false
+	}
+	// the working directory changes with autochdir
+	path, err := filepath.Abs(fields[0])
+	if err != nil {
+		return QuickfixEntry{}, false
+	}
+	q := QuickfixEntry{Path: path}
+	if q.Line, err = strconv.Atoi(fields[1]); err != nil || q.Line < 1 {
+		return QuickfixEntry{}, false
+	}
+	q.Text = strings.Join(fields[2:], ":")
+	if columns && len(fields) == 4 {
+		if col, err := strconv.Atoi(fields[2]); err == nil && col > 0 {
+			q.Col, q.Text = col, fields[3]
+		}
+	}
+	if columns {
+		q.Text = strings.TrimSpace(q.Text)
+	}
+	return q, true
+}
+
+// SetQuickfix replaces the quickfix list with entries. It jumps to the first
+// one if jump is set, and there is any.
+func (e *Editor) SetQuickfix(entries []QuickfixEntry, jump bool) error {
+	return e.setList(&e.quickfix, entries, jump)
+}
+
+// JumpQuickfix opens the file of the entry n of the quickfix list in the
+// active view, with the cursor at its location.
+func (e *Editor) JumpQuickfix(n int) error {
+	return e.jumpList(&e.quickfix, n)
+}
+
+// NextQuickfix jumps to the entry of the quickfix list count entries after
+// the current one, or before it if count is negative.
+func (e *Editor) NextQuickfix(count int) error {
+	return e.jumpList(&e.quickfix, e.quickfix.current+count)
+}
+
+// OpenQuickfix shows the quickfix list in a new view below the active one.
+func (e *Editor) OpenQuickfix() error {
+	return e.openList(&e.quickfix)
+}
+
+// SetLocationList replaces the location list of the active view with
+// entries. It jumps to the first one if jump is set, and there is any.
+func (e *Editor) SetLocationList(entries []QuickfixEntry, jump bool) error {
+	l := &quickfixList{name: "[Location List]"}
+	e.ActiveView().SetLocationList(l)
+	return e.setList(l, entries, jump)
+}
+
+// locationList returns the location list of the active view.
+func (e *Editor) locationList() (*quickfixList, error) {
+	l, ok := e.ActiveView().LocationList().(*quickfixList)
+	if !ok {
+		return nil, errors.New("no location list")
+	}
+	return l, nil
+}
+
+// JumpLocation opens the file of the entry n of the location list of the
+// active view, with the cursor at its location.
+func (e *Editor) JumpLocation(n int) error {
+	l, err := e.locationList()
+	if err != nil {
+		return err
+	}
+	return e.jumpList(l, n)
+}
+
+// NextLocation jumps to the entry of the location list of the active view
+// count entries after the current one, or before it if count is negative.
+func (e *Editor) NextLocation(count int) error {
+	l, err := e.locationList()
+	if err != nil {
+		return err
+	}
+	return e.jumpList(l, l.current+count)
+}
+
+// OpenLocationList shows the location list of the active view in a new view
+// below it, which shares the list.
+func (e *Editor) OpenLocationList() error {
+	l, err := e.locationList()
+	if err != nil {
+		return err
+	}
+	if err := e.openList(l); err != nil {
+		return err
+	}
+	e.ActiveView().SetLocationList(l)
+	return nil
+}
+
+func (e *Editor) setList(l *quickfixList, entries []QuickfixEntry, jump bool) error {
+	l.entries, l.current = entries, 0
+	switch {
+	case len(entries) == 0:
+		e.SetStatus("No errors")
+	case jump:
+		return e.jumpList(l, 0)
+	default:
+		e.SetStatus("(%d items)", len(entries))
+	}
+	return nil
+}
+
+func (e *Editor) jumpList(l *quickfixList, n int) error {
+	if len(l.entries) == 0 {
+		return errors.New("no errors")
+	}
+	if n < 0 || n >= len(l.entries) {
+		return errors.New("no more items")
+	}
+	q := l.entries[n]
+	// Edit only leaves a jump for another file
+	e.PushJump()
+	if err := e.Edit(q.Path); err != nil {
+		return err
+	}
+	l.current = n
+
+	v := e.ActiveView()
+	v.MoveCursorToLine(q.Line)
+	if q.Col > 0 {
+		c := v.Cursor()
+		c.Boffset = q.Col - 1
+		if c.Boffset > len(c.Line.Data) {
+			c.Boffset = len(c.Line.Data)
+		}
+		v.MoveCursorTo(c)
+	}
+	e.SetStatus("(%d of %d): %s", n+1, len(l.entries), q.Text)
+	return nil
+}
+
+func (e *Editor) openList(l *quickfixList) error {
+	var buf bytes.Buffer
+	for _, q := range l.entries {
+		fmt.Fprintln(&buf, q)
+	}
+	b, err := buffer.NewBuffer(&buf)
+	if err != nil {
+		return err
+	}
+	b.Name = l.name
+	b.UndoLevels = e.Config.UndoLevels
+
+	node := e.active
+	e.SplitHorizontally()
+	if e.active == node {
+		return errors.New("not enough room")
+	}
+	if bottom := e.active.Parent().Bottom(); e.active != bottom {
+		e.SetActiveViewNode(bottom)
+	}
+	v := e.ActiveView()
+	v.Attach(b)
+	v.MoveCursorToLine(l.current + 1)
+	return nil
+}
@@ -7,6 +7,7 @@ import (
 
 func suspend(e *Editor) {
 	// finalize termbox
+	DisableBracketedPaste()
 	termbox.Close()
 
 	// suspend the process
@@ -22,6 +23,8 @@ func suspend(e *Editor) {
 	if err != nil {
 		panic(err)
 	}
-	termbox.SetInputMode(termbox.InputAlt)
+	e.SetInputMode()
+	e.SetOutputMode()
+	EnableBracketedPaste()
 	e.Resize()
 }
@@ -0,0 +1,75 @@
+package editor
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/kisielk/vigo/view"
+	"github.com/nsf/termbox-go"
+)
+
+// defaultColors returns the number of colors of the terminal, guessed from
+// its name.
+func defaultColors() int {
+	if strings.Contains(os.Getenv("TERM"), "256color") {
+		return 256
+	}
+	return 8
+}
+
+// SetOutputMode sets the output mode of the terminal to the number of colors
+// given by the colors option, and the theme of the views to the one which
+// fits it.
+func (e *Editor) SetOutputMode() {
+	if e.Config.Colors >= 256 {
+		termbox.SetOutputMode(termbox.Output256)
+	} else {
+		termbox.SetOutputMode(termbox.OutputNormal)
+	}
+	e.applyTheme()
+}
+
+// applyTheme sets the theme of the views to the loaded color scheme, or to
+// the default one, falling back to the basic colors when the terminal has
+// only 8.
+func (e *Editor) applyTheme() {
+	t := view.DefaultTheme
+	if e.Config.Colors >= 256 {
+		t = view.DefaultTheme256
+	}
+	if e.colorScheme != nil {
+		t = *e.colorScheme
+	}
+	if e.Config.Colors < 256 {
+		t = t.Fallback()
+	}
+	e.Config.View.Theme = t
+}
+
+// LoadColorScheme sets the theme of the views to the one read from the file
+// ~/.vigo/colors/name. The name default restores the default theme.
+func (e *Editor) LoadColorScheme(name string) error {
+	if name == "default" {
+		e.colorScheme = nil
+		e.applyTheme()
+		return nil
+	}
+	home := os.Getenv("HOME")
+	if home == "" {
+		return errors.New("HOME is not set")
+	}
+	f, err := os.Open(filepath.Join(home, ".vigo", "colors", filepath.Base(name)))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	t, err := view.ReadTheme(f)
+	if err != nil {
+		return err
+	}
+	e.colorScheme = &t
+	e.applyTheme()
+	return nil
+}
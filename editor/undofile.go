@@ -0,0 +1,63 @@
+package editor
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+
+	"github.com/kisielk/vigo/buffer"
+)
+
+// undoFilePath returns the path of the file holding the undo history of the
+// file at path, in ~/.vigo/undo.
+func undoFilePath(path string) (string, error) {
+	home := os.Getenv("HOME")
+	if home == "" {
+		return "", errors.New("HOME is not set")
+	}
+	sum := sha1.Sum([]byte(path))
+	return filepath.Join(home, ".vigo", "undo", hex.EncodeToString(sum[:])), nil
+}
+
+// WriteUndoFile saves the undo history of the buffer b, if the undofile
+// option is set. It must be called after b is saved to its file.
+func (e *Editor) WriteUndoFile(b *buffer.Buffer) error {
+	if !e.Config.UndoFile || b.Path == "" {
+		return nil
+	}
+	path, err := undoFilePath(b.Path)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	if err := b.WriteUndo(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// readUndoFile restores the undo history of the buffer b saved by
+// WriteUndoFile, if there is one.
+func (e *Editor) readUndoFile(b *buffer.Buffer) error {
+	path, err := undoFilePath(b.Path)
+	if err != nil {
+		return err
+	}
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	defer f.Close()
+	return b.ReadUndo(f)
+}
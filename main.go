@@ -13,9 +13,12 @@ func main() {
 		panic(err)
 	}
 	defer termbox.Close()
-	termbox.SetInputMode(termbox.InputEsc)
+	editor.EnableBracketedPaste()
+	defer editor.DisableBracketedPaste()
 
 	e := editor.NewEditor(os.Args[1:])
+	e.SetInputMode()
+	e.SetOutputMode()
 	e.SetMode(mode.NewNormalMode(e))
 	e.Resize()
 	e.Draw()
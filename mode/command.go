@@ -3,10 +3,15 @@ package mode
 import (
 	"bytes"
 	"fmt"
+	"os/exec"
 	"strconv"
 	"strings"
+	"unicode/utf8"
 
+	"github.com/kisielk/vigo/buffer"
 	"github.com/kisielk/vigo/editor"
+	"github.com/kisielk/vigo/utils"
+	"github.com/kisielk/vigo/view"
 	"github.com/nsf/termbox-go"
 )
 
@@ -15,26 +20,46 @@ type CommandMode struct {
 	editor *editor.Editor
 	mode   editor.Mode
 	buffer *bytes.Buffer
+
+	register bool        // Ctrl-R was pressed, waiting for the cut buffer name
+	complete *completion // set while cycling through completions
 }
 
-func NewCommandMode(editor *editor.Editor, mode editor.Mode) CommandMode {
-	m := CommandMode{editor: editor, mode: mode, buffer: &bytes.Buffer{}}
+func NewCommandMode(editor *editor.Editor, mode editor.Mode) *CommandMode {
+	m := &CommandMode{editor: editor, mode: mode, buffer: &bytes.Buffer{}}
 	return m
 }
 
-func (m CommandMode) Enter(e *editor.Editor) {
+func (m *CommandMode) Enter(e *editor.Editor) {
 }
 
-func (m CommandMode) NeedsCursor() bool {
+func (m *CommandMode) NeedsCursor() bool {
 	return true
 }
 
-func (m CommandMode) CursorPosition() (int, int) {
+func (m *CommandMode) CursorPosition() (int, int) {
 	e := m.editor
 	return m.buffer.Len() + 1, e.Height() - 1
 }
 
-func (m CommandMode) OnKey(ev *termbox.Event) {
+func (m *CommandMode) OnKey(ev *termbox.Event) {
+	if m.register {
+		m.register = false
+		insertCutBuffer(m.editor, m.buffer, ev)
+		return
+	}
+
+	switch ev.Key {
+	case termbox.KeyTab, termbox.KeyCtrlN:
+		m.completeNext(1)
+		return
+	case termbox.KeyCtrlP:
+		// termbox doesn't report Shift-Tab
+		m.completeNext(-1)
+		return
+	}
+	m.complete = nil
+
 	switch ev.Key {
 	case termbox.KeyEsc, termbox.KeyCtrlC:
 		m.editor.SetMode(m.mode)
@@ -45,12 +70,17 @@ func (m CommandMode) OnKey(ev *termbox.Event) {
 		}
 	case termbox.KeyEnter:
 		c := m.buffer.String()
+		// Commands may replace the status with a message of their own.
+		m.editor.SetStatus(":%s", c)
 		if err := execCommand(m.editor, c); err != nil {
 			m.editor.SetStatus(fmt.Sprintf("error: %s", err))
-		} else {
-			m.editor.SetStatus(":" + c)
 		}
-		m.editor.SetMode(m.mode)
+		// unless the command switched to a mode of its own
+		if m.editor.Mode() == m {
+			m.editor.SetMode(m.mode)
+		}
+	case termbox.KeyCtrlR:
+		m.register = true
 	case termbox.KeySpace:
 		m.buffer.WriteRune(' ')
 	default:
@@ -58,34 +88,118 @@ func (m CommandMode) OnKey(ev *termbox.Event) {
 	}
 }
 
-func (m CommandMode) Exit() {
+func (m *CommandMode) Exit() {
 }
 
-func (m CommandMode) Draw() {
+func (m *CommandMode) Draw() {
+	if m.complete != nil {
+		m.editor.DrawMenu(m.complete.candidates, m.complete.selected)
+	}
 	m.editor.DrawStatus([]byte(":" + m.buffer.String()))
 }
 
+// completeNext completes the last word of the command line. With the wildmenu
+// option, the candidates are shown and repeated calls select the next one in
+// the direction dir, otherwise the word is completed to their common prefix.
+func (m *CommandMode) completeNext(dir int) {
+	if m.complete == nil {
+		s := m.buffer.String()
+		_, rest, err := parseRange(m.editor.ActiveView(), s)
+		if err != nil {
+			return
+		}
+		start, candidates := completeCommandLine(s, rest)
+		switch {
+		case len(candidates) == 0:
+			return
+		case len(candidates) == 1 || !m.editor.Config.WildMenu:
+			m.buffer.Truncate(start)
+			m.buffer.WriteString(commonPrefix(candidates))
+			return
+		}
+		m.complete = &completion{start: start, candidates: candidates, selected: -1}
+	}
+
+	c := m.complete
+	n := len(c.candidates)
+	if c.selected == -1 && dir < 0 {
+		c.selected = n - 1
+	} else {
+		c.selected = (c.selected + dir + n) % n
+	}
+	m.buffer.Truncate(c.start)
+	m.buffer.WriteString(c.candidates[c.selected])
+}
+
 // Interpret command and apply changes to editor.
 func execCommand(e *editor.Editor, command string) error {
+	r, command, err := parseRange(e.ActiveView(), command)
+	if err != nil {
+		return err
+	}
+
 	fields := strings.Fields(command)
 
 	// prevent a crash if no commands are given
 	if len(fields) == 0 {
+		if r != nil {
+			// only a range is given, we should move to its last line
+			e.ActiveView().MoveCursorToLine(r.end)
+		}
 		return nil
 	}
 
+	if c := strings.TrimLeft(command, " "); strings.HasPrefix(c, "!") {
+		if r == nil {
+			return fmt.Errorf("a range is required to filter lines")
+		}
+		return filterLines(e, r, c[1:])
+	}
+
+	if pattern, c, invert, ok := parseGlobal(strings.TrimLeft(command, " ")); ok {
+		return globalCommand(e, r, pattern, c, invert)
+	}
+
 	cmd, args := fields[0], fields[1:]
+	bang := strings.HasSuffix(cmd, "!")
+	cmd = strings.TrimSuffix(cmd, "!")
 
 	switch cmd {
 	case "q":
-		// TODO if more than one split, close active one only.
-		e.Quit()
+		return quitView(e, bang, e.Config.Confirm)
+	case "conf", "confirm":
+		c := strings.TrimLeft(strings.TrimPrefix(strings.TrimLeft(command, " "), fields[0]), " ")
+		if c == "q" {
+			return quitView(e, false, true)
+		}
+		// the other commands don't lose changes
+		return execCommand(e, c)
+	case "clo", "close":
+		return e.CloseView()
+	case "new":
+		return e.SplitNew()
 	case "w":
 		b := e.ActiveView().Buffer()
+		if len(args) > 0 && strings.HasPrefix(args[0], ">>") {
+			return appendLines(e, r, args)
+		}
 		switch len(args) {
 		case 0:
-			b.Save()
+			if err := b.Save(); err != nil {
+				return err
+			}
+			if err := e.SavePositions(e.ActiveView()); err != nil {
+				e.SetStatus("%s", err)
+			}
+			return e.WriteUndoFile(b)
 		case 1:
+			if r != nil {
+				if err := b.WriteLines(args[0], r.start, r.end, bang); err != nil {
+					return err
+				}
+				e.SetStatus("\"%s\" %d lines written", args[0], r.end-r.start+1)
+				return nil
+			}
 			b.SaveAs(args[0])
 		default:
 			return fmt.Errorf("too many arguments to :w")
@@ -102,11 +216,7 @@ func execCommand(e *editor.Editor, command string) error {
 		}
 
 		// TODO: Don't replace the current buffer if it has been modified
-		buffer, err := e.NewBufferFromFile(filename)
-		if err != nil {
-			return err
-		}
-		e.ActiveView().Attach(buffer)
+		return e.Edit(filename)
 	case "sp", "split":
 		e.SplitHorizontally()
 		// TODO file argument | shell command argument
@@ -116,12 +226,686 @@ func execCommand(e *editor.Editor, command string) error {
 		e.ActiveView().ShowHighlights(false)
 	case "hls":
 		e.ActiveView().ShowHighlights(true)
+	case "se", "set":
+		if len(args) == 0 {
+			e.SetStatus("%s", e.Config.String())
+			return nil
+		}
+		var values []string
+		for _, arg := range args {
+			var value string
+			if value, err = e.Config.Set(arg); err != nil {
+				break
+			}
+			if value != "" {
+				values = append(values, value)
+			}
+		}
+		// apply the options set before any error
+		if aerr := e.ApplyConfig(); err == nil {
+			err = aerr
+		}
+		if err != nil {
+			return err
+		}
+		if len(values) > 0 {
+			e.SetStatus("%s", strings.Join(values, " "))
+		}
+	case "exe", "execute":
+		expr := strings.TrimPrefix(strings.TrimLeft(command, " "), fields[0])
+		c, err := evalExpr(expr)
+		if err != nil {
+			return err
+		}
+		return execCommand(e, c)
+	case "norm", "normal":
+		keys := strings.TrimLeft(strings.TrimPrefix(strings.TrimLeft(command, " "), fields[0]), " ")
+		return normalCommand(e, r, keys)
+	case "res", "resize":
+		return resizeView(e, args, false)
+	case "vert", "vertical":
+		if len(args) == 0 || (args[0] != "res" && args[0] != "resize") {
+			return fmt.Errorf(":vertical is only supported with :resize")
+		}
+		return resizeView(e, args[1:], true)
+	case "ce", "center", "le", "left", "ri", "right":
+		return alignLines(e, r, cmd, args)
+	case "mak", "make":
+		return runMake(e, strings.Join(args, " "), e.SetQuickfix, !bang)
+	case "lmak", "lmake":
+		return runMake(e, strings.Join(args, " "), e.SetLocationList, !bang)
+	case "gr", "grep", "lgr", "lgrep":
+		if len(args) == 0 {
+			return fmt.Errorf("expected a pattern for :%s", cmd)
+		}
+		set := e.SetQuickfix
+		if cmd[0] == 'l' {
+			set = e.SetLocationList
+		}
+		return runGrep(e, strings.Join(args, " "), set, !bang)
+	case "cn", "cnext":
+		return e.NextQuickfix(1)
+	case "cp", "cprevious", "cN", "cNext":
+		return e.NextQuickfix(-1)
+	case "lne", "lnext":
+		return e.NextLocation(1)
+	case "lp", "lprevious", "lN", "lNext":
+		return e.NextLocation(-1)
+	case "cc", "ll":
+		n := 1
+		if len(args) > 0 {
+			var err error
+			if n, err = strconv.Atoi(args[0]); err != nil {
+				return fmt.Errorf("invalid number: %s", args[0])
+			}
+		}
+		if cmd == "ll" {
+			return e.JumpLocation(n - 1)
+		}
+		return e.JumpQuickfix(n - 1)
+	case "cope", "copen":
+		return e.OpenQuickfix()
+	case "lop", "lopen":
+		return e.OpenLocationList()
+	case "colo", "colorscheme":
+		if len(args) != 1 {
+			return fmt.Errorf("expected one name for :colorscheme")
+		}
+		return e.LoadColorScheme(args[0])
+	case "dig", "digraphs":
+		e.SetStatus("%s", describeDigraphs())
+	case "reg", "registers", "di", "display":
+		e.SetStatus("%s", e.DescribeCutBuffers())
+	case "nm", "nmap":
+		switch len(args) {
+		case 0:
+			e.SetStatus("%s", e.NormalMap.String())
+		case 1:
+			return fmt.Errorf("missing keys for :nmap %s", args[0])
+		default:
+			// runs of spaces are kept as one, <Space> maps the key
+			return e.NormalMap.Map(args[0], strings.Join(args[1:], " "))
+		}
+	case "nun", "nunmap":
+		if len(args) != 1 {
+			return fmt.Errorf("expected one key for :nunmap")
+		}
+		return e.NormalMap.Unmap(args[0])
+	case "trim":
+		return trimLines(e, r)
+	case "sor", "sort":
+		return sortLines(e, r, args, bang, false)
+	case "uniq":
+		return sortLines(e, r, args, false, true)
+	case "ret", "retab":
+		b := e.ActiveView().Buffer()
+		if r == nil {
+			r = &lineRange{1, b.NumLines}
+		}
+		b.FinalizeActionGroup()
+		n := b.Retab(r.start, r.end, e.Config.View.TabStop, e.Config.ExpandTab, bang)
+		b.FinalizeActionGroup()
+		e.ReportLines(n, "lines changed")
+	}
+
+	return nil
+}
+
+// quitView closes the active view, or quits if it is the last one, as :q
+// does. If confirm is set, the user is asked what to do with unsaved changes
+// instead of failing.
+func quitView(e *editor.Editor, force, confirm bool) error {
+	err := e.QuitView(force)
+	if err == editor.ErrUnsaved && confirm {
+		e.SetMode(newConfirmMode(e, NewNormalMode(e), "Quit with unsaved changes?", func() error {
+			return e.QuitView(true)
+		}))
+		return nil
+	}
+	return err
+}
+
+// resizeView sets the height of the active view, or its width if vertical
+// is set, as given by the arguments of :resize. A size starting with + or -
+// is relative to the current one, and no size makes the view as large as
+// possible, moving the splits of all the nodes above it.
+func resizeView(e *editor.Editor, args []string, vertical bool) error {
+	t := e.ActiveViewNode()
+	status := 0
+	if t.HasStatus() {
+		// the status line isn't counted
+		status = 1
+	}
+	size := t.Height - status
+	if vertical {
+		size = t.Width
+	}
+
+	switch len(args) {
+	case 0:
+		if !t.Maximize(vertical) {
+			return fmt.Errorf("no split to resize")
+		}
+		return nil
+	case 1:
+		n, err := strconv.Atoi(args[0])
+		if err != nil {
+			return fmt.Errorf("invalid size: %s", args[0])
+		}
+		if args[0][0] == '+' || args[0][0] == '-' {
+			size += n
+		} else {
+			size = n
+		}
+	default:
+		return fmt.Errorf("too many arguments to :resize")
+	}
+
+	var ok bool
+	if vertical {
+		ok = t.SetWidth(size)
+	} else {
+		ok = t.SetHeight(size + status)
+	}
+	if !ok {
+		return fmt.Errorf("no split to resize")
+	}
+	return nil
+}
+
+// alignLines aligns the lines of the range r of the active buffer, the cursor
+// line if r is nil, as given by the name and the arguments of the :center,
+// :left or :right command.
+func alignLines(e *editor.Editor, r *lineRange, cmd string, args []string) error {
+	v := e.ActiveView()
+	b := v.Buffer()
+	if r == nil {
+		n := v.Cursor().LineNum
+		r = &lineRange{n, n}
+	}
+
+	align := buffer.AlignLeft
+	width := 0
+	switch cmd[0] {
+	case 'c':
+		align = buffer.AlignCenter
+	case 'r':
+		align = buffer.AlignRight
+	}
+	if align != buffer.AlignLeft {
+		// the width defaults to textwidth, or 80 if it isn't set
+		width = e.Config.TextWidth
+		if width == 0 {
+			width = 80
+		}
+	}
+	switch len(args) {
+	case 0:
+	case 1:
+		n, err := strconv.Atoi(args[0])
+		if err != nil || n < 0 {
+			return fmt.Errorf("invalid width: %s", args[0])
+		}
+		width = n
+	default:
+		return fmt.Errorf("too many arguments to :%s", cmd)
+	}
+
+	b.FinalizeActionGroup()
+	b.Align(r.start, r.end, align, width, e.Config.View.TabStop, e.Config.ExpandTab)
+	b.FinalizeActionGroup()
+	v.Sync()
+	return nil
+}
+
+// trimLines removes the trailing whitespace of the lines of the range r of
+// the active buffer, the cursor line if r is nil, in one undo step.
+func trimLines(e *editor.Editor, r *lineRange) error {
+	v := e.ActiveView()
+	b := v.Buffer()
+	if r == nil {
+		n := v.Cursor().LineNum
+		r = &lineRange{n, n}
+	}
+
+	b.FinalizeActionGroup()
+	n := b.TrimTrailingSpaces(r.start, r.end)
+	b.FinalizeActionGroup()
+	v.Sync()
+
+	// keep the cursor on the last character of its line, not past it
+	if c := v.Cursor(); c.EOL() && !c.BOL() {
+		c.PrevRune(false)
+		v.MoveCursorTo(c)
+	}
+	e.ReportLines(n, "lines changed")
+	return nil
+}
+
+// parseGlobal parses the command c as :g/pattern/command, or :g!, :v or
+// :vglobal, which run the command on the lines not matching the pattern. The
+// pattern may be delimited by any character which isn't a letter, a digit or a
+// space. It reports whether c is such a command.
+func parseGlobal(c string) (pattern, command string, invert, ok bool) {
+	i := 0
+	for i < len(c) && 'a' <= c[i] && c[i] <= 'z' {
+		i++
+	}
+	switch c[:i] {
+	case "g", "global":
+	case "v", "vglobal":
+		invert = true
+	default:
+		return "", "", false, false
+	}
+	c = c[i:]
+	if strings.HasPrefix(c, "!") {
+		invert, c = true, c[1:]
+	}
+
+	d, size := utf8.DecodeRuneInString(c)
+	if size == 0 || d == ' ' || d == '"' || d == '|' || ('0' <= d && d <= '9') || ('a' <= d && d <= 'z') || ('A' <= d && d <= 'Z') {
+		return "", "", false, false
+	}
+	c = c[size:]
+	if i := strings.IndexRune(c, d); i != -1 {
+		return c[:i], c[i+size:], invert, true
+	}
+	// the closing delimiter may be left out if there is no command
+	return c, "", invert, true
+}
+
+// globalCommand runs the command c on each line of the range r of the active
+// buffer, all of them if r is nil, which contains pattern, or which doesn't if
+// invert is set. As for searches, the pattern is matched literally. The lines
+// are found before running the command on any of them, so that the lines
+// added by the command are left alone and the deleted ones are skipped.
+func globalCommand(e *editor.Editor, r *lineRange, pattern, c string, invert bool) error {
+	v := e.ActiveView()
+	b := v.Buffer()
+	if pattern == "" {
+		return fmt.Errorf("missing pattern")
+	}
+	if r == nil {
+		r = &lineRange{1, b.NumLines}
+	}
+
+	var lines []*buffer.Line
+	for _, l := range linesInRange(b, r) {
+		if bytes.Contains(l.Data, []byte(pattern)) != invert {
+			lines = append(lines, l)
+		}
+	}
+	if len(lines) == 0 {
+		return fmt.Errorf("pattern not found: %s", pattern)
+	}
+
+	b.FinalizeActionGroup()
+	defer b.FinalizeActionGroup()
+	defer b.JoinActionGroups(b.History)
+
+	for _, l := range lines {
+		v.Sync()
+		n := lineNumber(b, v.Cursor(), l)
+		if n == 0 {
+			continue
+		}
+		v.MoveCursorTo(buffer.Cursor{Line: l, LineNum: n})
+		if err := execCommand(e, c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// normalCommand handles the keys, written as for :nmap, in normal mode with
+// the cursor at the start of each line of the range r of the active buffer,
+// or at the cursor if r is nil. A command left incomplete by the keys is
+// ended as if Esc was typed.
+func normalCommand(e *editor.Editor, r *lineRange, keys string) error {
+	events, err := editor.ParseKeys(keys)
+	if err != nil {
+		return err
+	}
+	v := e.ActiveView()
+	b := v.Buffer()
+	if r == nil {
+		return executeNormal(e, events)
+	}
+	b.FinalizeActionGroup()
+	defer b.FinalizeActionGroup()
+	defer b.JoinActionGroups(b.History)
+
+	for _, l := range linesInRange(b, r) {
+		v.Sync()
+		n := lineNumber(b, v.Cursor(), l)
+		if n == 0 {
+			// deleted by the keys run on a previous line
+			continue
+		}
+		v.MoveCursorTo(buffer.Cursor{Line: l, LineNum: n})
+		if err := executeNormal(e, events); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// executeNormal handles the key events in a new normal mode, then types Esc
+// until the editor is back in normal mode.
+func executeNormal(e *editor.Editor, events []termbox.Event) error {
+	e.SetMode(NewNormalMode(e))
+	if err := e.ExecuteKeys(events); err != nil {
+		return err
+	}
+	esc := []termbox.Event{{Type: termbox.EventKey, Key: termbox.KeyEsc}}
+	// each Esc leaves one mode, such as command mode opened from visual mode
+	for i := 0; i < 3; i++ {
+		if _, ok := e.Mode().(*normalMode); ok {
+			break
+		}
+		if err := e.ExecuteKeys(esc); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// linesInRange returns the lines of the range r of the buffer b.
+func linesInRange(b *buffer.Buffer, r *lineRange) []*buffer.Line {
+	var lines []*buffer.Line
+	c := b.LineCursor(r.start)
+	for n := r.start; n <= r.end && c.Line != nil; n++ {
+		lines = append(lines, c.Line)
+		c.Line = c.Line.Next
+	}
+	return lines
+}
+
+// lineNumber returns the number of the line l in the buffer b, or 0 if it
+// isn't in the buffer anymore. It is looked for both ways from the cursor c,
+// which is usually close to it.
+func lineNumber(b *buffer.Buffer, c buffer.Cursor, l *buffer.Line) int {
+	if l.Prev == nil && b.FirstLine != l || l.Prev != nil && l.Prev.Next != l {
+		return 0
+	}
+	up, down := c, c
+	for up.Line != nil || down.Line != nil {
+		if down.Line == l {
+			return down.LineNum
+		}
+		if up.Line == l {
+			return up.LineNum
+		}
+		if down.Line != nil {
+			down.Line, down.LineNum = down.Line.Next, down.LineNum+1
+		}
+		if up.Line != nil {
+			up.Line, up.LineNum = up.Line.Prev, up.LineNum-1
+		}
+	}
+	return 0
+}
+
+// sortLines sorts the lines of the range r of the active buffer, all of them
+// if r is nil, in reverse order if reverse is set, as :sort does. The u flag
+// removes the duplicate lines. If uniq is set, the lines are only deduplicated,
+// keeping their order, as :uniq does.
+func sortLines(e *editor.Editor, r *lineRange, args []string, reverse, uniq bool) error {
+	v := e.ActiveView()
+	b := v.Buffer()
+	if r == nil {
+		r = &lineRange{1, b.NumLines}
+	}
+	if r.end == b.NumLines && r.end > r.start && len(b.LastLine.Data) == 0 {
+		// the empty line after the final newline stays last
+		r.end--
+	}
+
+	unique := false
+	for _, arg := range args {
+		if uniq || strings.Trim(arg, "u") != "" {
+			return fmt.Errorf("invalid argument: %s", arg)
+		}
+		unique = true
 	}
 
-	if lineNum, err := strconv.Atoi(cmd); err == nil {
-		// cmd is a number, we should move to that line
-		e.ActiveView().MoveCursorToLine(lineNum)
+	b.FinalizeActionGroup()
+	var n int
+	if uniq {
+		n = b.UniqLines(r.start, r.end)
+	} else {
+		n = b.SortLines(r.start, r.end, reverse, unique)
 	}
+	b.FinalizeActionGroup()
+	v.Sync()
+	v.MoveCursorTo(b.LineCursor(r.start))
+
+	e.ReportLines(n, "lines removed")
+	return nil
+}
 
+// appendLines appends the lines of the range r of the active buffer, all of
+// them if r is nil, to the file given by the arguments of :w >>.
+func appendLines(e *editor.Editor, r *lineRange, args []string) error {
+	b := e.ActiveView().Buffer()
+	if r == nil {
+		r = &lineRange{1, b.NumLines}
+	}
+	args = append([]string{strings.TrimPrefix(args[0], ">>")}, args[1:]...)
+	if args[0] == "" {
+		args = args[1:]
+	}
+	if len(args) != 1 {
+		return fmt.Errorf("expected one file name for :w >>")
+	}
+	if err := b.AppendTo(args[0], r.start, r.end); err != nil {
+		return err
+	}
+	e.SetStatus("\"%s\" %d lines appended", args[0], r.end-r.start+1)
 	return nil
 }
+
+// setList replaces the quickfix list or a location list with entries.
+type setList func(entries []editor.QuickfixEntry, jump bool) error
+
+// runMake runs the shell command c, make if it is empty, and sets the
+// quickfix or location list with set to the locations found in its output.
+// It jumps to the first one if jump is set.
+func runMake(e *editor.Editor, c string, set setList, jump bool) error {
+	if c == "" {
+		c = "make"
+	}
+	output, err := exec.Command("sh", "-c", c).CombinedOutput()
+	if _, ok := err.(*exec.ExitError); err != nil && !ok {
+		return err
+	}
+	// the command fails when there are errors, they are in the output
+	return set(editor.ParseQuickfix(output), jump)
+}
+
+// runGrep runs grep with the arguments args, a pattern followed by file
+// names expanded by the shell, and sets the quickfix or location list with
+// set to the matching lines. It jumps to the first one if jump is set. It
+// reports that there is no match if the list is empty.
+func runGrep(e *editor.Editor, args string, set setList, jump bool) error {
+	output, err := exec.Command("sh", "-c", "grep -n -H "+args).Output()
+	if exit, ok := err.(*exec.ExitError); ok && exit.ExitCode() == 1 {
+		// nothing matches
+		err = nil
+	}
+	if exit, ok := err.(*exec.ExitError); ok {
+		if msg := strings.TrimSpace(string(exit.Stderr)); msg != "" {
+			return fmt.Errorf("grep: %s", strings.SplitN(msg, "\n", 2)[0])
+		}
+	}
+	if err != nil {
+		return err
+	}
+	entries := editor.ParseGrep(output)
+	if err := set(entries, jump); err != nil {
+		return err
+	}
+	if len(entries) == 0 {
+		// rather than the "No errors" of an empty list
+		return fmt.Errorf("no match: %s", args)
+	}
+	return nil
+}
+
+// filterLines replaces the lines of the range r of the active buffer with the
+// output of the shell command c, given the lines as input.
+func filterLines(e *editor.Editor, r *lineRange, c string) error {
+	v := e.ActiveView()
+	b := v.Buffer()
+	lineNum := v.Cursor().LineNum
+
+	start, end := b.LineCursor(r.start), b.LineCursor(r.end+1)
+	lastLine := end.LineNum == r.end
+	if lastLine {
+		// there is no newline after the last line of the buffer
+		end.MoveEOL()
+	}
+	input := start.ExtractBytes(start.Distance(end))
+	if lastLine {
+		input = append(input, '\n')
+	}
+
+	var stdout, stderr bytes.Buffer
+	sh := exec.Command("sh", "-c", c)
+	sh.Stdin = bytes.NewReader(input)
+	sh.Stdout = &stdout
+	sh.Stderr = &stderr
+	if err := sh.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return fmt.Errorf("%s: %s", err, strings.SplitN(msg, "\n", 2)[0])
+		}
+		return err
+	}
+
+	output := stdout.Bytes()
+	if lastLine {
+		output = bytes.TrimSuffix(output, []byte{'\n'})
+	} else if len(output) > 0 && output[len(output)-1] != '\n' {
+		output = append(output, '\n')
+	}
+
+	b.FinalizeActionGroup()
+	b.Delete(start, start.Distance(end))
+	if len(output) > 0 {
+		b.Insert(start, output)
+	}
+	b.FinalizeActionGroup()
+
+	// keep the cursor on its line, if it still exists
+	v.Sync()
+	cursor := b.LineCursor(lineNum)
+	cursor.Boffset = utils.IndexFirstNonSpace(cursor.Line.Data)
+	v.MoveCursorTo(cursor)
+	return nil
+}
+
+// lineRange is a range of lines given to a command, both ends included.
+type lineRange struct {
+	start, end int
+}
+
+// parseRange parses the range of lines at the start of the command s, if
+// any, and returns it with the rest of the command. A range is either % for
+// the whole buffer, or one or two addresses separated by a comma. An address
+// is a line number, . for the cursor line, $ for the last line or 'x for the
+// line of the mark x, followed by any number of +N or -N offsets.
+func parseRange(v *view.View, s string) (*lineRange, string, error) {
+	b := v.Buffer()
+	cur, last := v.Cursor().LineNum, b.NumLines
+
+	s = strings.TrimLeft(s, " ")
+	if strings.HasPrefix(s, "%") {
+		return &lineRange{1, last}, s[1:], nil
+	}
+
+	start, s, ok, err := parseAddress(s, cur, b)
+	if err != nil {
+		return nil, s, err
+	}
+	if !ok && !strings.HasPrefix(s, ",") {
+		return nil, s, nil
+	}
+	if !ok {
+		start = cur
+	}
+	end := start
+	if strings.HasPrefix(s, ",") {
+		end, s, ok, err = parseAddress(s[1:], cur, b)
+		if err != nil {
+			return nil, s, err
+		}
+		if !ok {
+			end = cur
+		}
+	} else if start > last {
+		// a single address past the end, as :999, is the last line
+		start, end = last, last
+	}
+
+	if start > end {
+		start, end = end, start
+	}
+	if start < 1 || end > last {
+		return nil, s, fmt.Errorf("invalid range")
+	}
+	return &lineRange{start, end}, s, nil
+}
+
+// parseAddress parses a line address of the buffer b at the start of s, given
+// the cursor line cur. It reports whether an address was found.
+func parseAddress(s string, cur int, b *buffer.Buffer) (n int, rest string, ok bool, err error) {
+	digits := func(s string) int {
+		i := 0
+		for i < len(s) && '0' <= s[i] && s[i] <= '9' {
+			i++
+		}
+		return i
+	}
+
+	switch {
+	case s == "":
+	case s[0] == '.':
+		n, s, ok = cur, s[1:], true
+	case s[0] == '$':
+		n, s, ok = b.NumLines, s[1:], true
+	case s[0] == '\'':
+		r, size := utf8.DecodeRuneInString(s[1:])
+		c, set := b.Mark(r)
+		if !set {
+			return 0, s, false, fmt.Errorf("mark not set: %s", s[:1+size])
+		}
+		n, s, ok = c.LineNum, s[1+size:], true
+	case digits(s) > 0:
+		i := digits(s)
+		if n, err = strconv.Atoi(s[:i]); err != nil {
+			return 0, s, false, fmt.Errorf("invalid address: %s", s[:i])
+		}
+		s, ok = s[i:], true
+	}
+
+	for len(s) > 0 && (s[0] == '+' || s[0] == '-') {
+		if !ok {
+			n, ok = cur, true
+		}
+		sign := 1
+		if s[0] == '-' {
+			sign = -1
+		}
+		s = s[1:]
+		offset := 1
+		if i := digits(s); i > 0 {
+			if offset, err = strconv.Atoi(s[:i]); err != nil {
+				return 0, s, false, fmt.Errorf("invalid offset: %s", s[:i])
+			}
+			s = s[i:]
+		}
+		n += sign * offset
+	}
+	return n, s, ok, nil
+}
@@ -0,0 +1,29 @@
+package mode
+
+import (
+	"testing"
+
+	"github.com/kisielk/vigo/editor"
+)
+
+func TestGrepNoMatch(t *testing.T) {
+	e, done := newTestEditor(t, "abc")
+	defer done()
+	path := e.ActiveView().Buffer().Path
+	var got []editor.QuickfixEntry
+	set := func(entries []editor.QuickfixEntry, jump bool) error {
+		got = entries
+		return nil
+	}
+
+	if err := runGrep(e, "b "+path, set, false); err != nil || len(got) != 1 {
+		t.Errorf("got %v, %v, want one match", got, err)
+	}
+	err := runGrep(e, "x "+path, set, false)
+	if want := "no match: x " + path; err == nil || err.Error() != want {
+		t.Errorf("got error %v, want %s", err, want)
+	}
+	if len(got) != 0 {
+		t.Errorf("got %v, want the list emptied", got)
+	}
+}
@@ -0,0 +1,98 @@
+package mode
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// exCommands are the names of the commands completed in command mode.
+var exCommands = []string{
+	"cc", "center", "close", "cnext", "colorscheme", "confirm", "copen",
+	"cprevious", "digraphs", "display", "e", "execute", "global", "grep",
+	"hls", "left", "lgrep", "ll", "lmake", "lnext", "lopen", "lprevious",
+	"make", "new", "nmap", "nohls", "normal", "nunmap", "q", "registers",
+	"resize", "retab", "right", "set", "sort", "split", "trim", "uniq",
+	"vertical", "vglobal", "vsplit", "w",
+}
+
+// fileCommands are the commands taking a file name as argument.
+var fileCommands = map[string]bool{"e": true, "w": true}
+
+// completion holds the candidates for the word being completed in command
+// mode, while they are cycled through.
+type completion struct {
+	start      int      // offset of the completed word in the command line
+	candidates []string // sorted
+	selected   int      // index of the candidate in the command line, or -1
+}
+
+// completeCommandLine returns the offset of the last word of the command line
+// s and the candidates completing it. Command names are completed, and file
+// names for the commands taking one. rest is s without its range.
+func completeCommandLine(s, rest string) (int, []string) {
+	rest = strings.TrimLeft(rest, " ")
+	prefix := len(s) - len(rest)
+
+	i := strings.LastIndexByte(rest, ' ')
+	if i == -1 {
+		return prefix, completeWord(exCommands, rest)
+	}
+	cmd := strings.TrimSuffix(strings.Fields(rest)[0], "!")
+	if !fileCommands[cmd] {
+		return 0, nil
+	}
+	word := rest[i+1:]
+	return prefix + i + 1, completeFile(strings.TrimPrefix(word, ">>"), strings.HasPrefix(word, ">>"))
+}
+
+// completeWord returns the words starting with prefix.
+func completeWord(words []string, prefix string) []string {
+	var c []string
+	for _, w := range words {
+		if strings.HasPrefix(w, prefix) {
+			c = append(c, w)
+		}
+	}
+	return c
+}
+
+// completeFile returns the names of the files starting with prefix, with a
+// trailing slash for directories. The names are prefixed with >> for :w >>.
+func completeFile(prefix string, appending bool) []string {
+	matches, err := filepath.Glob(prefix + "*")
+	if err != nil {
+		return nil
+	}
+	for i, name := range matches {
+		if fi, err := os.Stat(name); err == nil && fi.IsDir() {
+			name += string(filepath.Separator)
+		}
+		if appending {
+			name = ">>" + name
+		}
+		matches[i] = name
+	}
+	sort.Strings(matches)
+	return matches
+}
+
+// commonPrefix returns the longest common prefix of the strings s.
+func commonPrefix(s []string) string {
+	if len(s) == 0 {
+		return ""
+	}
+	p := s[0]
+	for _, w := range s[1:] {
+		for !strings.HasPrefix(w, p) {
+			p = p[:len(p)-1]
+		}
+	}
+	// don't cut a multi-byte character
+	for !utf8.ValidString(p) {
+		p = p[:len(p)-1]
+	}
+	return p
+}
@@ -0,0 +1,61 @@
+package mode
+
+import (
+	"github.com/kisielk/vigo/editor"
+	"github.com/nsf/termbox-go"
+)
+
+// confirmMode asks whether to go on with an action which would lose unsaved
+// changes, as set by the confirm option or the :confirm command: y goes on,
+// w writes the changes first, and n, Esc or any other key cancels.
+type confirmMode struct {
+	editor *editor.Editor
+	mode   editor.Mode // mode to go back to
+	prompt string
+	action func() error
+}
+
+func newConfirmMode(e *editor.Editor, mode editor.Mode, prompt string, action func() error) *confirmMode {
+	return &confirmMode{editor: e, mode: mode, prompt: prompt, action: action}
+}
+
+func (m *confirmMode) Enter(e *editor.Editor) {
+}
+
+func (m *confirmMode) OnKey(ev *termbox.Event) {
+	g := m.editor
+	g.SetMode(m.mode)
+
+	var err error
+	switch ev.Ch {
+	case 'y', 'Y':
+		err = m.action()
+	case 'w', 'W':
+		if err = g.WriteAll(); err == nil {
+			err = m.action()
+		}
+	default:
+		g.SetStatus("Cancelled")
+	}
+	if err != nil {
+		g.SetStatus("error: %s", err)
+	}
+}
+
+func (m *confirmMode) Exit() {
+}
+
+func (m *confirmMode) NeedsCursor() bool {
+	return false
+}
+
+func (m *confirmMode) CursorPosition() (int, int) {
+	return 0, 0
+}
+
+func (m *confirmMode) OnResize(ev *termbox.Event) {
+}
+
+func (m *confirmMode) Draw() {
+	m.editor.DrawStatus([]byte(m.prompt + " (y)es, (w)rite, (n)o"))
+}
@@ -0,0 +1,71 @@
+package mode
+
+import (
+	"sort"
+	"strings"
+)
+
+// digraphs are the characters entered with Ctrl-K followed by two keys in
+// insert mode, a subset of those of RFC 1345.
+var digraphs = map[string]rune{
+	"a:": 'ä', "a'": 'á', "a!": 'à', "a>": 'â',
+	"a?": 'ã', "e:": 'ë', "e'": 'é', "e!": 'è',
+	"e>": 'ê', "i:": 'ï', "i'": 'í', "i!": 'ì',
+	"i>": 'î', "o:": 'ö', "o'": 'ó', "o!": 'ò',
+	"o>": 'ô', "o?": 'õ', "u:": 'ü', "u'": 'ú',
+	"u!": 'ù', "u>": 'û', "y:": 'ÿ', "y'": 'ý',
+	"A:": 'Ä', "A'": 'Á', "A!": 'À', "A>": 'Â',
+	"A?": 'Ã', "E:": 'Ë', "E'": 'É', "E!": 'È',
+	"E>": 'Ê', "I:": 'Ï', "I'": 'Í', "I!": 'Ì',
+	"I>": 'Î', "O:": 'Ö', "O'": 'Ó', "O!": 'Ò',
+	"O>": 'Ô', "O?": 'Õ', "U:": 'Ü', "U'": 'Ú',
+	"U!": 'Ù', "U>": 'Û', "Y:": 'Ÿ', "Y'": 'Ý',
+	"n'": 'ń', "n?": 'ñ', "N'": 'Ń', "N?": 'Ñ',
+	"c'": 'ć', "c>": 'ĉ', "C'": 'Ć', "C>": 'Ĉ',
+	"aa": 'å', "AA": 'Å', "ae": 'æ', "AE": 'Æ',
+	"c,": 'ç', "C,": 'Ç', "o/": 'ø', "O/": 'Ø',
+	"ss": 'ß', "oe": 'œ', "OE": 'Œ', "Eu": '€',
+	"Pd": '£', "Ye": '¥', "Ct": '¢', "Co": '©',
+	"Rg": '®', "TM": '™', "SE": '§', "PI": '¶',
+	"DG": '°', "+-": '±', "*X": '×', "-:": '÷',
+	"<<": '«', ">>": '»', "!I": '¡', "?I": '¿',
+	"My": 'µ', "12": '½', "14": '¼', "34": '¾',
+	"1S": '¹', "2S": '²', "3S": '³', ".M": '·',
+	"NS": '\u00a0', "->": '→', "<-": '←', "-!": '↑',
+	"-v": '↓', "!=": '≠', "=<": '≤', ">=": '≥',
+	"00": '∞', "..": '…', "-N": '–', "-M": '—',
+	"\"6": '“', "\"9": '”', "'6": '‘', "'9": '’',
+	"a*": 'α', "A*": 'Α', "b*": 'β', "B*": 'Β',
+	"g*": 'γ', "G*": 'Γ', "d*": 'δ', "D*": 'Δ',
+	"e*": 'ε', "E*": 'Ε', "z*": 'ζ', "Z*": 'Ζ',
+	"y*": 'η', "Y*": 'Η', "h*": 'θ', "H*": 'Θ',
+	"i*": 'ι', "I*": 'Ι', "k*": 'κ', "K*": 'Κ',
+	"l*": 'λ', "L*": 'Λ', "m*": 'μ', "M*": 'Μ',
+	"n*": 'ν', "N*": 'Ν', "c*": 'ξ', "C*": 'Ξ',
+	"o*": 'ο', "O*": 'Ο', "p*": 'π', "P*": 'Π',
+	"r*": 'ρ', "R*": 'Ρ', "s*": 'σ', "S*": 'Σ',
+	"t*": 'τ', "T*": 'Τ', "u*": 'υ', "U*": 'Υ',
+	"f*": 'φ', "F*": 'Φ', "x*": 'χ', "X*": 'Χ',
+	"q*": 'ψ', "Q*": 'Ψ', "w*": 'ω', "W*": 'Ω',
+}
+
+// lookupDigraph returns the character of the digraph made of the keys a and
+// b, typed in either order.
+func lookupDigraph(a, b rune) (rune, bool) {
+	if r, ok := digraphs[string([]rune{a, b})]; ok {
+		return r, true
+	}
+	r, ok := digraphs[string([]rune{b, a})]
+	return r, ok
+}
+
+// describeDigraphs returns all the digraphs with their characters, sorted by
+// keys.
+func describeDigraphs() string {
+	s := make([]string, 0, len(digraphs))
+	for keys, r := range digraphs {
+		s = append(s, keys+" "+string(r))
+	}
+	sort.Strings(s)
+	return strings.Join(s, "  ")
+}
@@ -0,0 +1,78 @@
+package mode
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// evalExpr evaluates the expression s, which is a sequence of string and
+// number literals concatenated with the . operator. Strings are either in
+// double quotes, with backslash escapes, or in single quotes, where a quote
+// is written twice.
+func evalExpr(s string) (string, error) {
+	var result []string
+	for {
+		v, rest, err := evalLiteral(strings.TrimLeft(s, " "))
+		if err != nil {
+			return "", err
+		}
+		result = append(result, v)
+		s = strings.TrimLeft(rest, " ")
+		if s == "" {
+			return strings.Join(result, ""), nil
+		}
+		if s[0] != '.' {
+			return "", fmt.Errorf("invalid expression: %s", s)
+		}
+		// .. is the same as .
+		s = strings.TrimPrefix(s[1:], ".")
+	}
+}
+
+// evalLiteral returns the value of the literal at the start of s, and the
+// rest of s.
+func evalLiteral(s string) (string, string, error) {
+	if s == "" {
+		return "", "", fmt.Errorf("missing expression")
+	}
+	switch s[0] {
+	case '"':
+		for i := 1; i < len(s); i++ {
+			switch s[i] {
+			case '\\':
+				i++
+			case '"':
+				v, err := strconv.Unquote(s[:i+1])
+				if err != nil {
+					return "", "", fmt.Errorf("invalid string: %s", s[:i+1])
+				}
+				return v, s[i+1:], nil
+			}
+		}
+	case '\'':
+		var v []byte
+		for i := 1; i < len(s); i++ {
+			if s[i] != '\'' {
+				v = append(v, s[i])
+				continue
+			}
+			if i+1 < len(s) && s[i+1] == '\'' {
+				v = append(v, '\'')
+				i++
+				continue
+			}
+			return string(v), s[i+1:], nil
+		}
+	default:
+		i := 0
+		for i < len(s) && '0' <= s[i] && s[i] <= '9' {
+			i++
+		}
+		if i == 0 {
+			return "", "", fmt.Errorf("invalid expression: %s", s)
+		}
+		return s[:i], s[i:], nil
+	}
+	return "", "", fmt.Errorf("missing quote: %s", s)
+}
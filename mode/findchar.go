@@ -0,0 +1,60 @@
+package mode
+
+import (
+	"strconv"
+
+	cmd "github.com/kisielk/vigo/commands"
+	"github.com/kisielk/vigo/editor"
+	"github.com/nsf/termbox-go"
+)
+
+// findCharMode reads the character searched by f, F, t or T.
+type findCharMode struct {
+	editor *editor.Editor
+	mode   editor.Mode // mode to go back to
+	op     rune        // 'f', 'F', 't' or 'T'
+	count  int
+}
+
+func newFindCharMode(e *editor.Editor, mode editor.Mode, op rune, count int) *findCharMode {
+	return &findCharMode{editor: e, mode: mode, op: op, count: count}
+}
+
+func (m *findCharMode) Enter(e *editor.Editor) {
+}
+
+func (m *findCharMode) OnKey(ev *termbox.Event) {
+	g := m.editor
+	r := ev.Ch
+	if r == 0 {
+		switch ev.Key {
+		case termbox.KeySpace:
+			r = ' '
+		case termbox.KeyTab:
+			r = '\t'
+		default:
+			// Esc or any other key cancels the command
+			g.SetMode(m.mode)
+			return
+		}
+	}
+
+	dir := cmd.Forward
+	if m.op == 'F' || m.op == 'T' {
+		dir = cmd.Backward
+	}
+	till := m.op == 't' || m.op == 'T'
+	g.Commands <- cmd.FindChar{Dir: dir, Till: till, Char: r, Count: m.count}
+	g.SetMode(m.mode)
+}
+
+func (m *findCharMode) Exit() {
+}
+
+func (m *findCharMode) PendingCommand() string {
+	s := string(m.op)
+	if m.count > 1 {
+		s = strconv.Itoa(m.count) + s
+	}
+	return s
+}
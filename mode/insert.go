@@ -1,36 +1,100 @@
 package mode
 
 import (
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
 	cmd "github.com/kisielk/vigo/commands"
 	"github.com/kisielk/vigo/editor"
 	"github.com/nsf/termbox-go"
 )
 
 type insertMode struct {
-	editor *editor.Editor
-	count  int
+	editor   *editor.Editor
+	count    int
+	register bool // Ctrl-R was pressed, waiting for the cut buffer name
+
+	// Ctrl-K was pressed, waiting for the keys of a digraph
+	digraph      bool
+	digraphFirst rune
+
+	// Ctrl-V was pressed, waiting for a key to insert literally or for
+	// the digits of a character code
+	literal  bool
+	code     string // digits typed so far
+	codeBase int    // base of the code, 0 until its first key
+	codeLen  int    // maximum number of digits
+	codeKey  rune   // u, U, x or o before the digits, 0 for decimal codes
+
+	// keys typed in the current undo step, and time of the last one
+	keys    int
+	lastKey time.Time
 }
 
-func NewInsertMode(editor *editor.Editor, count int) insertMode {
-	m := insertMode{editor: editor}
-	m.editor.SetStatus("Insert")
+func NewInsertMode(editor *editor.Editor, count int) *insertMode {
+	m := &insertMode{editor: editor}
 	m.count = count
 	return m
 }
 
-func (m insertMode) Enter(editor *editor.Editor) {
+func (m *insertMode) Enter(editor *editor.Editor) {
+	// also called when coming back from a command typed after Ctrl-O
+	if editor.Config.Paste {
+		editor.SetStatus("Insert (paste)")
+	} else {
+		editor.SetStatus("Insert")
+	}
 }
 
-func (m insertMode) OnKey(ev *termbox.Event) {
+func (m *insertMode) OnKey(ev *termbox.Event) {
 	g := m.editor
+	m.breakUndo()
+
+	if m.register {
+		m.register = false
+		if s, ok := g.CutBuffer(byte(ev.Ch)); ok && ev.Ch < utf8.RuneSelf {
+			g.Commands <- cmd.InsertText{s}
+		}
+		return
+	}
+
+	if m.digraph {
+		m.onDigraphKey(ev)
+		return
+	}
+	if m.literal {
+		m.onLiteralKey(ev)
+		return
+	}
 
 	switch ev.Key {
 	case termbox.KeyEsc, termbox.KeyCtrlC:
 		g.SetMode(NewNormalMode(g))
 	case termbox.KeyBackspace, termbox.KeyBackspace2:
 		g.Commands <- cmd.DeleteRuneBackward{}
-	case termbox.KeyDelete, termbox.KeyCtrlD:
+	case termbox.KeyDelete:
 		g.Commands <- cmd.DeleteRune{}
+	case termbox.KeyCtrlT:
+		g.Commands <- cmd.ShiftLine{cmd.Forward}
+	case termbox.KeyCtrlD:
+		g.Commands <- cmd.ShiftLine{cmd.Backward}
+	case termbox.KeyCtrlW:
+		g.Commands <- cmd.DeleteWordBackward{}
+	case termbox.KeyCtrlU:
+		g.Commands <- cmd.DeleteBOL{}
+	case termbox.KeyCtrlR:
+		m.register = true
+	case termbox.KeyCtrlK:
+		m.digraph = true
+	case termbox.KeyCtrlV:
+		m.literal = true
+	case termbox.KeyCtrlO:
+		// The text inserted so far isn't repeated.
+		m.count = 1
+		g.SetMode(newOneShotNormalMode(g, m))
 	case termbox.KeySpace:
 		g.Commands <- cmd.InsertRune{' '}
 	case termbox.KeyEnter:
@@ -47,7 +111,114 @@ func (m insertMode) OnKey(ev *termbox.Event) {
 	}
 }
 
-func (m insertMode) Exit() {
+// breakUndo starts a new undo step when the number of keys typed since the
+// last one reaches the undobreak option, or when typing resumes after the
+// idle time of the undotime option, so that long typing isn't undone at once.
+// The text is kept in one step when it is repeated by a count.
+func (m *insertMode) breakUndo() {
+	c := m.editor.Config
+	now := time.Now()
+	idle := c.UndoTime > 0 && !m.lastKey.IsZero() &&
+		now.Sub(m.lastKey) >= time.Duration(c.UndoTime)*time.Millisecond
+	m.lastKey = now
+	m.keys++
+	if m.count > 1 {
+		return
+	}
+	if idle || c.UndoBreak > 0 && m.keys > c.UndoBreak {
+		m.keys = 1
+		m.editor.Commands <- cmd.BreakUndo{}
+	}
+}
+
+// onDigraphKey handles the keys typed after Ctrl-K. The character of the
+// digraph is inserted after the second key, or that key if there is no such
+// digraph. Any key other than a character cancels the digraph.
+func (m *insertMode) onDigraphKey(ev *termbox.Event) {
+	r := ev.Ch
+	if ev.Key == termbox.KeySpace {
+		r = ' '
+	}
+	if r == 0 {
+		m.digraph, m.digraphFirst = false, 0
+		return
+	}
+	if m.digraphFirst == 0 {
+		m.digraphFirst = r
+		return
+	}
+	if d, ok := lookupDigraph(m.digraphFirst, r); ok {
+		r = d
+	}
+	m.editor.Commands <- cmd.InsertRune{r}
+	m.digraph, m.digraphFirst = false, 0
+}
+
+// onLiteralKey handles the keys typed after Ctrl-V. A character code is
+// given by up to three decimal digits, or by u and four hexadecimal digits,
+// U and eight, x and two, or o and three octal digits. It is complete when all
+// its digits are typed, otherwise the next key ends it and is handled as
+// usual. The letter is inserted when no digit follows it. Any other key is
+// inserted as it is, control keys included.
+func (m *insertMode) onLiteralKey(ev *termbox.Event) {
+	if m.codeBase == 0 {
+		switch ev.Ch {
+		case 'u':
+			m.codeBase, m.codeLen, m.codeKey = 16, 4, ev.Ch
+		case 'U':
+			m.codeBase, m.codeLen, m.codeKey = 16, 8, ev.Ch
+		case 'x', 'X':
+			m.codeBase, m.codeLen, m.codeKey = 16, 2, ev.Ch
+		case 'o', 'O':
+			m.codeBase, m.codeLen, m.codeKey = 8, 3, ev.Ch
+		case '0', '1', '2', '3', '4', '5', '6', '7', '8', '9':
+			m.codeBase, m.codeLen = 10, 3
+			m.onLiteralKey(ev)
+		default:
+			m.literal = false
+			if r, ok := literalRune(ev); ok {
+				m.editor.Commands <- cmd.InsertText{[]byte(string(r))}
+			}
+		}
+		return
+	}
+
+	digits := "0123456789abcdef"[:m.codeBase]
+	if ev.Ch != 0 && strings.ContainsRune(digits, unicode.ToLower(ev.Ch)) {
+		m.code += string(ev.Ch)
+		if len(m.code) < m.codeLen {
+			return
+		}
+		ev = nil
+	}
+
+	// the code is complete
+	if m.code == "" {
+		// no digits, the letter is inserted as it is
+		m.editor.Commands <- cmd.InsertText{[]byte(string(m.codeKey))}
+	} else if n, err := strconv.ParseUint(m.code, m.codeBase, 32); err == nil && utf8.ValidRune(rune(n)) {
+		m.editor.Commands <- cmd.InsertText{[]byte(string(rune(n)))}
+	}
+	m.literal, m.code, m.codeBase, m.codeKey = false, "", 0, 0
+	if ev != nil {
+		m.OnKey(ev)
+	}
+}
+
+// literalRune returns the rune inserted by the key of ev after Ctrl-V, and
+// false for keys such as arrows which have none.
+func literalRune(ev *termbox.Event) (rune, bool) {
+	switch {
+	case ev.Ch != 0:
+		return ev.Ch, true
+	case ev.Key <= termbox.KeySpace || ev.Key == termbox.KeyBackspace2:
+		// control keys are the control characters of the same code
+		return rune(ev.Key), true
+	}
+	return 0, false
+}
+
+func (m *insertMode) Exit() {
 	// repeat action specified number of times
 	v := m.editor.ActiveView()
 	b := v.Buffer()
@@ -1,6 +1,10 @@
 package mode
 
 import (
+	"path/filepath"
+	"unicode/utf8"
+
+	"github.com/kisielk/vigo/buffer"
 	cmd "github.com/kisielk/vigo/commands"
 	"github.com/kisielk/vigo/editor"
 	"github.com/kisielk/vigo/utils"
@@ -10,6 +14,16 @@ import (
 type normalMode struct {
 	editor *editor.Editor
 	count  string
+	prefix rune // first key of a pending multi-key command, such as 'g'
+
+	// cut buffer named with "x for the next command, 0 for the anonymous one
+	register byte
+
+	// Mode to go back to after a single command, for Ctrl-O in insert mode.
+	oneShot editor.Mode
+	started bool // the command has been started
+
+	noremap bool // handling the keys of a mapping, which aren't mapped again
 }
 
 func NewNormalMode(e *editor.Editor) *normalMode {
@@ -18,8 +32,24 @@ func NewNormalMode(e *editor.Editor) *normalMode {
 	return &m
 }
 
+// newOneShotNormalMode returns a normal mode executing a single command
+// before going back to the mode insert.
+func newOneShotNormalMode(e *editor.Editor, insert editor.Mode) *normalMode {
+	m := normalMode{editor: e, oneShot: insert}
+	m.editor.SetStatus("Normal (insert)")
+	return &m
+}
+
 func (m *normalMode) Enter(e *editor.Editor) {
 	e.ActiveView().Buffer().FinalizeActionGroup()
+	if m.oneShot != nil {
+		if m.started {
+			// back from a mode reading the rest of the command
+			e.SetMode(m.oneShot)
+			return
+		}
+		m.started = true
+	}
 }
 
 func (m *normalMode) OnKey(ev *termbox.Event) {
@@ -30,10 +60,24 @@ func (m *normalMode) OnKey(ev *termbox.Event) {
 	v := g.ActiveView()
 	c := v.Cursor()
 
+	if m.oneShot != nil {
+		defer m.endOneShot()
+	}
+
+	if keys, ok := g.NormalMap.Lookup(ev); ok && m.prefix == 0 && !m.noremap {
+		m.noremap = true
+		for i := range keys {
+			// the first keys may switch to another mode
+			g.Mode().OnKey(&keys[i])
+		}
+		m.noremap = false
+		return
+	}
+
 	// Consequtive non-zero digits specify action multiplier;
 	// accumulate and return. Accept zero only if it's
 	// a non-starting character.
-	if ('0' < ev.Ch && ev.Ch <= '9') || (ev.Ch == '0' && len(m.count) > 0) {
+	if m.prefix == 0 && (('0' < ev.Ch && ev.Ch <= '9') || (ev.Ch == '0' && len(m.count) > 0)) {
 		m.count = m.count + string(ev.Ch)
 		m.editor.SetStatus(m.count)
 		return
@@ -44,6 +88,28 @@ func (m *normalMode) OnKey(ev *termbox.Event) {
 		count = 1
 	}
 
+	if m.prefix != 0 {
+		prefix := m.prefix
+		m.prefix = 0
+		if prefix == '"' {
+			// the count may be typed before or after the register
+			if ev.Ch >= utf8.RuneSelf || !editor.IsCutBufferName(byte(ev.Ch)) {
+				m.editor.SetStatus("Invalid cut buffer: %c", ev.Ch)
+				return
+			}
+			m.register = byte(ev.Ch)
+			return
+		}
+		if prefix == 'z' {
+			// the count of the scroll commands is a line number
+			count = m.givenCount()
+		}
+		m.count = ""
+		m.onPrefixedKey(prefix, ev, count)
+		m.register = 0
+		return
+	}
+
 	// TODO: For (half)screen moving commands, use view.Height() in
 	// future cleanup. Currently, that method is private.
 	viewHeight := g.Height() - 1
@@ -54,11 +120,10 @@ func (m *normalMode) OnKey(ev *termbox.Event) {
 		// TODO Ctrl-U and CTRL-D have configurable ranges of motion.
 		switch ev.Key {
 		case termbox.KeyCtrlA:
-			term := c.WordUnderCursor()
-			if term != nil {
-				storeSearchTerm(g, string(term))
-				g.Commands <- cmd.Search{Dir: cmd.Forward}
-			}
+			// Searching for the word under the cursor, once bound to
+			// Ctrl-A, is done with * and can be restored with
+			// :nmap <C-A> *
+			g.Commands <- cmd.Increment{Start: c, End: c, Delta: count}
 		case termbox.KeyCtrlB:
 			g.Commands <- cmd.MoveView{Dir: cmd.Backward, Lines: viewHeight}
 		case termbox.KeyCtrlD:
@@ -73,7 +138,7 @@ func (m *normalMode) OnKey(ev *termbox.Event) {
 			g.Commands <- cmd.DisplayFileStatus{}
 		case termbox.KeyCtrlH:
 			// Same as 'h'
-			g.Commands <- cmd.Repeat{cmd.MoveRune{Dir: cmd.Backward, Wrap: false}, count}
+			g.Commands <- cmd.Repeat{cmd.MoveRune{Dir: cmd.Backward, Wrap: g.Config.Wraps('b')}, count}
 		case termbox.KeyCtrlJ, termbox.KeyCtrlN:
 			// Same as 'j'
 			g.Commands <- cmd.Repeat{cmd.MoveLine{Dir: cmd.Forward}, count}
@@ -83,6 +148,11 @@ func (m *normalMode) OnKey(ev *termbox.Event) {
 		case termbox.KeyCtrlM:
 			g.Commands <- cmd.MoveLine{Dir: cmd.Forward}
 			g.Commands <- cmd.MoveFOL{}
+		case termbox.KeyCtrlO:
+			g.Commands <- cmd.Jump{Dir: cmd.Backward, Count: count}
+		case termbox.KeyCtrlI:
+			// same as Tab
+			g.Commands <- cmd.Jump{Dir: cmd.Forward, Count: count}
 		case termbox.KeyCtrlP:
 			// same as 'k'
 			g.Commands <- cmd.Repeat{cmd.MoveLine{Dir: cmd.Backward}, count}
@@ -95,11 +165,10 @@ func (m *normalMode) OnKey(ev *termbox.Event) {
 			// TODO: Start visual (block) selection
 			return
 		case termbox.KeyCtrlW:
-			// TODO Use count for window width/height
-			g.SetMode(NewWindowMode(g, count))
+			// the count is a size for the sizing commands
+			g.SetMode(NewWindowMode(g, m.givenCount()))
 		case termbox.KeyCtrlX:
-			// TODO: Move to column count
-			return
+			g.Commands <- cmd.Increment{Start: c, End: c, Delta: -count}
 		case termbox.KeyCtrlY:
 			// TODO: should move by count lines, default to 1
 			g.Commands <- cmd.MoveView{Dir: cmd.Backward, Lines: 1}
@@ -108,7 +177,7 @@ func (m *normalMode) OnKey(ev *termbox.Event) {
 			return
 		case termbox.KeySpace:
 			// Same as 'l'
-			g.Commands <- cmd.Repeat{cmd.MoveRune{Dir: cmd.Forward, Wrap: false}, count}
+			g.Commands <- cmd.Repeat{cmd.MoveRune{Dir: cmd.Forward, Wrap: g.Config.Wraps('s')}, count}
 		}
 	case 'A':
 		g.Commands <- cmd.MoveEOL{}
@@ -124,73 +193,84 @@ func (m *normalMode) OnKey(ev *termbox.Event) {
 	case 'E':
 		// TODO: Distinction from 'e'
 		g.Commands <- cmd.Repeat{cmd.MoveWordEnd{}, count}
-	case 'F':
-		// TODO: Move left to given character
-		return
+	case 'F', 'T', 'f', 't':
+		g.SetMode(newFindCharMode(g, m, ev.Ch, count))
+	case 'r':
+		g.SetMode(newReplaceCharMode(g, m, count))
 	case 'G':
-		// TODO: Move to line #, default last line
-		g.Commands <- cmd.MoveEOF{}
+		// the count is a line number, the last line by default
+		if n := m.givenCount(); n != 0 {
+			g.Commands <- cmd.GoToLine{Line: n}
+		} else {
+			g.Commands <- cmd.GoToLine{Last: true}
+		}
 	case 'H':
-		// TODO: Move to line at the top of the screen
-		return
+		g.Commands <- cmd.MoveScreen{Pos: cmd.ScreenTop, Count: count}
 	case 'I':
 		g.Commands <- cmd.MoveFOL{}
 		g.SetMode(NewInsertMode(g, count))
 	case 'J':
-		// TODO: Join lines, whitespace separated
-		return
+		g.Commands <- cmd.JoinLines{Count: count}
 	case 'K':
 		// TODO: Run keywordprog
 		return
 	case 'L':
-		// TODO: Move to line at the bottom of the screen
-		return
+		g.Commands <- cmd.MoveScreen{Pos: cmd.ScreenBottom, Count: count}
 	case 'M':
-		// TODO: Move to line in the middle of the screen
-		return
+		g.Commands <- cmd.MoveScreen{Pos: cmd.ScreenMiddle}
 	case 'N':
 		g.Commands <- cmd.Search{Dir: cmd.Backward}
 	case 'O':
 		g.Commands <- cmd.Repeat{cmd.NewLine{Dir: cmd.Backward}, count}
 		g.SetMode(NewInsertMode(g, count))
 	case 'P':
-		// TODO: Paste text before cursor
-		return
+		g.Commands <- cmd.Paste{Dir: cmd.Backward, Count: count, Register: m.register}
 	case 'Q':
 		// TODO: Quit to ex mode
 		return
 	case 'R':
-		// TODO: Replace mode
-		return
+		g.SetMode(newReplaceMode(g))
 	case 'S':
 		// TODO: Like 'cc'
 		return
-	case 'T':
-		// TODO: Move left to just before the given character
-		return
 	case 'W':
 		// TODO: Make distinct from 'w'
 		g.Commands <- cmd.Repeat{cmd.MoveWord{Dir: cmd.Forward}, count}
 	case 'X':
-		// TODO: Delete count character to left of cursor
-		return
+		g.Commands <- cmd.CutRunes{Dir: cmd.Backward, Count: count, Register: m.register}
 	case 'Y':
-		// TODO: Yank lines
+		g.Commands <- cmd.YankLines{count}
+	case 'q':
+		if g.Recording() {
+			g.StopRecording()
+			return
+		}
+		m.prefix = ev.Ch
+		return
+	case 'g', '[', ']', 'c', 'z', '@', '`', '\'', '"':
+		// Wait for the rest of the command, keeping the count.
+		m.prefix = ev.Ch
 		return
 	case '0':
 		g.Commands <- cmd.MoveBOL{}
 	case '$':
 		g.Commands <- cmd.MoveEOL{}
+	case '%':
+		g.Commands <- cmd.MovePair{}
+	case ';':
+		g.Commands <- cmd.RepeatFind{Count: count}
+	case ',':
+		g.Commands <- cmd.RepeatFind{Reverse: true, Count: count}
 	case '^':
 		g.Commands <- cmd.MoveFOL{}
 	case 'h':
-		g.Commands <- cmd.Repeat{cmd.MoveRune{Dir: cmd.Backward, Wrap: false}, count}
+		g.Commands <- cmd.Repeat{cmd.MoveRune{Dir: cmd.Backward, Wrap: g.Config.Wraps('h')}, count}
 	case 'j':
 		g.Commands <- cmd.Repeat{cmd.MoveLine{Dir: cmd.Forward}, count}
 	case 'k':
 		g.Commands <- cmd.Repeat{cmd.MoveLine{Dir: cmd.Backward}, count}
 	case 'l':
-		g.Commands <- cmd.Repeat{cmd.MoveRune{Dir: cmd.Forward, Wrap: false}, count}
+		g.Commands <- cmd.Repeat{cmd.MoveRune{Dir: cmd.Forward, Wrap: g.Config.Wraps('l')}, count}
 	case 'o':
 		g.Commands <- cmd.Repeat{cmd.NewLine{Dir: cmd.Forward}, count}
 		g.SetMode(NewInsertMode(g, count))
@@ -201,11 +281,22 @@ func (m *normalMode) OnKey(ev *termbox.Event) {
 	case 'b':
 		g.Commands <- cmd.Repeat{cmd.MoveWord{Dir: cmd.Backward}, count}
 	case 'x':
-		g.Commands <- cmd.Repeat{cmd.DeleteRune{}, count}
+		g.Commands <- cmd.CutRunes{Dir: cmd.Forward, Count: count, Register: m.register}
+	case 'p':
+		g.Commands <- cmd.Paste{Dir: cmd.Forward, Count: count, Register: m.register}
 	case 'u':
 		g.Commands <- cmd.Repeat{cmd.Undo{}, count}
 	case 'n':
 		g.Commands <- cmd.Search{Dir: cmd.Forward}
+	case '*', '#':
+		if term := c.WordUnderCursorFunc(g.Config.WordClass()); term != nil {
+			storeSearchTerm(g, string(term))
+			dir := cmd.Forward
+			if ev.Ch == '#' {
+				dir = cmd.Backward
+			}
+			g.Commands <- cmd.Search{Dir: dir}
+		}
 	}
 
 	switch ev.Ch {
@@ -213,9 +304,17 @@ func (m *normalMode) OnKey(ev *termbox.Event) {
 		g.Commands <- cmd.MoveRune{Dir: cmd.Forward, Wrap: false}
 		g.SetMode(NewInsertMode(g, count))
 	case 'd':
-		g.SetMode(NewTextObjectMode(g, m, v.Buffer().DeleteRange, count))
+		g.SetMode(NewTextObjectMode(g, m, 'd', v.Buffer().DeleteRange, count))
+	case 'y':
+		g.SetMode(NewTextObjectMode(g, m, 'y', yankRange(g), count))
 	case 'i':
 		g.SetMode(NewInsertMode(g, count))
+	case '=':
+		g.SetMode(NewTextObjectMode(g, m, '=', formatLines(g), count))
+	case '>':
+		g.SetMode(NewTextObjectMode(g, m, '>', shiftLines(g, cmd.Forward), count))
+	case '<':
+		g.SetMode(NewTextObjectMode(g, m, '<', shiftLines(g, cmd.Backward), count))
 	case 'v':
 		g.SetMode(NewVisualMode(g, false))
 	case 'V':
@@ -229,7 +328,221 @@ func (m *normalMode) OnKey(ev *termbox.Event) {
 
 	// Reset repetitions
 	m.count = ""
+	m.register = 0
+}
+
+// endOneShot goes back to insert mode once a command typed after Ctrl-O is
+// complete. Commands switching to another mode are complete when it returns
+// to this one.
+func (m *normalMode) endOneShot() {
+	if m.count == "" && m.prefix == 0 && m.register == 0 && m.editor.Mode() == m {
+		m.editor.SetMode(m.oneShot)
+	}
+}
+
+// givenCount returns the count typed before the command, 0 if none was.
+func (m *normalMode) givenCount() int {
+	if m.count == "" {
+		return 0
+	}
+	return utils.ParseCount(m.count)
+}
+
+// onPrefixedKey handles the key completing a multi-key command started
+// with the prefix key.
+func (m *normalMode) onPrefixedKey(prefix rune, ev *termbox.Event, count int) {
+	g := m.editor
+
+	switch prefix {
+	case 'g':
+		switch ev.Key {
+		case termbox.KeyCtrlG:
+			g.Commands <- cmd.DisplayWordCount{}
+		}
+		switch ev.Ch {
+		case 'c':
+			g.SetMode(NewTextObjectMode(g, m, 'c', commentLines(g), count))
+		case 'd':
+			g.Commands <- cmd.GotoDeclaration{}
+		case 'f':
+			g.Commands <- cmd.GotoFile{}
+		case 'g':
+			// the count is a line number, the first line by default
+			g.Commands <- cmd.GoToLine{Line: count}
+		case ';':
+			g.Commands <- cmd.MoveChange{Dir: cmd.Backward, Count: count}
+		case ',':
+			g.Commands <- cmd.MoveChange{Dir: cmd.Forward, Count: count}
+		case 'J':
+			g.Commands <- cmd.JoinLines{Count: count, Raw: true}
+		}
+	case '`', '\'':
+		g.Commands <- cmd.MoveMark{Mark: ev.Ch, Linewise: prefix == '\''}
+	case 'q':
+		if err := g.StartRecording(byte(ev.Ch)); err != nil {
+			g.SetStatus("%s", err)
+		}
+	case '@':
+		if err := g.ExecuteMacro(byte(ev.Ch), count); err != nil {
+			g.SetStatus("%s", err)
+		}
+	case 'z':
+		if ev.Key == termbox.KeyEnter {
+			g.Commands <- cmd.Scroll{Pos: cmd.ScrollTop, Line: count, FirstNonBlank: true}
+		}
+		switch ev.Ch {
+		case '=':
+			if s := newSpellMode(g, m); s != nil {
+				g.SetMode(s)
+			}
+		case 't':
+			g.Commands <- cmd.Scroll{Pos: cmd.ScrollTop, Line: count}
+		case 'z':
+			g.Commands <- cmd.Scroll{Pos: cmd.ScrollCenter, Line: count}
+		case 'b':
+			g.Commands <- cmd.Scroll{Pos: cmd.ScrollBottom, Line: count}
+		case '.':
+			g.Commands <- cmd.Scroll{Pos: cmd.ScrollCenter, Line: count, FirstNonBlank: true}
+		case '-':
+			g.Commands <- cmd.Scroll{Pos: cmd.ScrollBottom, Line: count, FirstNonBlank: true}
+		case 's':
+			g.Commands <- cmd.Scroll{Pos: cmd.ScrollLeftEdge}
+		case 'e':
+			g.Commands <- cmd.Scroll{Pos: cmd.ScrollRightEdge}
+		}
+	case 'c':
+		switch ev.Ch {
+		case 's':
+			g.SetMode(newSurroundMode(g, m, 'c'))
+		case 'w':
+			g.Commands <- cmd.ChangeWord{Count: count}
+			g.SetMode(NewInsertMode(g, 1))
+		}
+	case '[', ']':
+		switch ev.Ch {
+		case 's':
+			dir := cmd.Backward
+			if prefix == ']' {
+				dir = cmd.Forward
+			}
+			g.Commands <- cmd.Repeat{cmd.MoveMisspelling{dir}, count}
+		case 'p', 'P':
+			dir := cmd.Backward
+			if prefix == ']' && ev.Ch == 'p' {
+				dir = cmd.Forward
+			}
+			g.Commands <- cmd.Paste{Dir: dir, Count: count, Register: m.register, Indent: true}
+		}
+	}
 }
 
 func (m *normalMode) Exit() {
 }
+
+// PendingCommand returns the register, the count and the prefix key typed so
+// far.
+func (m *normalMode) PendingCommand() string {
+	var s string
+	if m.register != 0 {
+		s = "\"" + string(m.register)
+	}
+	s += m.count
+	if m.prefix != 0 {
+		s += string(m.prefix)
+	}
+	return s
+}
+
+// StartDrag starts a visual selection where the mouse button was pressed.
+func (m *normalMode) StartDrag() {
+	m.editor.SetMode(NewVisualMode(m.editor, false))
+}
+
+// formatLines returns a function formatting the lines between two cursors
+// with the equalprg program, or by adjusting their indentation if it is not
+// set.
+func formatLines(e *editor.Editor) buffer.RangeFunc {
+	return func(from, to buffer.Cursor) {
+		from, to = buffer.SortCursors(from, to)
+		if prg := e.Config.EqualPrg; prg != "" {
+			if err := filterLines(e, &lineRange{from.LineNum, to.LineNum}, prg); err != nil {
+				e.SetStatus("error: %s", err)
+			}
+			return
+		}
+
+		b := e.ActiveView().Buffer()
+		b.FinalizeActionGroup()
+		b.Reindent(from.LineNum, to.LineNum, e.Config.ShiftWidth, e.Config.View.TabStop, e.Config.ExpandTab)
+		b.FinalizeActionGroup()
+	}
+}
+
+// shiftLines returns a function changing the indentation of the lines between
+// two cursors by one shiftwidth, in the direction dir.
+func shiftLines(e *editor.Editor, dir cmd.Dir) buffer.RangeFunc {
+	return func(from, to buffer.Cursor) {
+		from, to = buffer.SortCursors(from, to)
+		// the lines are counted from the cursor line
+		e.ActiveView().MoveCursorTo(from)
+		n := to.LineNum - from.LineNum + 1
+		if dir == cmd.Forward {
+			e.Commands <- cmd.Indent{Count: n}
+		} else {
+			e.Commands <- cmd.Deindent{Count: n}
+		}
+	}
+}
+
+// yankRange returns a function copying the text between two cursors into the
+// unnamed cut buffer, and moving the cursor to the start of the text.
+func yankRange(e *editor.Editor) buffer.RangeFunc {
+	return func(from, to buffer.Cursor) {
+		from, to = buffer.SortCursors(from, to)
+		e.Yank('"', from.ExtractBytes(from.Distance(to)), false)
+		b := e.ActiveView().Buffer()
+		b.SetMark('[', from)
+		b.SetMark(']', to)
+		e.ActiveView().MoveCursorTo(from)
+	}
+}
+
+// commentLines returns a function toggling the comments of the lines between
+// two cursors.
+func commentLines(e *editor.Editor) buffer.RangeFunc {
+	return func(from, to buffer.Cursor) {
+		from, to = buffer.SortCursors(from, to)
+		b := e.ActiveView().Buffer()
+		b.FinalizeActionGroup()
+		defer b.FinalizeActionGroup()
+
+		n := to.LineNum - from.LineNum + 1
+		if b.ToggleComment(from.LineNum, to.LineNum, commentPrefix(b.Path)) {
+			e.SetStatus("%d lines commented", n)
+		} else {
+			e.SetStatus("%d lines uncommented", n)
+		}
+	}
+}
+
+// hashCommentExts are the extensions of the files with comments starting
+// with #. Other files use //.
+var hashCommentExts = map[string]bool{
+	".bash": true,
+	".conf": true,
+	".pl":   true,
+	".py":   true,
+	".rb":   true,
+	".sh":   true,
+	".toml": true,
+	".yaml": true,
+	".yml":  true,
+}
+
+// commentPrefix returns the prefix of line comments in the file at path.
+func commentPrefix(path string) []byte {
+	if hashCommentExts[filepath.Ext(path)] || filepath.Base(path) == "Makefile" {
+		return []byte("#")
+	}
+	return []byte("//")
+}
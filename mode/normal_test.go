@@ -0,0 +1,296 @@
+package mode
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/kisielk/vigo/editor"
+	"github.com/nsf/tulib"
+)
+
+// newTestEditor returns an editor in normal mode editing a file holding the
+// text, in a view of 10 lines, and a function cleaning up after it.
+func newTestEditor(t *testing.T, text string) (*editor.Editor, func()) {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "vigo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	home := os.Getenv("HOME")
+	os.Setenv("HOME", dir)
+	path := filepath.Join(dir, "file")
+	if err := ioutil.WriteFile(path, []byte(text), 0600); err != nil {
+		t.Fatal(err)
+	}
+	e := editor.NewEditor([]string{path})
+	e.ActiveViewNode().Resize(tulib.Rect{Width: 80, Height: 11})
+	e.SetMode(NewNormalMode(e))
+	return e, func() {
+		e.ActiveView().Detach()
+		os.Setenv("HOME", home)
+		os.RemoveAll(dir)
+	}
+}
+
+// typeKeys runs the keys, written as for :nmap, in the mode of the editor.
+func typeKeys(t *testing.T, e *editor.Editor, keys string) {
+	t.Helper()
+	events, err := editor.ParseKeys(keys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := e.ExecuteKeys(events); err != nil {
+		t.Fatal(err)
+	}
+	e.ActiveView().Sync()
+}
+
+// contents returns the text of the buffer of the active view.
+func contents(t *testing.T, e *editor.Editor) string {
+	t.Helper()
+	b := e.ActiveView().Buffer()
+	data, err := ioutil.ReadAll(b.LinesReader(1, b.NumLines))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+// numberedLines returns the text of n lines holding their number.
+func numberedLines(n int) string {
+	var s []string
+	for i := 1; i <= n; i++ {
+		s = append(s, fmt.Sprintf("line %d", i))
+	}
+	return strings.Join(s, "\n")
+}
+
+func TestGoToLine(t *testing.T) {
+	for _, test := range []struct {
+		keys string
+		line int
+	}{
+		{"G", 5},
+		{"3G", 3},
+		{"Ggg", 1},
+		{"G2gg", 2},
+		{"9G", 5},
+	} {
+		e, done := newTestEditor(t, "a\nb\nc\nd\ne")
+		typeKeys(t, e, test.keys)
+		if got := e.ActiveView().Cursor().LineNum; got != test.line {
+			t.Errorf("%s: got line %d, want %d", test.keys, got, test.line)
+		}
+		done()
+	}
+
+	e, done := newTestEditor(t, "a\nb\nc\nd\ne")
+	defer done()
+	typeKeys(t, e, "Gdd")
+	if got, want := contents(t, e), "a\nb\nc\nd"; got != want {
+		t.Errorf("Gdd: got %q, want %q", got, want)
+	}
+}
+
+func TestScrollCount(t *testing.T) {
+	for _, test := range []struct {
+		keys      string
+		line, top int
+	}{
+		// without a count the cursor line is scrolled
+		{"20Gzt", 20, 20},
+		{"20Gzz", 20, 15},
+		{"20Gzb", 20, 11},
+		{"20Gz<CR>", 20, 20},
+		// with a count the line is first made the cursor line
+		{"20G5zt", 5, 5},
+		{"5G20zb", 20, 11},
+	} {
+		e, done := newTestEditor(t, numberedLines(30))
+		typeKeys(t, e, test.keys)
+		v := e.ActiveView()
+		top, _ := v.VisibleRange()
+		if line := v.Cursor().LineNum; line != test.line || top != test.top {
+			t.Errorf("%s: got line %d at the top line %d, want %d at %d", test.keys, line, top, test.line, test.top)
+		}
+		done()
+	}
+}
+
+func TestYankWord(t *testing.T) {
+	for _, test := range []struct {
+		text, keys, want string
+	}{
+		// the last word of a line is yanked without its newline
+		{"abc\ndef", "yw$p", "abcabc\ndef"},
+		{"abc def", "wywP", "abc defdef"},
+		{"abc def\nghi", "2ywjP", "abc def\nabc defghi"},
+		{"abc def", "ywP", "abc abc def"},
+	} {
+		e, done := newTestEditor(t, test.text)
+		typeKeys(t, e, test.keys)
+		if got := contents(t, e); got != test.want {
+			t.Errorf("%s on %q: got %q, want %q", test.keys, test.text, got, test.want)
+		}
+		done()
+	}
+}
+
+func TestShiftLines(t *testing.T) {
+	for _, test := range []struct {
+		text, keys, want string
+	}{
+		// exactly one shiftwidth, without rounding
+		{"  a", ">>", "\t  a"},
+		{"\t  a", "<<", "  a"},
+		{"  a", "<<", "a"},
+		{"a", "<<", "a"},
+		{"a\n\nb\nc", "3>>", "\ta\n\n\tb\nc"},
+		{"a\nb\nc", "j>k", "\ta\n\tb\nc"},
+		{"a\nb", "5>>", "\ta\n\tb"},
+	} {
+		e, done := newTestEditor(t, test.text)
+		typeKeys(t, e, test.keys)
+		if got := contents(t, e); got != test.want {
+			t.Errorf("%s on %q: got %q, want %q", test.keys, test.text, got, test.want)
+		}
+		done()
+	}
+}
+
+func TestGotoDeclaration(t *testing.T) {
+	text := "x := 0\nfunc f() {\n\txx := 1\n\tx := xx\n\ty := x\n}"
+	for _, test := range []struct {
+		keys         string
+		line, offset int
+	}{
+		// not the x above the function
+		{"5G$gd", 4, 1},
+		// the word under the cursor is the first occurrence
+		{"4Gwgd", 4, 1},
+		{"3Gwgd", 3, 1},
+	} {
+		e, done := newTestEditor(t, text)
+		typeKeys(t, e, test.keys)
+		if c := e.ActiveView().Cursor(); c.LineNum != test.line || c.Boffset != test.offset {
+			t.Errorf("%s: got cursor at %d:%d, want %d:%d", test.keys, c.LineNum, c.Boffset, test.line, test.offset)
+		}
+		done()
+	}
+}
+
+func TestChangeWord(t *testing.T) {
+	for _, test := range []struct {
+		cpo, text, keys, want string
+	}{
+		// a word up to its end, as ce does
+		{"_", "abc  def", "cwx<Esc>", "x  def"},
+		// with the blanks after it, as dw does
+		{"", "abc  def", "cwx<Esc>", "xdef"},
+		{"", "abc\ndef", "cwx<Esc>", "x\ndef"},
+		{"_", "abc def ghi", "2cwx<Esc>", "x ghi"},
+		// all the blanks, or only the one under the cursor
+		{"_", "abc  def", "3lcwx<Esc>", "abcxdef"},
+		{"w_", "abc  def", "3lcwx<Esc>", "abcx def"},
+	} {
+		e, done := newTestEditor(t, test.text)
+		e.Config.CpOptions = test.cpo
+		typeKeys(t, e, test.keys)
+		if got := contents(t, e); got != test.want {
+			t.Errorf("%s on %q with cpo=%s: got %q, want %q", test.keys, test.text, test.cpo, got, test.want)
+		}
+		done()
+	}
+}
+
+func TestMoveWordEOF(t *testing.T) {
+	for _, test := range []struct {
+		cpo    string
+		offset int
+	}{
+		// to the last character
+		{"_", 6},
+		// or nowhere
+		{"W", 4},
+	} {
+		e, done := newTestEditor(t, "abc def")
+		e.Config.CpOptions = test.cpo
+		typeKeys(t, e, "ww")
+		if c := e.ActiveView().Cursor(); c.Boffset != test.offset {
+			t.Errorf("cpo=%s: got cursor at %d, want %d", test.cpo, c.Boffset, test.offset)
+		}
+		done()
+	}
+}
+
+func TestReplaceMode(t *testing.T) {
+	for _, test := range []struct {
+		text, keys, want string
+		offset           int
+	}{
+		// back on the last character typed
+		{"abcd", "Rxy<Esc>", "xycd", 1},
+		{"abcd", "lRxyz<BS><Esc>", "axyd", 2},
+		{"ab", "lRxyz<Esc>", "axyz", 3},
+		{"abcd", "llR<Esc>", "abcd", 2},
+	} {
+		e, done := newTestEditor(t, test.text)
+		typeKeys(t, e, test.keys)
+		if got := contents(t, e); got != test.want {
+			t.Errorf("%s on %q: got %q, want %q", test.keys, test.text, got, test.want)
+		}
+		if c := e.ActiveView().Cursor(); c.Boffset != test.offset {
+			t.Errorf("%s on %q: got cursor at %d, want %d", test.keys, test.text, c.Boffset, test.offset)
+		}
+		done()
+	}
+}
+
+func TestSearchOverlapping(t *testing.T) {
+	e, done := newTestEditor(t, "aaaa")
+	defer done()
+	typeKeys(t, e, "/aa<CR>")
+	// searches don't wrap around the end of the buffer
+	for _, want := range []int{1, 2, 2} {
+		if c := e.ActiveView().Cursor(); c.Boffset != want {
+			t.Errorf("got cursor at %d, want %d", c.Boffset, want)
+		}
+		typeKeys(t, e, "n")
+	}
+}
+
+func TestWindowCancel(t *testing.T) {
+	e, done := newTestEditor(t, "a")
+	defer done()
+	typeKeys(t, e, "<C-w>s<C-w><C-c>")
+	if e.ActiveViewNode().Parent() == nil {
+		t.Errorf("Ctrl-W Ctrl-C closed the view")
+	}
+	typeKeys(t, e, "<C-w>c")
+	if e.ActiveViewNode().Parent() != nil {
+		t.Errorf("Ctrl-W c didn't close the view")
+	}
+}
+
+func TestInsertLiteral(t *testing.T) {
+	for _, test := range []struct {
+		keys, want string
+	}{
+		{"i<C-v>u00e9<Esc>", "é"},
+		{"i<C-v>65<Esc>", "A"},
+		{"i<C-v>ux<Esc>", "ux"},
+		{"i<C-v>u<Esc>", "u"},
+		{"i<C-v>u4g<Esc>", "\x04g"},
+	} {
+		e, done := newTestEditor(t, "")
+		typeKeys(t, e, test.keys)
+		if got := contents(t, e); got != test.want {
+			t.Errorf("%s: got %q, want %q", test.keys, got, test.want)
+		}
+		done()
+	}
+}
@@ -0,0 +1,55 @@
+package mode
+
+import (
+	cmd "github.com/kisielk/vigo/commands"
+	"github.com/kisielk/vigo/editor"
+	"github.com/nsf/termbox-go"
+)
+
+// replaceMode overwrites the characters under the cursor with the typed ones,
+// as entered by R. Backspace restores the characters overwritten since the
+// mode was entered, and the whole overwrite is undone at once.
+type replaceMode struct {
+	editor *editor.Editor
+
+	// characters overwritten so far, 0 for the ones appended at the end of
+	// lines and line breaks
+	replaced []rune
+}
+
+func newReplaceMode(e *editor.Editor) *replaceMode {
+	return &replaceMode{editor: e}
+}
+
+func (m *replaceMode) Enter(e *editor.Editor) {
+	e.SetStatus("Replace")
+	e.Commands <- cmd.BreakUndo{}
+}
+
+func (m *replaceMode) OnKey(ev *termbox.Event) {
+	g := m.editor
+	switch ev.Key {
+	case termbox.KeyEsc, termbox.KeyCtrlC:
+		g.Commands <- cmd.BreakUndo{}
+		// back on the last character typed
+		if c := g.ActiveView().Cursor(); len(m.replaced) > 0 && !c.BOL() {
+			g.Commands <- cmd.MoveRune{Dir: cmd.Backward}
+		}
+		g.SetMode(NewNormalMode(g))
+	case termbox.KeyBackspace, termbox.KeyBackspace2:
+		g.Commands <- cmd.RestoreRune{Replaced: &m.replaced}
+	case termbox.KeySpace:
+		g.Commands <- cmd.OverwriteRune{Rune: ' ', Replaced: &m.replaced}
+	case termbox.KeyTab:
+		g.Commands <- cmd.OverwriteRune{Rune: '\t', Replaced: &m.replaced}
+	case termbox.KeyEnter:
+		g.Commands <- cmd.OverwriteRune{Rune: '\n', Replaced: &m.replaced}
+	default:
+		if ev.Ch != 0 {
+			g.Commands <- cmd.OverwriteRune{Rune: ev.Ch, Replaced: &m.replaced}
+		}
+	}
+}
+
+func (m *replaceMode) Exit() {
+}
@@ -0,0 +1,56 @@
+package mode
+
+import (
+	"strconv"
+
+	cmd "github.com/kisielk/vigo/commands"
+	"github.com/kisielk/vigo/editor"
+	"github.com/nsf/termbox-go"
+)
+
+// replaceCharMode reads the character typed after r, which replaces the
+// characters under the cursor.
+type replaceCharMode struct {
+	editor *editor.Editor
+	mode   editor.Mode // mode to go back to
+	count  int
+}
+
+func newReplaceCharMode(e *editor.Editor, mode editor.Mode, count int) *replaceCharMode {
+	return &replaceCharMode{editor: e, mode: mode, count: count}
+}
+
+func (m *replaceCharMode) Enter(e *editor.Editor) {
+}
+
+func (m *replaceCharMode) OnKey(ev *termbox.Event) {
+	g := m.editor
+	r := ev.Ch
+	if r == 0 {
+		switch ev.Key {
+		case termbox.KeySpace:
+			r = ' '
+		case termbox.KeyTab:
+			r = '\t'
+		case termbox.KeyEnter:
+			r = '\n'
+		default:
+			// Esc or any other key cancels the command
+			g.SetMode(m.mode)
+			return
+		}
+	}
+	g.Commands <- cmd.ReplaceChar{Char: r, Count: m.count}
+	g.SetMode(m.mode)
+}
+
+func (m *replaceCharMode) Exit() {
+}
+
+func (m *replaceCharMode) PendingCommand() string {
+	s := "r"
+	if m.count > 1 {
+		s = strconv.Itoa(m.count) + s
+	}
+	return s
+}
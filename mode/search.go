@@ -3,7 +3,9 @@ package mode
 import (
 	"bytes"
 	"strings"
+	"unicode/utf8"
 
+	"github.com/kisielk/vigo/buffer"
 	cmd "github.com/kisielk/vigo/commands"
 	"github.com/kisielk/vigo/editor"
 	"github.com/nsf/termbox-go"
@@ -14,53 +16,104 @@ type SearchMode struct {
 	editor *editor.Editor
 	mode   editor.Mode
 	buffer *bytes.Buffer
+
+	register bool          // Ctrl-R was pressed, waiting for the cut buffer name
+	start    buffer.Cursor // cursor position before the incsearch preview
 }
 
-func NewSearchMode(editor *editor.Editor, mode editor.Mode) SearchMode {
-	m := SearchMode{editor: editor, mode: mode, buffer: &bytes.Buffer{}}
+func NewSearchMode(editor *editor.Editor, mode editor.Mode) *SearchMode {
+	m := &SearchMode{editor: editor, mode: mode, buffer: &bytes.Buffer{}}
+	m.start = editor.ActiveView().Cursor()
 	return m
 }
 
-func (m SearchMode) Enter(e *editor.Editor) {
+func (m *SearchMode) Enter(e *editor.Editor) {
 }
 
-func (m SearchMode) NeedsCursor() bool {
+func (m *SearchMode) NeedsCursor() bool {
 	return true
 }
 
-func (m SearchMode) CursorPosition() (int, int) {
+func (m *SearchMode) CursorPosition() (int, int) {
 	e := m.editor
 	return m.buffer.Len() + 1, e.Height() - 1
 }
 
-func (m SearchMode) OnKey(ev *termbox.Event) {
+func (m *SearchMode) OnKey(ev *termbox.Event) {
+	if m.register {
+		m.register = false
+		insertCutBuffer(m.editor, m.buffer, ev)
+		m.preview(m.term())
+		return
+	}
+
 	switch ev.Key {
 	case termbox.KeyEsc, termbox.KeyCtrlC:
+		m.preview("")
 		m.editor.SetMode(m.mode)
+		return
 	case termbox.KeyBackspace, termbox.KeyBackspace2:
 		l := m.buffer.Len()
 		if l > 0 {
 			m.buffer.Truncate(l - 1)
 		}
 	case termbox.KeyEnter:
-		c := m.buffer.String()
-		term := strings.Fields(c)[0]
-		storeSearchTerm(m.editor, term)
+		// search from where the cursor was before the preview
+		m.preview("")
+		storeSearchTerm(m.editor, m.term())
 		m.editor.Commands <- cmd.Search{Dir: cmd.Forward}
 		m.editor.SetMode(m.mode)
+		return
+	case termbox.KeyCtrlR:
+		m.register = true
+		return
 	case termbox.KeySpace:
 		m.buffer.WriteRune(' ')
 	default:
 		m.buffer.WriteRune(ev.Ch)
 	}
+	m.preview(m.term())
 }
 
-func (m SearchMode) Exit() {}
+// term returns the search term typed so far.
+func (m *SearchMode) term() string {
+	fields := strings.Fields(m.buffer.String())
+	if len(fields) == 0 {
+		return ""
+	}
+	return fields[0]
+}
 
-func (m SearchMode) Draw() {
+// preview moves the cursor to the first match of term when the incsearch
+// option is set, or back to where it was if term is empty.
+func (m *SearchMode) preview(term string) {
+	if m.editor.Config.IncSearch {
+		m.editor.Commands <- cmd.SearchPreview{From: m.start, Term: term}
+	}
+}
+
+func (m *SearchMode) Exit() {}
+
+func (m *SearchMode) Draw() {
 	m.editor.DrawStatus([]byte("/" + m.buffer.String()))
 }
 
+// insertCutBuffer appends the first line of the cut buffer named by the
+// key event ev to the command line buffer.
+func insertCutBuffer(e *editor.Editor, buffer *bytes.Buffer, ev *termbox.Event) {
+	if ev.Ch >= utf8.RuneSelf {
+		return
+	}
+	s, ok := e.CutBuffer(byte(ev.Ch))
+	if !ok {
+		return
+	}
+	if i := bytes.IndexByte(s, '\n'); i != -1 {
+		s = s[:i]
+	}
+	buffer.Write(s)
+}
+
 // Store the search term on the editor instance.
 // This allows us to use it later in other commands.
 func storeSearchTerm(e *editor.Editor, term string) {
@@ -69,5 +122,7 @@ func storeSearchTerm(e *editor.Editor, term string) {
 		return
 	}
 	e.LastSearchTerm = term
-	e.ActiveView().SetHighlightBytes([]byte(term))
+	if e.Config.HLSearch {
+		e.ActiveView().SetHighlightBytes([]byte(term))
+	}
 }
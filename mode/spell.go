@@ -0,0 +1,95 @@
+package mode
+
+import (
+	"fmt"
+
+	cmd "github.com/kisielk/vigo/commands"
+	"github.com/kisielk/vigo/editor"
+	"github.com/nsf/termbox-go"
+)
+
+// maxSuggestions is the number of spelling suggestions offered by z=, each
+// picked with its digit.
+const maxSuggestions = 9
+
+// spellMode shows the suggestions to replace the word under the cursor, one of
+// which is picked with its number, or by cycling through them with Tab and
+// confirming with Enter.
+type spellMode struct {
+	editor      *editor.Editor
+	mode        editor.Mode
+	word        string
+	suggestions []string
+	selected    int
+}
+
+// newSpellMode returns the mode offering suggestions for the word under the
+// cursor, or nil after reporting why there are none.
+func newSpellMode(e *editor.Editor, mode editor.Mode) *spellMode {
+	d := e.Config.View.Dictionary
+	if !e.Config.View.Spell || d == nil {
+		e.SetStatus("Spell checking is not enabled")
+		return nil
+	}
+	c := e.ActiveView().Cursor()
+	word := c.WordUnderCursorFunc(e.Config.WordClass())
+	if word == nil {
+		e.SetStatus("No word under cursor")
+		return nil
+	}
+	s := d.Suggest(string(word), maxSuggestions)
+	if len(s) == 0 {
+		e.SetStatus("No suggestions for %s", word)
+		return nil
+	}
+	return &spellMode{editor: e, mode: mode, word: string(word), suggestions: s}
+}
+
+func (m *spellMode) Enter(e *editor.Editor) {
+}
+
+func (m *spellMode) OnKey(ev *termbox.Event) {
+	g := m.editor
+	switch {
+	case '1' <= ev.Ch && ev.Ch <= '9':
+		i := int(ev.Ch - '1')
+		if i >= len(m.suggestions) {
+			return
+		}
+		m.selected = i
+		fallthrough
+	case ev.Key == termbox.KeyEnter:
+		g.Commands <- cmd.ReplaceWord{[]byte(m.suggestions[m.selected])}
+	case ev.Key == termbox.KeyTab || ev.Key == termbox.KeyCtrlN:
+		m.selected = (m.selected + 1) % len(m.suggestions)
+		return
+	case ev.Key == termbox.KeyCtrlP:
+		m.selected = (m.selected + len(m.suggestions) - 1) % len(m.suggestions)
+		return
+	}
+	// any other key cancels
+	g.SetMode(m.mode)
+}
+
+func (m *spellMode) Exit() {
+}
+
+func (m *spellMode) NeedsCursor() bool {
+	return false
+}
+
+func (m *spellMode) CursorPosition() (int, int) {
+	return 0, 0
+}
+
+func (m *spellMode) OnResize(ev *termbox.Event) {
+}
+
+func (m *spellMode) Draw() {
+	items := make([]string, len(m.suggestions))
+	for i, s := range m.suggestions {
+		items[i] = fmt.Sprintf("%d %s", i+1, s)
+	}
+	m.editor.DrawMenu(items, m.selected)
+	m.editor.DrawStatus([]byte(fmt.Sprintf("Change %q to: (1-%d, Tab, Enter)", m.word, len(items))))
+}
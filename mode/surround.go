@@ -0,0 +1,74 @@
+package mode
+
+import (
+	"github.com/kisielk/vigo/buffer"
+	cmd "github.com/kisielk/vigo/commands"
+	"github.com/kisielk/vigo/editor"
+	"github.com/nsf/termbox-go"
+)
+
+// surroundMode reads the characters naming the pairs of a surround command:
+// ys{motion}{char}, cs{old}{new} or ds{char}.
+type surroundMode struct {
+	editor *editor.Editor
+	mode   editor.Mode // mode to go back to
+	op     rune        // 'y', 'c' or 'd'
+
+	// range of the motion of ys
+	from, to buffer.Cursor
+	ranged   bool
+
+	old rune // first character of cs
+}
+
+func newSurroundMode(e *editor.Editor, mode editor.Mode, op rune) *surroundMode {
+	return &surroundMode{editor: e, mode: mode, op: op}
+}
+
+// setRange is used as the function of the text object mode reading the motion
+// of ys, before this mode is entered.
+func (m *surroundMode) setRange(from, to buffer.Cursor) {
+	m.from, m.to = buffer.SortCursors(from, to)
+	m.ranged = true
+}
+
+func (m *surroundMode) Enter(e *editor.Editor) {
+	if m.op == 'y' && !m.ranged {
+		// the motion was invalid
+		e.SetMode(m.mode)
+	}
+}
+
+func (m *surroundMode) OnKey(ev *termbox.Event) {
+	g := m.editor
+	if ev.Ch == 0 {
+		// Esc or any other key cancels the command
+		g.SetMode(m.mode)
+		return
+	}
+
+	switch m.op {
+	case 'y':
+		g.Commands <- cmd.Surround{Start: m.from, End: m.to, Char: ev.Ch}
+	case 'd':
+		g.Commands <- cmd.DeleteSurround{ev.Ch}
+	case 'c':
+		if m.old == 0 {
+			m.old = ev.Ch
+			return
+		}
+		g.Commands <- cmd.ChangeSurround{m.old, ev.Ch}
+	}
+	g.SetMode(m.mode)
+}
+
+func (m *surroundMode) Exit() {
+}
+
+func (m *surroundMode) PendingCommand() string {
+	s := string(m.op) + "s"
+	if m.old != 0 {
+		s += string(m.old)
+	}
+	return s
+}
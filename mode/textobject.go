@@ -2,6 +2,7 @@ package mode
 
 import (
 	"errors"
+	"strconv"
 
 	"github.com/kisielk/vigo/buffer"
 	"github.com/kisielk/vigo/editor"
@@ -12,6 +13,7 @@ import (
 type TextObjectMode struct {
 	editor *editor.Editor
 	mode   editor.Mode
+	op     rune // key of the operator, repeating it selects whole lines
 	object textObject
 	stage  textObjectStage // Text object parsing stage
 	err    error           // Set in case of error during text object parsing.
@@ -23,6 +25,9 @@ type TextObjectMode struct {
 	outerCount int    // Outer count preceding the initial command.
 	countChars []rune // Temporary buffer for inner repetition digits.
 	count      int    // Inner repetitions
+	keys       []rune // Keys typed after the operator.
+
+	surround bool // ds was typed, the surround mode handles the rest
 }
 
 type textObjectStage int
@@ -50,6 +55,12 @@ const (
 	textObjectPercent
 	textObjectParens
 	textObjectBraces
+
+	// Line-wise motions.
+	textObjectLines // the operator key repeated
+	textObjectLinesDown
+	textObjectLinesUp
+	textObjectLinesEOF
 )
 
 var textObjectKeyToType = map[rune]textObjectKind{
@@ -61,12 +72,16 @@ var textObjectKeyToType = map[rune]textObjectKind{
 	'%': textObjectPercent,
 	'b': textObjectParens,
 	'B': textObjectBraces,
+	'j': textObjectLinesDown,
+	'k': textObjectLinesUp,
+	'G': textObjectLinesEOF,
 }
 
-func NewTextObjectMode(editor *editor.Editor, mode editor.Mode, f buffer.RangeFunc, count int) *TextObjectMode {
+func NewTextObjectMode(editor *editor.Editor, mode editor.Mode, op rune, f buffer.RangeFunc, count int) *TextObjectMode {
 	return &TextObjectMode{
 		editor:     editor,
 		mode:       mode,
+		op:         op,
 		object:     textObject{},
 		stage:      textObjectStageReps,
 		f:          f,
@@ -80,6 +95,9 @@ func (m *TextObjectMode) Enter(e *editor.Editor) {
 var ErrBadTextObject error = errors.New("bad text object")
 
 func (m *TextObjectMode) OnKey(ev *termbox.Event) {
+	if ev.Ch != 0 {
+		m.keys = append(m.keys, ev.Ch)
+	}
 loop:
 	switch m.stage {
 	case textObjectStageReps:
@@ -92,6 +110,21 @@ loop:
 		}
 	case textObjectStageChar1:
 		switch ev.Ch {
+		case 's':
+			if m.op == 'd' && len(m.countChars) == 0 {
+				m.surround = true
+				m.editor.SetMode(newSurroundMode(m.editor, m.mode, 'd'))
+				return
+			}
+			if m.op == 'y' && len(m.countChars) == 0 {
+				// ys reads a motion of its own
+				m.surround = true
+				s := newSurroundMode(m.editor, m.mode, 'y')
+				m.editor.SetMode(NewTextObjectMode(m.editor, s, 's', s.setRange, m.outerCount))
+				return
+			}
+			m.stage = textObjectStageChar2
+			goto loop
 		case 'i':
 			m.object.inner = true
 		case 'a':
@@ -101,7 +134,9 @@ loop:
 			goto loop
 		}
 	case textObjectStageChar2:
-		if kind, ok := textObjectKeyToType[ev.Ch]; ok {
+		if ev.Ch == m.op && !m.object.inner {
+			m.object.kind = textObjectLines
+		} else if kind, ok := textObjectKeyToType[ev.Ch]; ok {
 			m.object.kind = kind
 		} else {
 			m.err = ErrBadTextObject
@@ -110,7 +145,20 @@ loop:
 	}
 }
 
+// PendingCommand returns the outer count, the operator and the keys typed
+// after it.
+func (m *TextObjectMode) PendingCommand() string {
+	s := string(m.op) + string(m.keys)
+	if m.outerCount > 1 {
+		s = strconv.Itoa(m.outerCount) + s
+	}
+	return s
+}
+
 func (m *TextObjectMode) Exit() {
+	if m.surround {
+		return
+	}
 	if m.err != nil {
 		m.editor.SetStatus(m.err.Error())
 		return
@@ -120,16 +168,113 @@ func (m *TextObjectMode) Exit() {
 
 	switch m.object.kind {
 	case textObjectWord:
-		for i := 0; i < m.count*m.outerCount; i++ {
-			from := v.Cursor()
-			to := v.Cursor()
-			// FIXME this wraps onto next line
-			if !to.NextWord() {
-				v.SetStatus("End of buffer")
+		from, to := v.Cursor(), v.Cursor()
+		n := m.count * m.outerCount
+		for i := 0; i < n; i++ {
+			next := to
+			ok := next.NextWordFunc(m.editor.Config.WordClass())
+			if !ok || next.Line != to.Line && i == n-1 {
+				// the last word ends at the end of its line
+				to.MoveEOL()
+				if !ok {
+					v.SetStatus("End of buffer")
+				}
+				break
+			}
+			to = next
+		}
+		m.f(from, to)
+	case textObjectPercent:
+		from, ok := v.Cursor().MatchPair(m.editor.Config.Pairs())
+		if !ok {
+			v.SetStatus("No matching pair")
+			return
+		}
+		from, to := buffer.SortCursors(from, v.Cursor())
+		// the motion includes the last character
+		to.NextRune(false)
+		m.f(from, to)
+	case textObjectLines, textObjectLinesDown, textObjectLinesUp, textObjectLinesEOF:
+		from, to := v.Cursor(), v.Cursor()
+		n := m.count * m.outerCount
+		switch m.object.kind {
+		case textObjectLines:
+			// n lines including the cursor line
+			n--
+			fallthrough
+		case textObjectLinesDown:
+			for ; n > 0 && to.NextLine(); n-- {
+			}
+		case textObjectLinesUp:
+			for ; n > 0 && from.PrevLine(); n-- {
 			}
-			m.f(from, to)
+		case textObjectLinesEOF:
+			if len(m.countChars) > 0 {
+				to = v.Buffer().LineCursor(m.count)
+			} else {
+				to = v.Buffer().LineCursor(v.Buffer().NumLines)
+			}
+			from, to = buffer.SortCursors(from, to)
+		}
+		from.Boffset = 0
+		to.MoveEOL()
+		switch m.op {
+		case 'd':
+			m.deleteLines(from, to)
+			return
+		case 'y':
+			m.yankLines(from, to)
+			return
 		}
+		m.f(from, to)
 	default:
 		m.editor.SetStatus("range conversion not implemented")
 	}
 }
+
+// deleteLines deletes the whole lines from the start of from to the end of to
+// with their newline, yanking them linewise into the unnamed register. The
+// cursor goes to the first non-blank character of the line following them, or
+// of the line preceding them when they were the last lines.
+func (m *TextObjectMode) deleteLines(from, to buffer.Cursor) {
+	v := m.editor.ActiveView()
+	b := v.Buffer()
+	m.editor.Yank('"', from.ExtractBytes(from.Distance(to)), true)
+
+	n := to.LineNum - from.LineNum + 1
+	b.FinalizeActionGroup()
+	c := from
+	if next := to; next.NextLine() {
+		next.Boffset = 0
+		b.DeleteRange(from, next)
+	} else if c.PrevLine() {
+		// the newline of the previous line goes with the last line
+		c.MoveEOL()
+		b.DeleteRange(c, to)
+	} else {
+		// the buffer keeps an empty line
+		b.DeleteRange(from, to)
+	}
+	b.FinalizeActionGroup()
+
+	c.Boffset = utils.IndexFirstNonSpace(c.Line.Data)
+	v.Sync()
+	v.MoveCursorTo(c)
+	m.editor.ReportLines(n, "fewer lines")
+}
+
+// yankLines yanks the whole lines from the start of from to the end of to
+// linewise into the unnamed register, without changing the buffer. The cursor
+// goes to the first of the lines, keeping its column.
+func (m *TextObjectMode) yankLines(from, to buffer.Cursor) {
+	v := m.editor.ActiveView()
+	m.editor.Yank('"', from.ExtractBytes(from.Distance(to)), true)
+	b := v.Buffer()
+	b.SetMark('[', from)
+	b.SetMark(']', to)
+	if from.Line != v.Cursor().Line {
+		from.Boffset = -1
+		v.MoveCursorTo(from)
+	}
+	m.editor.ReportLines(to.LineNum-from.LineNum+1, "lines yanked")
+}
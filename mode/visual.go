@@ -1,6 +1,7 @@
 package mode
 
 import (
+	"github.com/kisielk/vigo/buffer"
 	cmd "github.com/kisielk/vigo/commands"
 	"github.com/kisielk/vigo/editor"
 	"github.com/kisielk/vigo/utils"
@@ -12,31 +13,39 @@ type visualMode struct {
 	editor   *editor.Editor
 	count    string
 	lineMode bool
+	prefix   rune // first key of a pending multi-key command, such as 'g'
 }
 
 func NewVisualMode(e *editor.Editor, lineMode bool) *visualMode {
-	m := visualMode{editor: e, lineMode: lineMode}
+	m := visualMode{editor: e}
 	v := m.editor.ActiveView()
 	c := v.Cursor()
 
-	var t view.SelectionType
-	if lineMode {
-		m.editor.SetStatus("Visual Line")
-		t = view.SelectionLine
-	} else {
-		m.editor.SetStatus("Visual")
-		t = view.SelectionChar
-	}
-
-	sel := view.Selection{Type: t}
+	sel := view.Selection{Type: view.SelectionChar}
 	sel.Range.Start = c
 	sel.Range.End = c
 
 	v.SetSelection(sel)
+	m.setLineMode(lineMode)
 
 	return &m
 }
 
+// setLineMode makes the selection linewise or charwise, keeping its ends.
+func (m *visualMode) setLineMode(lineMode bool) {
+	v := m.editor.ActiveView()
+	sel := v.Selection()
+	m.lineMode = lineMode
+	if lineMode {
+		m.editor.SetStatus("Visual Line")
+		sel.Type = view.SelectionLine
+	} else {
+		m.editor.SetStatus("Visual")
+		sel.Type = view.SelectionChar
+	}
+	v.SetSelection(sel)
+}
+
 func (m *visualMode) Enter(e *editor.Editor) {
 }
 
@@ -57,12 +66,29 @@ func (m *visualMode) OnKey(ev *termbox.Event) {
 	g := m.editor
 	v := g.ActiveView()
 
+	if prefix := m.prefix; prefix != 0 {
+		m.prefix = 0
+		m.onPrefixedKey(prefix, ev, count)
+		m.count = ""
+		return
+	}
+
 	switch ev.Key {
 	case termbox.KeyEsc:
 		m.editor.SetMode(NewNormalMode(m.editor))
+	case termbox.KeyCtrlA:
+		m.increment(count, false)
+		return
+	case termbox.KeyCtrlX:
+		m.increment(-count, false)
+		return
 	}
 
 	switch ev.Ch {
+	case 'g', '`', '\'':
+		// Wait for the rest of the command, keeping the count.
+		m.prefix = ev.Ch
+		return
 	case 'h':
 		g.Commands <- cmd.Repeat{cmd.MoveRune{Dir: cmd.Backward}, count}
 	case 'j':
@@ -72,25 +98,107 @@ func (m *visualMode) OnKey(ev *termbox.Event) {
 	case 'l':
 		g.Commands <- cmd.Repeat{cmd.MoveRune{Dir: cmd.Forward}, count}
 	case 'd':
-		r := v.Selection().EffectiveRange()
+		r := yankSelection(g)
 		v.Buffer().DeleteRange(r.Start, r.End)
 		m.editor.SetMode(NewNormalMode(m.editor))
-	case 'v':
+	case 'y':
+		r := yankSelection(g)
+		v.MoveCursorTo(r.Start)
 		m.editor.SetMode(NewNormalMode(m.editor))
-	case 'V':
-		if m.lineMode {
-			m.editor.SetMode(NewNormalMode(m.editor))
+	case 'v', 'V':
+		// the other kind of selection switches to it, the same one ends
+		// the visual mode
+		if lineMode := ev.Ch == 'V'; lineMode != m.lineMode {
+			m.setLineMode(lineMode)
 		} else {
-			sel := v.Selection()
-			sel.Type = view.SelectionLine
-			v.SetSelection(sel)
+			m.editor.SetMode(NewNormalMode(m.editor))
+		}
+	case 'o', 'O':
+		// move the cursor to the other end of the selection
+		sel := v.Selection()
+		sel.Start, sel.End = sel.End, sel.Start
+		v.SetSelection(sel)
+		v.MoveCursorTo(sel.End)
+	case ':':
+		// The range of the selection is set in Exit.
+		c := NewCommandMode(g, NewNormalMode(g))
+		c.buffer.WriteString("'<,'>")
+		g.SetMode(c)
+		return
+	}
+
+	// Report the size of the selection once the motion has been applied.
+	g.Commands <- cmd.DisplaySelectionSize{}
+	m.count = ""
+}
+
+// onPrefixedKey handles the key completing a multi-key command started
+// with the prefix key.
+func (m *visualMode) onPrefixedKey(prefix rune, ev *termbox.Event, count int) {
+	g := m.editor
+	switch prefix {
+	case 'g':
+		switch ev.Key {
+		case termbox.KeyCtrlA:
+			m.increment(count, true)
+		case termbox.KeyCtrlX:
+			m.increment(-count, true)
 		}
+	case '`', '\'':
+		g.Commands <- cmd.MoveMark{Mark: ev.Ch, Linewise: prefix == '\''}
+		g.Commands <- cmd.DisplaySelectionSize{}
 	}
+}
 
+// increment adds delta to the first number of each selected line and returns
+// to normal mode. If progressive is set, the increment grows with each line.
+//
+// TODO Once there is a blockwise selection (Ctrl-V), only increment the
+// number of each line crossing the columns of the block.
+func (m *visualMode) increment(delta int, progressive bool) {
+	g := m.editor
+	sel := g.ActiveView().Selection()
+	start, end := buffer.SortCursors(sel.Start, sel.End)
+	if sel.Type == view.SelectionLine {
+		start.Boffset = 0
+	}
+	g.Commands <- cmd.Increment{Start: start, End: end, Delta: delta, Progressive: progressive}
+	g.SetMode(NewNormalMode(g))
 	m.count = ""
 }
 
 func (m *visualMode) Exit() {
 	v := m.editor.ActiveView()
+	sel := v.Selection()
+	start, end := buffer.SortCursors(sel.Start, sel.End)
+	v.Buffer().SetMark('<', start)
+	v.Buffer().SetMark('>', end)
 	v.SetSelection(view.Selection{Type: view.SelectionNone})
 }
+
+// PendingCommand returns the count and the prefix key typed so far.
+func (m *visualMode) PendingCommand() string {
+	if m.prefix != 0 {
+		return m.count + string(m.prefix)
+	}
+	return m.count
+}
+
+// StartDrag starts a new charwise selection where the mouse button was pressed.
+func (m *visualMode) StartDrag() {
+	m.editor.SetMode(NewVisualMode(m.editor, false))
+}
+
+// yankSelection stores the selected text of the active view in the anonymous
+// cut buffer and returns its range.
+func yankSelection(e *editor.Editor) buffer.Range {
+	sel := e.ActiveView().Selection()
+	r := sel.EffectiveRange()
+	s := r.Start.ExtractBytes(r.Start.Distance(r.End))
+	// a linewise selection of the last line has no newline, Yank adds it
+	e.Yank('"', s, sel.Type == view.SelectionLine)
+	b := e.ActiveView().Buffer()
+	b.SetMark('[', r.Start)
+	b.SetMark(']', r.End)
+	return r
+}
@@ -1,14 +1,19 @@
 package mode
 
 import (
+	"fmt"
+	"strconv"
+
 	cmd "github.com/kisielk/vigo/commands"
 	"github.com/kisielk/vigo/editor"
+	"github.com/kisielk/vigo/view"
 	"github.com/nsf/termbox-go"
 )
 
 type WindowMode struct {
 	editor *editor.Editor
-	count  int
+	count  int  // 0 if none was given
+	prefix rune // first key of a two key command, such as g of gf
 }
 
 func NewWindowMode(editor *editor.Editor, count int) WindowMode {
@@ -19,7 +24,45 @@ func (m WindowMode) Enter(e *editor.Editor) {
 }
 
 func (m WindowMode) OnKey(ev *termbox.Event) {
+	if m.prefix == 'g' {
+		switch ev.Ch {
+		case 'f':
+			// TODO open the file in a new tab page, once there are tab pages
+			m.editor.SetMode(NewNormalMode(m.editor))
+			m.editor.SetStatus("Tab pages are not supported")
+			return
+		}
+		m.editor.SetMode(NewNormalMode(m.editor))
+		return
+	}
+
+	n := m.count
+	if n == 0 {
+		n = 1
+	}
+	t := m.editor.ActiveViewNode()
+
+	var err error
 	switch ev.Ch {
+	case 0:
+		switch ev.Key {
+		case termbox.KeyCtrlS:
+			m.editor.SplitHorizontally()
+		case termbox.KeyCtrlV:
+			m.editor.SplitVertically()
+		case termbox.KeyCtrlP:
+			m.editor.FocusPreviousView()
+		case termbox.KeyCtrlF:
+			m.editor.Commands <- cmd.GotoFile{Split: true}
+		case termbox.KeyCtrlN:
+			err = m.editor.SplitNew()
+		case termbox.KeyCtrlC:
+			// cancels the command, as in Vim
+		case termbox.KeyCtrlR:
+			err = m.editor.RotateViews(false)
+		case termbox.KeyCtrlX:
+			err = m.editor.ExchangeViews()
+		}
 	case 'h':
 		m.editor.Commands <- cmd.NearestVSplit{cmd.Backward}
 	case 'j':
@@ -28,11 +71,103 @@ func (m WindowMode) OnKey(ev *termbox.Event) {
 		m.editor.Commands <- cmd.NearestHSplit{cmd.Backward}
 	case 'l':
 		m.editor.Commands <- cmd.NearestVSplit{cmd.Forward}
+	case 's', 'S':
+		// same as :split
+		m.editor.SplitHorizontally()
+	case 'v':
+		// same as :vsplit
+		m.editor.SplitVertically()
+	case 'p':
+		m.editor.FocusPreviousView()
+	case 'n':
+		// same as :new
+		err = m.editor.SplitNew()
+	case 'c':
+		// same as :close
+		err = m.editor.CloseView()
+	case 'q':
+		// same as :q
+		err = m.editor.QuitView(false)
+	case 'r':
+		err = m.editor.RotateViews(false)
+	case 'R':
+		err = m.editor.RotateViews(true)
+	case 'x':
+		err = m.editor.ExchangeViews()
+	case 'H':
+		m.editor.MoveActiveViewToEdge(true, true)
+	case 'J':
+		m.editor.MoveActiveViewToEdge(false, false)
+	case 'K':
+		m.editor.MoveActiveViewToEdge(false, true)
+	case 'L':
+		m.editor.MoveActiveViewToEdge(true, false)
+	case 'f':
+		m.editor.Commands <- cmd.GotoFile{Split: true}
+	case '}':
+		m.editor.Commands <- cmd.PreviewDeclaration{}
+	case 'g':
+		m.prefix = 'g'
+		m.editor.SetMode(m)
+		return
+	case '+':
+		err = growView(t, n, false)
+	case '-':
+		err = growView(t, -n, false)
+	case '>':
+		err = growView(t, n, true)
+	case '<':
+		err = growView(t, -n, true)
+	case '_':
+		// same as :resize
+		err = resizeView(m.editor, m.sizeArgs(), false)
+	case '|':
+		// same as :vertical resize
+		err = resizeView(m.editor, m.sizeArgs(), true)
 	case '=':
-		// TODO viewTree.normalizeSplit
+		m.editor.EqualizeViews()
+	case 'T':
+		// TODO move the window to a new tab page, once there are tab
+		// pages: remove its leaf from the tree like killActiveView and
+		// make it the only leaf of the new page.
+		m.editor.SetMode(NewNormalMode(m.editor))
+		m.editor.SetStatus("Tab pages are not supported")
+		return
 	}
 	m.editor.SetMode(NewNormalMode(m.editor))
+	if err != nil {
+		m.editor.SetStatus("%s", err)
+	}
+}
+
+// sizeArgs returns the arguments of :resize setting the size to the count, if
+// one was given.
+func (m WindowMode) sizeArgs() []string {
+	if m.count == 0 {
+		return nil
+	}
+	return []string{strconv.Itoa(m.count)}
+}
+
+// growView makes the view of the node t n lines higher, or n columns wider if
+// vertical is set.
+func growView(t *view.Tree, n int, vertical bool) error {
+	if !t.Grow(n, vertical) {
+		return fmt.Errorf("no split to resize")
+	}
+	return nil
 }
 
 func (m WindowMode) Exit() {
 }
+
+func (m WindowMode) PendingCommand() string {
+	s := "^W"
+	if m.count > 1 {
+		s = strconv.Itoa(m.count) + s
+	}
+	if m.prefix != 0 {
+		s += string(m.prefix)
+	}
+	return s
+}
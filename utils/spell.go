@@ -0,0 +1,155 @@
+package utils
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Dictionary is a set of correctly spelled words.
+type Dictionary map[string]bool
+
+// ReadDictionary reads a word list, made of one word per line.
+func ReadDictionary(r io.Reader) (Dictionary, error) {
+	d := make(Dictionary)
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		if w := bytes.TrimSpace(s.Bytes()); len(w) > 0 {
+			d[string(w)] = true
+		}
+	}
+	return d, s.Err()
+}
+
+// Misspelled reports whether word is in the dictionary neither as written
+// nor in lower case, e.g. at the start of a sentence.
+func (d Dictionary) Misspelled(word []byte) bool {
+	return !d[string(word)] && !d[string(bytes.ToLower(word))]
+}
+
+// IterMisspellings calls cb with the start and end byte offsets of the words
+// of data not in the dictionary. Only words made of letters, possibly with
+// apostrophes between them as in "don't", are checked.
+func (d Dictionary) IterMisspellings(data []byte, cb func(start, end int)) {
+	for i := 0; i < len(data); {
+		r, n := utf8.DecodeRune(data[i:])
+		if !IsWord(r) {
+			i += n
+			continue
+		}
+
+		start, letters := i, true
+		for i < len(data) {
+			r, n := utf8.DecodeRune(data[i:])
+			if r == '\'' {
+				if next, _ := utf8.DecodeRune(data[i+n:]); !unicode.IsLetter(next) {
+					break
+				}
+			} else if !IsWord(r) {
+				break
+			} else if !unicode.IsLetter(r) {
+				letters = false
+			}
+			i += n
+		}
+		if letters && d.Misspelled(data[start:i]) {
+			cb(start, i)
+		}
+	}
+}
+
+// maxSuggestionDistance is the largest edit distance between a misspelled
+// word and the words suggested to replace it.
+const maxSuggestionDistance = 2
+
+// Suggest returns at most n words of the dictionary close to word, the
+// closest first. The distance between two words is the number of characters
+// inserted, deleted or substituted to turn one into the other.
+func (d Dictionary) Suggest(word string, n int) []string {
+	w := []rune(strings.ToLower(word))
+	var s suggestions
+	for candidate := range d {
+		c := []rune(strings.ToLower(candidate))
+		if abs(len(c)-len(w)) > maxSuggestionDistance {
+			continue
+		}
+		if dist := editDistance(w, c, maxSuggestionDistance); dist <= maxSuggestionDistance {
+			s = append(s, suggestion{candidate, dist})
+		}
+	}
+	sort.Sort(s)
+
+	var words []string
+	for _, sg := range s {
+		if len(words) == n {
+			break
+		}
+		if sg.word != word {
+			words = append(words, sg.word)
+		}
+	}
+	return words
+}
+
+type suggestion struct {
+	word string
+	dist int
+}
+
+// suggestions sort by distance, then alphabetically.
+type suggestions []suggestion
+
+func (s suggestions) Len() int      { return len(s) }
+func (s suggestions) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
+func (s suggestions) Less(i, j int) bool {
+	if s[i].dist != s[j].dist {
+		return s[i].dist < s[j].dist
+	}
+	return s[i].word < s[j].word
+}
+
+// editDistance returns the Levenshtein distance between a and b, or any
+// value larger than max if it is larger than max.
+func editDistance(a, b []rune, max int) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		rowMin := cur[0]
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = prev[j-1] + cost
+			if prev[j]+1 < cur[j] {
+				cur[j] = prev[j] + 1
+			}
+			if cur[j-1]+1 < cur[j] {
+				cur[j] = cur[j-1] + 1
+			}
+			if cur[j] < rowMin {
+				rowMin = cur[j]
+			}
+		}
+		if rowMin > max {
+			return rowMin
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
@@ -0,0 +1,40 @@
+package utils
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestIterMisspellings(t *testing.T) {
+	d, err := ReadDictionary(strings.NewReader("the\ncat\ndon't\nGo\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got [][2]int
+	d.IterMisspellings([]byte("The cat don't sta go Go x2 foo_bar dont"), func(start, end int) {
+		got = append(got, [2]int{start, end})
+	})
+	want := [][2]int{{14, 17}, {18, 20}, {35, 39}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestSuggest(t *testing.T) {
+	d, err := ReadDictionary(strings.NewReader("cat\ncart\ncast\ncoat\ndog\nat\ncatalog\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := d.Suggest("caat", 4)
+	want := []string{"cart", "cast", "cat", "coat"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got := d.Suggest("caat", 2); len(got) != 2 {
+		t.Errorf("got %d suggestions, want 2", len(got))
+	}
+	if got := d.Suggest("xyzzy", 4); len(got) != 0 {
+		t.Errorf("unexpected suggestions: %v", got)
+	}
+}
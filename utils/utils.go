@@ -6,7 +6,8 @@ import (
 	"unicode"
 )
 
-// TODO keep synched with editor/config.go/tabstopLength
+// TabstopLength is the default number of cells between two tab stops, which
+// the tabstop option in editor/config.go changes.
 const TabstopLength = 8
 
 var InvisibleRuneTable = []rune{
@@ -55,6 +56,8 @@ func IterWords(data []byte, cb func(word []byte)) {
 			return !IsWord(r)
 		})
 		if i == -1 {
+			// the last word runs until the end of data
+			cb(data)
 			return
 		}
 		cb(data[:i])
@@ -80,6 +83,65 @@ func IndexLastNonSpace(s []byte) int {
 	return -1
 }
 
+// FindNumber returns the byte offsets of the decimal number in data which
+// the offset is in, or else of the first one after it, including a minus sign
+// just before it. It returns -1 if there is none.
+func FindNumber(data []byte, offset int) (start, end int) {
+	for offset < len(data) && isDigit(data[offset]) && offset > 0 && isDigit(data[offset-1]) {
+		offset--
+	}
+	i := bytes.IndexAny(data[offset:], "0123456789")
+	if i == -1 {
+		return -1, -1
+	}
+	start = offset + i
+	end = start
+	for end < len(data) && isDigit(data[end]) {
+		end++
+	}
+	if start > 0 && data[start-1] == '-' {
+		start--
+	}
+	return start, end
+}
+
+func isDigit(b byte) bool {
+	return '0' <= b && b <= '9'
+}
+
+// IndentWidth returns the number of cells taken by the leading whitespace of
+// s, with tabstop cells between tab stops.
+func IndentWidth(s []byte, tabstop int) int {
+	w := 0
+	for _, b := range s[:IndexFirstNonSpace(s)] {
+		w += RuneAdvanceLen(rune(b), w, tabstop)
+	}
+	return w
+}
+
+// MakeIndent returns whitespace taking width cells, made of tabs and spaces,
+// or only of spaces if expandTab is set.
+func MakeIndent(width, tabstop int, expandTab bool) []byte {
+	return MakeWhitespace(0, width, tabstop, expandTab)
+}
+
+// MakeWhitespace returns whitespace filling the cells between the visual
+// offsets from and to, made of tabs and spaces, or only of spaces if
+// expandTab is set. There are tabstop cells between tab stops.
+func MakeWhitespace(from, to, tabstop int, expandTab bool) []byte {
+	var s []byte
+	if !expandTab {
+		for next := (from/tabstop + 1) * tabstop; next <= to; next += tabstop {
+			s = append(s, '\t')
+			from = next
+		}
+	}
+	for ; from < to; from++ {
+		s = append(s, ' ')
+	}
+	return s
+}
+
 func CloneByteSlice(s []byte) []byte {
 	c := make([]byte, len(s))
 	copy(c, s)
@@ -126,17 +188,50 @@ func InsertBytes(s []byte, offset int, data []byte) []byte {
 	return s
 }
 
-func RuneAdvanceLen(r rune, pos int) int {
+// RuneAdvanceLen returns the number of cells taken by the rune r drawn at
+// the visual offset pos, with tabstop cells between tab stops.
+func RuneAdvanceLen(r rune, pos, tabstop int) int {
 	switch {
 	case r == '\t':
-		return TabstopLength - pos%TabstopLength
+		return tabstop - pos%tabstop
 	case r < 32:
 		// for invisible chars like ^R ^@ and such, two cells
 		return 2
+	case r < 0x300:
+		return 1
+	case unicode.In(r, unicode.Mn, unicode.Me) || (0x200b <= r && r <= 0x200f):
+		// combining marks and zero width spaces are drawn over the
+		// previous rune
+		return 0
+	case unicode.Is(wideRunes, r):
+		return 2
 	}
 	return 1
 }
 
+// wideRunes are the runes taking two cells, those of East Asian width W or F.
+var wideRunes = &unicode.RangeTable{
+	R16: []unicode.Range16{
+		{0x1100, 0x115f, 1}, // Hangul Jamo
+		{0x2e80, 0x303e, 1}, // CJK radicals and punctuation
+		{0x3041, 0x33ff, 1}, // Kana, Bopomofo, CJK compatibility
+		{0x3400, 0x4dbf, 1}, // CJK unified ideographs extension A
+		{0x4e00, 0x9fff, 1}, // CJK unified ideographs
+		{0xa000, 0xa4cf, 1}, // Yi
+		{0xac00, 0xd7a3, 1}, // Hangul syllables
+		{0xf900, 0xfaff, 1}, // CJK compatibility ideographs
+		{0xfe30, 0xfe4f, 1}, // CJK compatibility forms
+		{0xff00, 0xff60, 1}, // Fullwidth forms
+		{0xffe0, 0xffe6, 1}, // Fullwidth signs
+	},
+	R32: []unicode.Range32{
+		{0x1f300, 0x1f64f, 1}, // pictographs and emoticons
+		{0x1f900, 0x1f9ff, 1}, // supplemental pictographs
+		{0x20000, 0x2fffd, 1}, // CJK unified ideographs extensions
+		{0x30000, 0x3fffd, 1},
+	},
+}
+
 func GrowByteSlice(s []byte, desiredCap int) []byte {
 	if cap(s) < desiredCap {
 		ns := make([]byte, len(s), desiredCap)
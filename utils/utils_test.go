@@ -10,6 +10,9 @@ func TestIterWords(t *testing.T) {
 	}{
 		{[]byte("hello world"), bytes.Split([]byte("hello:world"), []byte(":"))},
 		{[]byte("    hello    world   "), bytes.Split([]byte("hello:world"), []byte(":"))},
+		{[]byte("hello"), [][]byte{[]byte("hello")}},
+		{[]byte("(foo_bar, baz)"), bytes.Split([]byte("foo_bar:baz"), []byte(":"))},
+		{[]byte("  "), [][]byte{}},
 	}
 
 	for i, test := range tests {
@@ -19,7 +22,8 @@ func TestIterWords(t *testing.T) {
 		}
 		IterWords(test.in, f)
 		if len(out) != len(test.out) {
-			t.Logf("%d: wrong output length: got %d want %d", i, len(out), len(test.out))
+			t.Errorf("%d: wrong output length: got %d want %d", i, len(out), len(test.out))
+			continue
 		}
 		for j := range out {
 			if !bytes.Equal(out[j], test.out[j]) {
@@ -29,3 +33,81 @@ func TestIterWords(t *testing.T) {
 		}
 	}
 }
+
+func TestFindNumber(t *testing.T) {
+	tests := []struct {
+		in         string
+		offset     int
+		start, end int
+	}{
+		{"x 199 y", 0, 2, 5},
+		{"x 199 y", 3, 2, 5},
+		{"x 199 y", 4, 2, 5},
+		{"x 199 y", 5, -1, -1},
+		{"-5", 0, 0, 2},
+		{"-5", 1, 0, 2},
+		{"a-12 3", 3, 1, 4},
+		{"a-12 3", 4, 5, 6},
+		{"", 0, -1, -1},
+	}
+	for _, test := range tests {
+		start, end := FindNumber([]byte(test.in), test.offset)
+		if start != test.start || end != test.end {
+			t.Errorf("%q at %d: got %d-%d, want %d-%d", test.in, test.offset, start, end, test.start, test.end)
+		}
+	}
+}
+
+func TestIndent(t *testing.T) {
+	tests := []struct {
+		in        string
+		width     int
+		expandTab string
+	}{
+		{"foo", 0, ""},
+		{"  foo", 2, "  "},
+		{"\tfoo", 8, "        "},
+		{"  \tfoo", 8, "        "},
+		{"\t  \t foo", 17, "                 "},
+		{"    ", 4, "    "},
+	}
+	for i, test := range tests {
+		w := IndentWidth([]byte(test.in), 8)
+		if w != test.width {
+			t.Errorf("%d: bad width: got %d want %d", i, w, test.width)
+		}
+		if s := string(MakeIndent(w, 8, true)); s != test.expandTab {
+			t.Errorf("%d: bad expanded indent: got %q want %q", i, s, test.expandTab)
+		}
+		if s := MakeIndent(w, 8, false); IndentWidth(s, 8) != w || bytes.Contains(s, []byte("        ")) {
+			t.Errorf("%d: bad indent %q for width %d", i, s, w)
+		}
+	}
+}
+
+func TestRuneAdvanceLen(t *testing.T) {
+	tests := []struct {
+		r     rune
+		pos   int
+		width int
+	}{
+		{'a', 0, 1},
+		{'\t', 3, 5},
+		{'\x01', 0, 2},
+		{'é', 0, 1},
+		{'\u0301', 1, 0}, // combining acute accent
+		{'日', 0, 2},
+		{'テ', 0, 2},
+		{'한', 0, 2},
+		{'Ａ', 0, 2}, // fullwidth A
+		{'ｱ', 0, 1}, // halfwidth katakana
+	}
+	for _, test := range tests {
+		if w := RuneAdvanceLen(test.r, test.pos, 8); w != test.width {
+			t.Errorf("%q at %d: got width %d, want %d", test.r, test.pos, w, test.width)
+		}
+	}
+	if w := RuneAdvanceLen('\t', 3, 4); w != 1 {
+		t.Errorf("tab at 3 with a tabstop of 4: got width %d, want 1", w)
+	}
+}
@@ -0,0 +1,134 @@
+package view
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// ListChars are the characters drawn in place of some text, as set by the
+// listchars option. Eol, Tab and Trail are only drawn with the list option,
+// Extends and Precedes always. A character which is 0 isn't drawn.
+type ListChars struct {
+	Eol      rune    // after the end of lines
+	Tab      [2]rune // in the first cell of tabs, and in the other ones
+	Trail    rune    // for trailing spaces
+	Extends  rune    // in the last column when a line goes past it
+	Precedes rune    // in the first column when a line is scrolled horizontally
+}
+
+// DefaultListChars are the list characters until others are set.
+var DefaultListChars = ListChars{Eol: '$', Extends: '→', Precedes: '←'}
+
+// ParseListChars parses the list characters written as for the listchars
+// option: a comma separated list of eol:c, tab:xy, trail:c, extends:c and
+// precedes:c. The characters which aren't given are left out.
+func ParseListChars(s string) (ListChars, error) {
+	var lc ListChars
+	chars, err := parseChars(s, map[string]int{"eol": 1, "tab": 2, "trail": 1, "extends": 1, "precedes": 1})
+	if err != nil {
+		return lc, err
+	}
+	lc.Eol = chars["eol"][0]
+	copy(lc.Tab[:], chars["tab"])
+	lc.Trail = chars["trail"][0]
+	lc.Extends = chars["extends"][0]
+	lc.Precedes = chars["precedes"][0]
+	return lc, nil
+}
+
+// String returns the list characters written as for the listchars option.
+func (lc ListChars) String() string {
+	var items []string
+	add := func(name string, chars ...rune) {
+		if chars[0] != 0 {
+			items = append(items, name+":"+string(chars))
+		}
+	}
+	add("eol", lc.Eol)
+	add("tab", lc.Tab[:]...)
+	add("trail", lc.Trail)
+	add("extends", lc.Extends)
+	add("precedes", lc.Precedes)
+	return strings.Join(items, ",")
+}
+
+// FillChars are the characters drawn around views, as set by the fillchars
+// option.
+type FillChars struct {
+	Vert   rune // splitters between views side by side
+	Status rune // fill of status lines
+}
+
+// DefaultFillChars are the fill characters until others are set. The corners
+// where splitters meet are only drawn with them.
+var DefaultFillChars = FillChars{Vert: '│', Status: '─'}
+
+// ParseFillChars parses the fill characters written as for the fillchars
+// option: a comma separated list of vert:c and stl:c. The characters which
+// aren't given are the default ones.
+func ParseFillChars(s string) (FillChars, error) {
+	fc := DefaultFillChars
+	chars, err := parseChars(s, map[string]int{"vert": 1, "stl": 1})
+	if err != nil {
+		return fc, err
+	}
+	if c := chars["vert"][0]; c != 0 {
+		fc.Vert = c
+	}
+	if c := chars["stl"][0]; c != 0 {
+		fc.Status = c
+	}
+	return fc, nil
+}
+
+// String returns the fill characters written as for the fillchars option.
+func (fc FillChars) String() string {
+	return fmt.Sprintf("vert:%c,stl:%c", fc.Vert, fc.Status)
+}
+
+// parseChars parses a comma separated list of name:chars items. want gives
+// the number of characters following each name. The returned map has as many
+// characters, 0 if the item isn't given, for every name of want.
+func parseChars(s string, want map[string]int) (map[string][]rune, error) {
+	chars := make(map[string][]rune)
+	for name, n := range want {
+		chars[name] = make([]rune, n)
+	}
+	if s == "" {
+		return chars, nil
+	}
+	for _, item := range strings.Split(s, ",") {
+		i := strings.IndexByte(item, ':')
+		if i == -1 {
+			return nil, fmt.Errorf("invalid item: %s", item)
+		}
+		name, value := item[:i], item[i+1:]
+		n, ok := want[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown item: %s", name)
+		}
+		if utf8.RuneCountInString(value) != n {
+			return nil, fmt.Errorf("%s takes %d character(s): %s", name, n, value)
+		}
+		chars[name] = []rune(value)
+	}
+	return chars, nil
+}
+
+// listChars returns the list characters of the view, and whether the list
+// option is set.
+func (v *View) listChars() (*ListChars, bool) {
+	if v.ctx.options == nil {
+		return &DefaultListChars, false
+	}
+	return &v.ctx.options.ListChars, v.ctx.options.List
+}
+
+// fillChars returns the fill characters of the view.
+func (v *View) fillChars() *FillChars {
+	if v.ctx.options == nil {
+		return &DefaultFillChars
+	}
+	return &v.ctx.options.FillChars
+}
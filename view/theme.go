@@ -0,0 +1,202 @@
+package view
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/nsf/termbox-go"
+)
+
+// Color is the foreground and background attributes of drawn text.
+type Color struct {
+	Fg, Bg termbox.Attribute
+}
+
+// Theme holds the colors of the text drawn in each role.
+type Theme struct {
+	Status       Color // status lines and splitters
+	StatusName   Color // file name in the status line of a view
+	Highlight    Color // matches of the last search
+	Selection    Color // visual selection
+	CursorColumn Color // column highlighted by the cursorcolumn option
+	Spell        Color // misspelled words
+	Control      Color // control characters, drawn as ^X
+	Menu         Color // candidates of command line completion
+	MenuSelected Color // selected candidate
+	Sign         Color // sign column, and signs without a color of their own
+}
+
+// DefaultTheme is the theme used until another one is loaded.
+var DefaultTheme = Theme{
+	Status:       Color{termbox.AttrReverse, termbox.AttrReverse},
+	StatusName:   Color{termbox.AttrReverse | termbox.AttrBold, termbox.AttrReverse},
+	Highlight:    Color{termbox.ColorCyan, termbox.ColorBlue},
+	Selection:    Color{termbox.ColorDefault, termbox.ColorDefault | termbox.AttrReverse},
+	CursorColumn: Color{termbox.ColorBlack, termbox.ColorWhite},
+	Spell:        Color{termbox.ColorRed | termbox.AttrUnderline, termbox.ColorDefault},
+	Control:      Color{termbox.ColorRed, termbox.ColorDefault},
+	Menu:         Color{termbox.AttrReverse, termbox.AttrReverse},
+	MenuSelected: Color{termbox.ColorDefault, termbox.ColorDefault},
+	Sign:         Color{termbox.ColorYellow | termbox.AttrBold, termbox.ColorDefault},
+}
+
+// DefaultTheme256 is the theme used until another one is loaded, when the
+// terminal has 256 colors.
+var DefaultTheme256 = Theme{
+	Status:       Color{paletteColor(252), paletteColor(238)},
+	StatusName:   Color{paletteColor(231) | termbox.AttrBold, paletteColor(238)},
+	Highlight:    Color{paletteColor(16), paletteColor(221)},
+	Selection:    Color{termbox.ColorDefault, paletteColor(24)},
+	CursorColumn: Color{termbox.ColorDefault, paletteColor(236)},
+	Spell:        Color{paletteColor(203) | termbox.AttrUnderline, termbox.ColorDefault},
+	Control:      Color{paletteColor(167), termbox.ColorDefault},
+	Menu:         Color{paletteColor(252), paletteColor(238)},
+	MenuSelected: Color{paletteColor(16), paletteColor(117)},
+	Sign:         Color{paletteColor(214) | termbox.AttrBold, paletteColor(235)},
+}
+
+// paletteColor returns the attribute of the color n of the 256 color palette,
+// in which the first 8 colors are the same as termbox.ColorBlack to
+// termbox.ColorWhite.
+func paletteColor(n int) termbox.Attribute {
+	return termbox.Attribute(n + 1)
+}
+
+// colorMask covers the color of an attribute, leaving out bold, underline and
+// reverse.
+const colorMask = termbox.AttrBold - 1
+
+// Fallback returns the theme with the colors of the 256 color palette replaced
+// by the closest of the 8 basic colors, for terminals which have no more.
+func (t Theme) Fallback() Theme {
+	for _, c := range t.roles() {
+		c.Fg = fallbackColor(c.Fg)
+		c.Bg = fallbackColor(c.Bg)
+	}
+	return t
+}
+
+func fallbackColor(a termbox.Attribute) termbox.Attribute {
+	n := int(a&colorMask) - 1
+	var basic int
+	switch {
+	case n < 8:
+		return a
+	case n < 16:
+		// bright variants of the basic colors
+		basic = n - 8
+	case n < 232:
+		// 6x6x6 color cube, each component is kept if it is bright enough
+		n -= 16
+		r, g, b := n/36, n/6%6, n%6
+		for i, v := range []int{r, g, b} {
+			if v >= 3 {
+				basic |= 1 << uint(i)
+			}
+		}
+	default:
+		// grayscale ramp
+		if n >= 244 {
+			basic = 7
+		}
+	}
+	return a&^colorMask | paletteColor(basic)
+}
+
+// theme returns the theme of the view.
+func (v *View) theme() *Theme {
+	if v.ctx.options == nil {
+		return &DefaultTheme
+	}
+	return &v.ctx.options.Theme
+}
+
+// roles returns the colors of the theme by the names used in theme files.
+func (t *Theme) roles() map[string]*Color {
+	return map[string]*Color{
+		"status":       &t.Status,
+		"statusname":   &t.StatusName,
+		"highlight":    &t.Highlight,
+		"selection":    &t.Selection,
+		"cursorcolumn": &t.CursorColumn,
+		"spell":        &t.Spell,
+		"control":      &t.Control,
+		"menu":         &t.Menu,
+		"menuselected": &t.MenuSelected,
+		"sign":         &t.Sign,
+	}
+}
+
+var attributes = map[string]termbox.Attribute{
+	"default":   termbox.ColorDefault,
+	"black":     termbox.ColorBlack,
+	"red":       termbox.ColorRed,
+	"green":     termbox.ColorGreen,
+	"yellow":    termbox.ColorYellow,
+	"blue":      termbox.ColorBlue,
+	"magenta":   termbox.ColorMagenta,
+	"cyan":      termbox.ColorCyan,
+	"white":     termbox.ColorWhite,
+	"bold":      termbox.AttrBold,
+	"underline": termbox.AttrUnderline,
+	"reverse":   termbox.AttrReverse,
+}
+
+// ReadTheme reads a theme made of lines giving the foreground and the
+// optional background of a role, such as:
+//
+//	highlight black,bold yellow
+//
+// Colors are default, black, red, green, yellow, blue, magenta, cyan, white or
+// a number of the 256 color palette, combined with bold, underline or reverse. Roles which aren't given keep the
+// colors of DefaultTheme. Empty lines and lines starting with # are ignored.
+func ReadTheme(r io.Reader) (Theme, error) {
+	t := DefaultTheme
+	roles := t.roles()
+	s := bufio.NewScanner(r)
+	for n := 1; s.Scan(); n++ {
+		fields := strings.Fields(s.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		c, ok := roles[fields[0]]
+		if !ok {
+			return t, fmt.Errorf("line %d: unknown role: %s", n, fields[0])
+		}
+		if len(fields) < 2 || len(fields) > 3 {
+			return t, fmt.Errorf("line %d: expected a role and one or two colors", n)
+		}
+		var err error
+		if c.Fg, err = parseAttribute(fields[1]); err != nil {
+			return t, fmt.Errorf("line %d: %s", n, err)
+		}
+		c.Bg = termbox.ColorDefault
+		if len(fields) == 3 {
+			if c.Bg, err = parseAttribute(fields[2]); err != nil {
+				return t, fmt.Errorf("line %d: %s", n, err)
+			}
+		}
+	}
+	return t, s.Err()
+}
+
+// parseAttribute parses a color combined with attributes, separated by
+// commas.
+func parseAttribute(s string) (termbox.Attribute, error) {
+	var attr termbox.Attribute
+	for _, name := range strings.Split(s, ",") {
+		a, ok := attributes[name]
+		if !ok {
+			n, err := strconv.Atoi(name)
+			if err != nil || n < 0 || n > 255 {
+				return 0, fmt.Errorf("unknown color: %s", name)
+			}
+			a = paletteColor(n)
+		}
+		attr |= a
+	}
+	return attr, nil
+}
@@ -64,6 +64,9 @@ func (t *Tree) Bottom() *Tree {
 func (v *Tree) SplitHorizontally() {
 	top := v.leaf
 	bottom := NewView(top.ctx, top.buf, top.redraw)
+	if top.locations != nil {
+		bottom.locations = top.locations.Copy()
+	}
 	*v = Tree{
 		parent: v.parent,
 		split:  0.5,
@@ -79,6 +82,9 @@ func (t *Tree) SetParent(parent *Tree) {
 func (v *Tree) SplitVertically() {
 	left := v.leaf
 	right := NewView(left.ctx, left.buf, left.redraw)
+	if left.locations != nil {
+		right.locations = left.locations.Copy()
+	}
 	*v = Tree{
 		parent: v.parent,
 		split:  0.5,
@@ -105,6 +111,7 @@ func (v *Tree) Draw() {
 func (v *Tree) Resize(pos tulib.Rect) {
 	v.Rect = pos
 	if v.leaf != nil {
+		v.leaf.noStatus = !v.HasStatus()
 		v.leaf.resize(pos.Width, pos.Height)
 		return
 	}
@@ -132,6 +139,30 @@ func (v *Tree) Resize(pos tulib.Rect) {
 	}
 }
 
+// HasStatus reports whether the views of the node have a status line at their
+// bottom. Only the views at the bottom of the tree may have none, as set by
+// the laststatus option.
+func (v *Tree) HasStatus() bool {
+	root := v
+	for root.parent != nil {
+		root = root.parent
+	}
+	if v.Y+v.Height < root.Y+root.Height {
+		return true
+	}
+	opts := v.FirstLeafNode().leaf.ctx.options
+	if opts == nil {
+		return true
+	}
+	switch opts.LastStatus {
+	case 0:
+		return false
+	case 1:
+		return root.leaf == nil
+	}
+	return true
+}
+
 func (v *Tree) Walk(cb func(*Tree)) {
 	if v.leaf != nil {
 		cb(v)
@@ -220,6 +251,187 @@ func (v *Tree) stepResize(n int) {
 	v.Resize(v.Rect)
 }
 
+// SetHeight changes the split of the nearest node above v splitting views
+// one above the other, so that the part containing v is h lines high,
+// including the status line. It reports whether there is such a node.
+func (v *Tree) SetHeight(h int) bool {
+	for w := v; w.parent != nil; w = w.parent {
+		if p := w.parent; p.top != nil {
+			if w == p.bottom {
+				h = p.Height - h
+			}
+			p.setSplit(h, p.Height)
+			return true
+		}
+	}
+	return false
+}
+
+// SetWidth changes the split of the nearest node above v splitting views
+// side by side, so that the part containing v is w columns wide. It reports
+// whether there is such a node.
+func (v *Tree) SetWidth(width int) bool {
+	for w := v; w.parent != nil; w = w.parent {
+		if p := w.parent; p.left != nil {
+			// one column is taken by the splitter
+			if w == p.right {
+				width = p.Width - 1 - width
+			}
+			p.setSplit(width, p.Width-1)
+			return true
+		}
+	}
+	return false
+}
+
+// Grow makes the part containing v n lines higher, or n columns wider if
+// vertical is set, and smaller if n is negative, by moving the split of the
+// nearest node above v splitting in that direction. It reports whether there
+// is such a node.
+func (v *Tree) Grow(n int, vertical bool) bool {
+	for w := v; w.parent != nil; w = w.parent {
+		p := w.parent
+		if (p.left != nil) != vertical {
+			continue
+		}
+		if w == p.bottom || w == p.right {
+			n = -n
+		}
+		p.stepResize(n)
+		return true
+	}
+	return false
+}
+
+// Maximize makes the part containing v as high as possible, or as wide if
+// vertical is set, by moving the splits of all the nodes above v splitting in
+// that direction. The other views keep their smallest size. It reports
+// whether there is any such node.
+func (v *Tree) Maximize(vertical bool) bool {
+	var nodes []*Tree
+	for w := v; w.parent != nil; w = w.parent {
+		if p := w.parent; (p.left != nil) == vertical {
+			nodes = append(nodes, w)
+		}
+	}
+	// from the top, as each split resizes the nodes below it
+	for i := len(nodes) - 1; i >= 0; i-- {
+		w := nodes[i]
+		p := w.parent
+		total := p.Height
+		if vertical {
+			total = p.Width - 1
+		}
+		if w == p.top || w == p.left {
+			p.setSplit(total, total)
+		} else {
+			p.setSplit(0, total)
+		}
+	}
+	return len(nodes) > 0
+}
+
+// Equalize splits v and the nodes below it so that the views get the same
+// size, as far as the size of v allows it, and resizes them.
+func (v *Tree) Equalize() {
+	switch {
+	case v.left != nil:
+		l, r := v.left.span(true), v.right.span(true)
+		v.setSplit((v.Width-1)*l/(l+r), v.Width-1)
+		v.left.Equalize()
+		v.right.Equalize()
+	case v.top != nil:
+		t, b := v.top.span(false), v.bottom.span(false)
+		v.setSplit(v.Height*t/(t+b), v.Height)
+		v.top.Equalize()
+		v.bottom.Equalize()
+	}
+}
+
+// span returns the largest number of views side by side in v if vertical is
+// set, or one above the other if it isn't.
+func (v *Tree) span(vertical bool) int {
+	var a, b *Tree
+	switch {
+	case v.leaf != nil:
+		return 1
+	case v.left != nil:
+		a, b = v.left, v.right
+	default:
+		a, b = v.top, v.bottom
+	}
+	n, m := a.span(vertical), b.span(vertical)
+	if (v.left != nil) == vertical {
+		return n + m
+	}
+	if n > m {
+		return n
+	}
+	return m
+}
+
+// setSplit splits the node so that its first part is n of total lines or
+// columns, and resizes it. Each view keeps at least one line and its status
+// line, and one column.
+func (v *Tree) setSplit(n, total int) {
+	if total <= 0 {
+		return
+	}
+	first, second := v.top, v.bottom
+	vertical := v.left != nil
+	if vertical {
+		first, second = v.left, v.right
+	}
+	if max := total - second.minSize(vertical); n > max {
+		n = max
+	}
+	if min := first.minSize(vertical); n < min {
+		n = min
+	}
+	if n < 0 {
+		n = 0
+	}
+	if n > total {
+		n = total
+	}
+	// half a step more, so that the size isn't rounded down below n
+	v.split = (float32(n) + 0.5) / float32(total)
+	if v.split > 1.0 {
+		v.split = 1.0
+	}
+	v.Resize(v.Rect)
+}
+
+// minSize returns the smallest width of v if vertical is set, or else its
+// smallest height, leaving one column or one line and the status line to
+// each view.
+func (v *Tree) minSize(vertical bool) int {
+	var a, b *Tree
+	switch {
+	case v.leaf != nil:
+		if vertical {
+			return 1
+		}
+		return 2
+	case v.left != nil:
+		a, b = v.left, v.right
+	default:
+		a, b = v.top, v.bottom
+	}
+	n, m := a.minSize(vertical), b.minSize(vertical)
+	if (v.left != nil) != vertical {
+		if n > m {
+			return n
+		}
+		return m
+	}
+	if vertical {
+		// one column is taken by the splitter
+		return n + m + 1
+	}
+	return n + m
+}
+
 func (v *Tree) Reparent(parent *Tree) {
 	v.parent = parent
 	if v.left != nil {
@@ -231,6 +443,120 @@ func (v *Tree) Reparent(parent *Tree) {
 	}
 }
 
+// MoveToEdge takes the leaf v out of the tree whose root is root, and splits
+// the rest of the tree with it, so that v takes the whole height of the left
+// or right edge if vertical is set, or else the whole width of the top or
+// bottom edge. first selects the left or top edge. It returns the new root.
+func (v *Tree) MoveToEdge(root *Tree, vertical, first bool) *Tree {
+	p := v.parent
+	if p == nil {
+		return root
+	}
+	// replace the parent with the sibling, as when v is killed; the rest of
+	// the tree is still found at root
+	pp := p.parent
+	*p = *v.Sibling()
+	p.Reparent(pp)
+
+	t := &Tree{split: 0.5}
+	a, b := &t.top, &t.bottom
+	if vertical {
+		a, b = &t.left, &t.right
+	}
+	if first {
+		*a, *b = v, root
+	} else {
+		*a, *b = root, v
+	}
+	t.Reparent(nil)
+	return t
+}
+
+// Rotate moves each view of the row or column of views containing the leaf v
+// to the next place, downwards or rightwards, or to the previous one if
+// reverse is set. The last view goes to the first place. It returns the node
+// of the view of v, and reports whether the views could be rotated, which
+// they can't if one of the places holds more than one view.
+func (v *Tree) Rotate(reverse bool) (*Tree, bool) {
+	nodes := v.group()
+	for _, t := range nodes {
+		if t.leaf == nil {
+			return v, false
+		}
+	}
+	n, view := len(nodes), v.leaf
+	views := make([]*View, n)
+	for i, t := range nodes {
+		j := (i + 1) % n
+		if reverse {
+			j = (i + n - 1) % n
+		}
+		views[j] = t.leaf
+	}
+	for i, t := range nodes {
+		t.leaf = views[i]
+		if t.leaf == view {
+			v = t
+		}
+	}
+	return v, true
+}
+
+// Exchange swaps the view of the leaf v with the next one in its row or
+// column of views, or the previous one if it is the last. It returns the node
+// of the view of v, and reports whether the views could be swapped, which
+// they can't if the other place holds more than one view.
+func (v *Tree) Exchange() (*Tree, bool) {
+	nodes := v.group()
+	if len(nodes) < 2 {
+		return v, false
+	}
+	i := 0
+	for nodes[i] != v {
+		i++
+	}
+	other := nodes[len(nodes)-2]
+	if i+1 < len(nodes) {
+		other = nodes[i+1]
+	}
+	if other.leaf == nil {
+		return v, false
+	}
+	v.leaf, other.leaf = other.leaf, v.leaf
+	return other, true
+}
+
+// group returns the places of the row or column of views containing the leaf
+// v, in order. They are the children of the nodes splitting it in the same
+// direction as its parent, and may be split in the other direction.
+func (v *Tree) group() []*Tree {
+	top := v.parent
+	if top == nil {
+		return []*Tree{v}
+	}
+	vertical := top.left != nil
+	for top.parent != nil && (top.parent.left != nil) == vertical {
+		top = top.parent
+	}
+
+	var nodes []*Tree
+	var walk func(t *Tree)
+	walk = func(t *Tree) {
+		switch {
+		case t.leaf != nil || (t.left != nil) != vertical:
+			nodes = append(nodes, t)
+		case vertical:
+			walk(t.left)
+			walk(t.right)
+		default:
+			walk(t.top)
+			walk(t.bottom)
+		}
+	}
+	walk(top)
+	return nodes
+}
+
 func (v *Tree) Sibling() *Tree {
 	p := v.parent
 	if p == nil {
@@ -249,6 +575,29 @@ func (v *Tree) Sibling() *Tree {
 	panic("unreachable")
 }
 
+// LeafAt returns the leaf node displayed at the screen position x, y, or nil
+// if there is none, e.g. on a splitter.
+func (v *Tree) LeafAt(x, y int) *Tree {
+	if x < v.X || y < v.Y || x >= v.X+v.Width || y >= v.Y+v.Height {
+		return nil
+	}
+	switch {
+	case v.leaf != nil:
+		return v
+	case v.left != nil:
+		if t := v.left.LeafAt(x, y); t != nil {
+			return t
+		}
+		return v.right.LeafAt(x, y)
+	case v.top != nil:
+		if t := v.top.LeafAt(x, y); t != nil {
+			return t
+		}
+		return v.bottom.LeafAt(x, y)
+	}
+	return nil
+}
+
 func (v *Tree) FirstLeafNode() *Tree {
 	if v.left != nil {
 		return v.left.FirstLeafNode()
@@ -73,9 +73,6 @@ func (r byteRange) includes(offset int) bool {
 	return r.begin <= offset && r.end > offset
 }
 
-const hlFG = termbox.ColorCyan
-const hlBG = termbox.ColorBlue
-
 type Tag struct {
 	begLine   int
 	begOffset int
@@ -177,10 +174,14 @@ func (s Selection) EffectiveRange() (r buffer.Range) {
 	case SelectionLine:
 		// Delete from the beginning of first line to the start of line after last.
 		r.Start.Boffset = 0
-		r.End.NextLine()
-		// XXX NextLine() sets Boffset to -1 which doesn't work for us,
-		// but gets specially handled in view.MoveCursorTo
-		r.End.Boffset = 0
+		if r.End.NextLine() {
+			// XXX NextLine() sets Boffset to -1 which doesn't work for us,
+			// but gets specially handled in view.MoveCursorTo
+			r.End.Boffset = 0
+		} else {
+			// there is no line after the last one, take all of it
+			r.End.MoveEOL()
+		}
 	case SelectionBlock:
 		// TODO Return a list of effective ranges. This will also work
 		// if we have sparse selection such as in Sublime Text
@@ -189,6 +190,24 @@ func (s Selection) EffectiveRange() (r buffer.Range) {
 	return
 }
 
+// onInsertAdjust moves the ends of the selection along with the text, after
+// the action a inserted text in the buffer.
+func (s *Selection) onInsertAdjust(a *buffer.Action) {
+	if s.Type != SelectionNone {
+		s.Start.OnInsertAdjust(a)
+		s.End.OnInsertAdjust(a)
+	}
+}
+
+// onDeleteAdjust moves the ends of the selection along with the text, after
+// the action a deleted text from the buffer.
+func (s *Selection) onDeleteAdjust(a *buffer.Action) {
+	if s.Type != SelectionNone {
+		s.Start.OnDeleteAdjust(a)
+		s.End.OnDeleteAdjust(a)
+	}
+}
+
 func (s Selection) includes(c buffer.Cursor) bool {
 	if s.Type == SelectionNone {
 		return false
@@ -214,12 +233,29 @@ type Context struct {
 	setStatus  StatusFunc
 	killBuffer *[]byte
 	buffers    *[]*buffer.Buffer
+	options    *Options
 }
 
 type StatusFunc func(format string, args ...interface{})
 
-func NewContext(setStatus StatusFunc, killBuffer *[]byte, buffers *[]*buffer.Buffer) Context {
-	return Context{setStatus, killBuffer, buffers}
+func NewContext(setStatus StatusFunc, killBuffer *[]byte, buffers *[]*buffer.Buffer, options *Options) Context {
+	return Context{setStatus, killBuffer, buffers, options}
+}
+
+// Options are the settings shared by all views, changed with the :set
+// command of the editor.
+type Options struct {
+	CursorColumn bool             // Highlight the screen column of the cursor.
+	Spell        bool             // Highlight misspelled words.
+	Dictionary   utils.Dictionary // Words accepted by the spell checker.
+	SplitKeep    string           // "cursor" keeps the relative cursor row on resize, "screen" and "topline" keep the top line.
+	LastStatus   int              // Status line of the bottom views: 0 never, 1 if split, 2 always.
+	List         bool             // Draw the end of lines, tabs and trailing spaces.
+	ListChars    ListChars        // Characters drawn for the list option and long lines.
+	FillChars    FillChars        // Characters drawn around views.
+	ScrollJump   int              // Minimal number of lines scrolled when the cursor leaves the view.
+	TabStop      int              // Number of cells between two tab stops.
+	Theme        Theme            // Colors of the drawn text.
 }
 
 // A view is an abstract "window". It draws contents from a portion of a buffer into
@@ -232,6 +268,7 @@ type View struct {
 	dirty           dirtyFlag
 	highlightBytes  []byte
 	highlightRanges []byteRange
+	spellRanges     []byteRange // misspelled words of the line being drawn
 	tags            []Tag
 	redraw          chan struct{}
 
@@ -240,15 +277,42 @@ type View struct {
 
 	selection      Selection
 	showHighlights bool
+	noStatus       bool // the status line is hidden by the laststatus option
+
+	// screen column highlighted by the cursorcolumn option, or -1
+	cursorColumn int
+	spell        bool // misspelled words are highlighted
 
 	bufferEvents chan buffer.BufferEvent
+	synced       chan struct{}
+
+	locations LocationList // nil until one is set
 }
 
+// LocationList is the location list of a view, which is set and used by the
+// editor. A view split from another one gets a copy of its list.
+type LocationList interface {
+	Copy() LocationList
+}
+
+// bufferEventSync is sent through the buffer event channel by Sync.
+const bufferEventSync buffer.BufferEventType = -1
+
 // SetStatus sets the status line of the view
 func (v *View) SetStatus(format string, args ...interface{}) {
 	v.ctx.setStatus(format, args...)
 }
 
+// LocationList returns the location list of the view, nil if none was set.
+func (v *View) LocationList() LocationList {
+	return v.locations
+}
+
+// SetLocationList replaces the location list of the view with l.
+func (v *View) SetLocationList(l LocationList) {
+	v.locations = l
+}
+
 func (v *View) Buffer() *buffer.Buffer {
 	return v.buf
 }
@@ -261,6 +325,7 @@ func NewView(ctx Context, buf *buffer.Buffer, redraw chan struct{}) *View {
 		tags:            make([]Tag, 0, 10),
 		redraw:          redraw,
 		showHighlights:  true,
+		cursorColumn:    -1,
 	}
 	v.Attach(buf)
 	return v
@@ -305,6 +370,7 @@ func (v *View) Attach(b *buffer.Buffer) {
 	// Add a small message buffer, otherwise buffer methods
 	// sending several consequitive events will lock up.
 	v.bufferEvents = make(chan buffer.BufferEvent, 10)
+	v.synced = make(chan struct{})
 	v.buf.AddListener(v.bufferEvents)
 	go v.bufferEventLoop()
 
@@ -316,6 +382,9 @@ func (v *View) bufferEventLoop() {
 		switch e.Type {
 		case buffer.BufferEventInsert:
 			v.onInsertAdjustTopLine(e.Action)
+			// the selection may be changed through another view of the
+			// buffer, its end is set to the cursor once more below
+			v.selection.onInsertAdjust(e.Action)
 			c := v.cursor
 			c.OnInsertAdjust(e.Action)
 			v.MoveCursorTo(c)
@@ -324,6 +393,7 @@ func (v *View) bufferEventLoop() {
 			// v.onInsert(e.Action)
 		case buffer.BufferEventDelete:
 			v.onDeleteAdjustTopLine(e.Action)
+			v.selection.onDeleteAdjust(e.Action)
 			c := v.cursor
 			c.OnDeleteAdjust(e.Action)
 			v.MoveCursorTo(c)
@@ -350,11 +420,34 @@ func (v *View) bufferEventLoop() {
 			v.dirty |= dirtyStatus
 		case buffer.BufferEventSave:
 			v.dirty |= dirtyStatus
+		case buffer.BufferEventSign:
+			// the sign column may have appeared or disappeared
+			v.adjustLineVoffset()
+			v.dirty = dirtyEverything
+		case bufferEventSync:
+			v.synced <- struct{}{}
+			continue
+		}
+		// A redraw may already be pending, which will include this event.
+		select {
+		case v.redraw <- struct{}{}:
+		default:
 		}
-		v.redraw <- struct{}{}
 	}
 }
 
+// Sync waits until the view has processed all pending buffer events. Commands
+// must call it before placing the cursor after modifying the buffer, as the
+// events would otherwise adjust the new cursor position once more. It does
+// nothing once the view is detached.
+func (v *View) Sync() {
+	if v.bufferEvents == nil {
+		return
+	}
+	v.bufferEvents <- buffer.BufferEvent{Type: bufferEventSync}
+	<-v.synced
+}
+
 func (v *View) Detach() {
 	// Stop listening to current buffer and close event loop.
 	v.buf.RemoveListener(v.bufferEvents)
@@ -365,17 +458,29 @@ func (v *View) Detach() {
 
 // Resize the 'v.uibuf', adjusting things accordingly.
 func (v *View) resize(w, h int) {
+	oldHeight := v.height()
+	row := v.cursor.LineNum - v.topLineNum
 	v.uiBuf.Resize(w, h)
 	v.adjustLineVoffset()
+	if v.ctx.options != nil && v.ctx.options.SplitKeep == "cursor" && oldHeight > 0 {
+		// keep the cursor at the same height in proportion to the view,
+		// instead of scrolling only once it is off screen
+		v.moveTopLineNtimes(row - row*v.height()/oldHeight)
+	}
 	v.adjustTopLine()
 	v.dirty = dirtyEverything
 }
 
 func (v *View) height() int {
+	if v.noStatus {
+		return v.uiBuf.Height
+	}
 	return v.uiBuf.Height - 1
 }
 
-func (v *View) verticalThreshold() int {
+// VerticalThreshold returns the number of lines kept between the cursor and
+// the top or the bottom of the view, as the view scrolls.
+func (v *View) VerticalThreshold() int {
 	maxVthreshold := (v.height() - 1) / 2
 	if VerticalThreshold > maxVthreshold {
 		return maxVthreshold
@@ -391,24 +496,71 @@ func (v *View) horizontalThreshold() int {
 	return HorizontalThreshold
 }
 
+// width returns the number of screen columns showing the text of the view.
 func (v *View) width() int {
-	return v.uiBuf.Width
+	return v.uiBuf.Width - v.contentOffset()
+}
+
+// contentOffset returns the number of screen columns left of the text of the
+// view, taken by gutters such as line numbers. Every translation between the
+// screen columns of the view and the visual columns of the text goes through
+// it.
+func (v *View) contentOffset() int {
+	if v.buf.HasSigns() {
+		return signColumnWidth
+	}
+	return 0
+}
+
+// signColumnWidth is the width of the sign column, shown when a line of the
+// buffer has a sign.
+const signColumnWidth = 2
+
+// drawSign draws the sign column of the line displayed on the row y.
+func (v *View) drawSign(line *buffer.Line, y int) {
+	t := v.theme()
+	color := t.Sign
+	sign, ok := v.buf.Sign(line)
+	if ok && sign.Color != "" {
+		if fg, err := parseAttribute(sign.Color); err == nil {
+			color.Fg = fg
+		}
+	}
+	cell := termbox.Cell{Ch: ' ', Fg: color.Fg, Bg: color.Bg}
+	v.uiBuf.Fill(tulib.Rect{X: 0, Y: y, Width: signColumnWidth, Height: 1}, cell)
+	if ok {
+		cell.Ch = sign.Glyph
+		v.uiBuf.Set(0, y, cell)
+	}
 }
 
 func (v *View) SetHighlightBytes(b []byte) {
 	v.highlightBytes = b
+	v.highlightRanges = v.highlightRanges[:0]
 	v.dirty |= dirtyContents
 }
 
 func (v *View) drawLine(line *buffer.Line, lineNum, coff, lineVoffset int) {
 	x := 0
 	tabstop := 0
+	ts := v.TabStop()
 	bx := 0
 	data := line.Data
+	width := v.width()
+	coff += v.contentOffset()
+	lc, list := v.listChars()
+	// start of the trailing spaces
+	trail := utils.IndexLastNonSpace(data) + 1
 
 	if len(v.highlightBytes) > 0 {
 		v.findHighlightRangesForLine(data)
 	}
+	v.spellRanges = v.spellRanges[:0]
+	if v.spell {
+		v.ctx.options.Dictionary.IterMisspellings(data, func(start, end int) {
+			v.spellRanges = append(v.spellRanges, byteRange{start, end})
+		})
+	}
 	for {
 		rx := x - lineVoffset
 		if len(data) == 0 {
@@ -416,16 +568,11 @@ func (v *View) drawLine(line *buffer.Line, lineNum, coff, lineVoffset int) {
 		}
 
 		if x == tabstop {
-			tabstop += utils.TabstopLength
+			tabstop += ts
 		}
 
-		if rx >= v.uiBuf.Width {
-			last := coff + v.uiBuf.Width - 1
-			v.uiBuf.Cells[last] = termbox.Cell{
-				Ch: '→',
-				Fg: termbox.ColorDefault,
-				Bg: termbox.ColorDefault,
-			}
+		if rx >= width {
+			v.drawExtends(coff + width - 1)
 			break
 		}
 
@@ -433,53 +580,105 @@ func (v *View) drawLine(line *buffer.Line, lineNum, coff, lineVoffset int) {
 		switch {
 		case r == '\t':
 			// fill with spaces to the next tabstop
+			ch := ' '
+			if list && lc.Tab[0] != 0 {
+				ch = lc.Tab[0]
+			}
 			for ; x < tabstop; x++ {
 				rx := x - lineVoffset
-				if rx >= v.uiBuf.Width {
+				if rx >= width {
 					break
 				}
 
 				if rx >= 0 {
 					v.uiBuf.Cells[coff+rx] = v.makeCell(
-						lineNum, bx, ' ')
+						lineNum, bx, ch)
+				}
+				if list && lc.Tab[0] != 0 {
+					ch = lc.Tab[1]
 				}
 			}
+		case r == ' ' && list && lc.Trail != 0 && bx >= trail:
+			if rx >= 0 {
+				v.uiBuf.Cells[coff+rx] = v.makeCell(
+					lineNum, bx, lc.Trail)
+			}
+			x++
 		case r < 32:
 			// invisible chars like ^R or ^@
+			control := v.theme().Control
 			if rx >= 0 {
 				v.uiBuf.Cells[coff+rx] = termbox.Cell{
 					Ch: '^',
-					Fg: termbox.ColorRed,
-					Bg: termbox.ColorDefault,
+					Fg: control.Fg,
+					Bg: control.Bg,
 				}
 			}
 			x++
 			rx = x - lineVoffset
-			if rx >= v.uiBuf.Width {
+			if rx >= width {
 				break
 			}
 			if rx >= 0 {
 				v.uiBuf.Cells[coff+rx] = termbox.Cell{
 					Ch: utils.InvisibleRuneTable[r],
-					Fg: termbox.ColorRed,
-					Bg: termbox.ColorDefault,
+					Fg: control.Fg,
+					Bg: control.Bg,
 				}
 			}
 			x++
 		default:
+			w := utils.RuneAdvanceLen(r, x, ts)
+			if w == 0 {
+				// combining marks have no cell of their own
+				break
+			}
+			if rx+w > width {
+				// a wide rune not fitting in the last cell
+				v.drawExtends(coff + width - 1)
+				return
+			}
 			if rx >= 0 {
 				v.uiBuf.Cells[coff+rx] = v.makeCell(
 					lineNum, bx, r)
 			}
-			x++
+			// the second cell of a wide rune is covered when it is
+			// drawn, it only gets the attributes
+			for i := 1; i < w; i++ {
+				if rx+i >= 0 {
+					v.uiBuf.Cells[coff+rx+i] = v.makeCell(
+						lineNum, bx, ' ')
+				}
+			}
+			x += w
 		}
 		data = data[rlen:]
 		bx += rlen
 	}
 
-	if lineVoffset != 0 {
+	if rx := x - lineVoffset; list && lc.Eol != 0 && rx >= 0 && rx < width {
+		control := v.theme().Control
+		v.uiBuf.Cells[coff+rx] = termbox.Cell{
+			Ch: lc.Eol,
+			Fg: control.Fg,
+			Bg: control.Bg,
+		}
+	}
+	if lineVoffset != 0 && lc.Precedes != 0 {
 		v.uiBuf.Cells[coff] = termbox.Cell{
-			Ch: '←',
+			Ch: lc.Precedes,
+			Fg: termbox.ColorDefault,
+			Bg: termbox.ColorDefault,
+		}
+	}
+}
+
+// drawExtends draws the character of a line going past the last column in
+// the cell i.
+func (v *View) drawExtends(i int) {
+	if lc, _ := v.listChars(); lc.Extends != 0 {
+		v.uiBuf.Cells[i] = termbox.Cell{
+			Ch: lc.Extends,
 			Fg: termbox.ColorDefault,
 			Bg: termbox.ColorDefault,
 		}
@@ -491,6 +690,8 @@ func (v *View) drawContents() {
 		v.highlightRanges = v.highlightRanges[:0]
 	}
 
+	v.spell = v.spellEnabled()
+
 	// clear the buffer
 	v.uiBuf.Fill(v.uiBuf.Rect, termbox.Cell{
 		Ch: ' ',
@@ -498,11 +699,12 @@ func (v *View) drawContents() {
 		Bg: termbox.ColorDefault,
 	})
 
-	if v.uiBuf.Width == 0 || v.uiBuf.Height == 0 {
+	if v.width() <= 0 || v.uiBuf.Height == 0 {
 		return
 	}
 
-	// draw lines
+	// draw lines, one row each: long lines are scrolled horizontally
+	// TODO: soft wrap, with the showbreak option marking continuation rows
 	line := v.topLine
 	coff := 0
 	for y, h := 0, v.height(); y < h; y++ {
@@ -516,27 +718,83 @@ func (v *View) drawContents() {
 		} else {
 			v.drawLine(line, v.topLineNum+y, coff, 0)
 		}
+		if v.buf.HasSigns() {
+			v.drawSign(line, y)
+		}
 
 		coff += v.uiBuf.Width
 		line = line.Next
 	}
+
+	v.cursorColumn = v.cursorColumnX()
+	if v.cursorColumn != -1 {
+		v.drawCursorColumn()
+	}
+}
+
+func (v *View) spellEnabled() bool {
+	o := v.ctx.options
+	return o != nil && o.Spell && o.Dictionary != nil
+}
+
+// cursorColumnX returns the screen column to highlight with the cursorcolumn
+// option, or -1 if there is none.
+func (v *View) cursorColumnX() int {
+	if v.ctx.options == nil || !v.ctx.options.CursorColumn {
+		return -1
+	}
+	x := v.cursorVoffset - v.lineVoffset
+	if x < 0 || x >= v.width() {
+		return -1
+	}
+	return v.contentOffset() + x
+}
+
+// drawCursorColumn highlights the cursor column on every line of the view,
+// except where text is selected or highlighted.
+func (v *View) drawCursorColumn() {
+	t := v.theme()
+	for y, h := 0, v.height(); y < h; y++ {
+		cell := &v.uiBuf.Cells[y*v.uiBuf.Width+v.cursorColumn]
+		if cell.Bg == t.Selection.Bg || cell.Bg == t.Highlight.Bg {
+			continue
+		}
+		cell.Bg = t.CursorColumn.Bg
+		if cell.Fg == termbox.ColorDefault {
+			// keep the character readable on dark terminals
+			cell.Fg = t.CursorColumn.Fg
+		}
+	}
+}
+
+// TabStop returns the number of cells between two tab stops, set by the
+// tabstop option.
+func (v *View) TabStop() int {
+	if o := v.ctx.options; o != nil && o.TabStop > 0 {
+		return o.TabStop
+	}
+	return utils.TabstopLength
 }
 
 func (v *View) drawStatus() {
-	// fill background with '─'
+	if v.noStatus {
+		return
+	}
+	// fill background with the status fill character
+	t := v.theme()
 	lp := tulib.DefaultLabelParams
-	lp.Bg = termbox.AttrReverse
-	lp.Fg = termbox.AttrReverse | termbox.AttrBold
+	lp.Bg = t.StatusName.Bg
+	lp.Fg = t.StatusName.Fg
 	v.uiBuf.Fill(
 		tulib.Rect{X: 0, Y: v.height(), Width: v.uiBuf.Width, Height: 1},
-		termbox.Cell{Fg: termbox.AttrReverse, Bg: termbox.AttrReverse, Ch: '─'},
+		termbox.Cell{Fg: t.Status.Fg, Bg: t.Status.Bg, Ch: v.fillChars().Status},
 	)
 
 	// on disk sync status
 	if !v.buf.SyncedWithDisk() {
 		cell := termbox.Cell{
-			Fg: termbox.AttrReverse,
-			Bg: termbox.AttrReverse,
+			Fg: t.Status.Fg,
+			Bg: t.Status.Bg,
 			Ch: '*',
 		}
 		v.uiBuf.Set(1, v.height(), cell)
@@ -547,7 +805,7 @@ func (v *View) drawStatus() {
 	fmt.Fprintf(&v.statusBuf, "  %s  ", v.buf.Name)
 	v.uiBuf.DrawLabel(tulib.Rect{X: 5, Y: v.height(), Width: v.uiBuf.Width, Height: 1}, &lp, v.statusBuf.Bytes())
 	namel := v.statusBuf.Len()
-	lp.Fg = termbox.AttrReverse
+	lp.Fg, lp.Bg = t.Status.Fg, t.Status.Bg
 	v.statusBuf.Reset()
 	fmt.Fprintf(&v.statusBuf, "(%d, %d)  ", v.cursor.LineNum, v.cursorVoffset)
 	v.uiBuf.DrawLabel(tulib.Rect{X: 5 + namel, Y: v.height(), Width: v.uiBuf.Width, Height: 1}, &lp, v.statusBuf.Bytes())
@@ -556,6 +814,9 @@ func (v *View) drawStatus() {
 
 // Draw the current view to the 'v.uibuf'.
 func (v *View) draw() {
+	if v.cursorColumnX() != v.cursorColumn || v.spellEnabled() != v.spell {
+		v.dirty |= dirtyContents
+	}
 	if v.dirty&dirtyContents != 0 {
 		v.dirty &^= dirtyContents
 		v.drawContents()
@@ -575,6 +836,33 @@ func (v *View) centerViewOnCursor() {
 	v.dirty = dirtyEverything
 }
 
+// VisibleRange returns the number of the top line of the view and the number
+// of lines it can show.
+func (v *View) VisibleRange() (topNum, height int) {
+	return v.topLineNum, v.height()
+}
+
+// Center scrolls the view to put the cursor line in its middle.
+func (v *View) Center() {
+	v.centerViewOnCursor()
+}
+
+// ScrollTop scrolls the view to put the cursor line at its top.
+func (v *View) ScrollTop() {
+	v.topLine = v.cursor.Line
+	v.topLineNum = v.cursor.LineNum
+	v.dirty = dirtyEverything
+}
+
+// ScrollBottom scrolls the view to put the cursor line at its bottom, or as
+// low as the start of the buffer allows.
+func (v *View) ScrollBottom() {
+	v.topLine = v.cursor.Line
+	v.topLineNum = v.cursor.LineNum
+	v.moveTopLineNtimes(-(v.height() - 1))
+	v.dirty = dirtyEverything
+}
+
 func (v *View) MoveCursorToLine(n int) {
 	v.moveCursorBeginningOfFile()
 	v.moveCursorLineNtimes(n - 1)
@@ -624,7 +912,7 @@ func (v *View) moveCursorLineNtimes(n int) {
 // When 'top_line' was changed, call this function to possibly adjust the
 // 'cursor_line'.
 func (v *View) adjustCursorLine() {
-	vt := v.verticalThreshold()
+	vt := v.VerticalThreshold()
 	cursor := v.cursor.Line
 	co := v.cursor.LineNum - v.topLineNum
 	h := v.height()
@@ -639,7 +927,7 @@ func (v *View) adjustCursorLine() {
 
 	if cursor != v.cursor.Line {
 		cursor = v.cursor.Line
-		bo, co, vo := cursor.FindClosestOffsets(v.lastCursorVoffset)
+		bo, co, vo := cursor.FindClosestOffsets(v.lastCursorVoffset, v.TabStop())
 		v.cursor.Boffset = bo
 		v.cursorCoffset = co
 		v.cursorVoffset = vo
@@ -652,27 +940,46 @@ func (v *View) adjustCursorLine() {
 // When 'cursor_line' was changed, call this function to possibly adjust the
 // 'top_line'.
 func (v *View) adjustTopLine() {
-	vt := v.verticalThreshold()
+	vt := v.VerticalThreshold()
 	top := v.topLine
 	co := v.cursor.LineNum - v.topLineNum
 	h := v.height()
 
 	if top.Next != nil && co >= h-vt {
-		v.moveTopLineNtimes(co - (h - vt) + 1)
+		// the cursor stays out of the threshold at the top
+		v.moveTopLineNtimes(v.scrollJump(co-(h-vt)+1, co-vt))
 		v.dirty = dirtyEverything
 	}
 
 	if top.Prev != nil && co < vt {
-		v.moveTopLineNtimes(co - vt)
+		// and out of the threshold at the bottom
+		v.moveTopLineNtimes(-v.scrollJump(vt-co, h-vt-1-co))
 		v.dirty = dirtyEverything
 	}
 }
 
+// scrollJump returns the number of lines to scroll the view by when it needs
+// n more lines: as many as the scrolljump option if there are less, but no
+// more than limit.
+func (v *View) scrollJump(n, limit int) int {
+	j := n
+	if v.ctx.options != nil && v.ctx.options.ScrollJump > j {
+		j = v.ctx.options.ScrollJump
+	}
+	if j > limit {
+		j = limit
+	}
+	if j < n {
+		j = n
+	}
+	return j
+}
+
 // When 'cursor_voffset' was changed usually > 0, then call this function to
 // possibly adjust 'line_voffset'.
 func (v *View) adjustLineVoffset() {
 	ht := v.horizontalThreshold()
-	w := v.uiBuf.Width
+	w := v.width()
 	vo := v.lineVoffset
 	cvo := v.cursorVoffset
 	threshold := w - 1
@@ -697,12 +1004,59 @@ func (v *View) adjustLineVoffset() {
 	}
 }
 
+// ScrollLeftEdge scrolls the cursor line horizontally to put the cursor at
+// the left edge of the view, as far as the horizontal threshold allows.
+func (v *View) ScrollLeftEdge() {
+	v.setLineVoffset(v.cursorVoffset - v.horizontalThreshold())
+}
+
+// ScrollRightEdge scrolls the cursor line horizontally to put the cursor at
+// the right edge of the view, as far as the horizontal threshold allows.
+func (v *View) ScrollRightEdge() {
+	v.setLineVoffset(v.cursorVoffset + v.horizontalThreshold() - v.width() + 1)
+}
+
+// setLineVoffset sets the number of cells of the cursor line scrolled out of
+// the view to vo, or 0 if it is negative.
+func (v *View) setLineVoffset(vo int) {
+	if vo < 0 {
+		vo = 0
+	}
+	if v.lineVoffset != vo {
+		v.lineVoffset = vo
+		v.dirty = dirtyEverything
+	}
+}
+
 func (v *View) CursorPosition() (int, int) {
 	y := v.cursor.LineNum - v.topLineNum
-	x := v.cursorVoffset - v.lineVoffset
+	x := v.contentOffset() + v.cursorVoffset - v.lineVoffset
 	return x, y
 }
 
+// MoveCursorToPosition moves the cursor to the character displayed at x, y,
+// relative to the top left corner of the view, or to the closest one.
+func (v *View) MoveCursorToPosition(x, y int) {
+	if h := v.height(); y >= h {
+		y = h - 1
+	}
+	// a click in a gutter goes to the start of the line
+	if x -= v.contentOffset(); x < 0 {
+		x = 0
+	}
+	c := buffer.Cursor{Line: v.topLine, LineNum: v.topLineNum}
+	for ; y > 0 && c.Line.Next != nil; y-- {
+		c.Line = c.Line.Next
+		c.LineNum++
+	}
+	if c.Line == v.cursor.Line {
+		// only the cursor line is scrolled horizontally
+		x += v.lineVoffset
+	}
+	c.MoveToColumn(x, v.TabStop())
+	v.MoveCursorTo(c)
+}
+
 // Move cursor to the 'boffset' position in the 'line'. Obviously 'line' must be
 // from the attached buffer. If 'boffset' < 0, use 'last_cursor_voffset'. Keep
 // in mind that there is no need to maintain connections between lines (e.g. for
@@ -710,12 +1064,12 @@ func (v *View) CursorPosition() (int, int) {
 func (v *View) MoveCursorTo(c buffer.Cursor) {
 	v.dirty |= dirtyStatus
 	if c.Boffset < 0 {
-		bo, co, vo := c.Line.FindClosestOffsets(v.lastCursorVoffset)
+		bo, co, vo := c.Line.FindClosestOffsets(v.lastCursorVoffset, v.TabStop())
 		v.cursor.Boffset = bo
 		v.cursorCoffset = co
 		v.cursorVoffset = vo
 	} else {
-		vo, co := c.VoffsetCoffset()
+		vo, co := c.VoffsetCoffset(v.TabStop())
 		v.cursor.Boffset = c.Boffset
 		v.cursorCoffset = co
 		v.cursorVoffset = vo
@@ -910,6 +1264,15 @@ func (v *View) inOneOfHighlightRanges(offset int) bool {
 	return false
 }
 
+func (v *View) inOneOfSpellRanges(offset int) bool {
+	for _, r := range v.spellRanges {
+		if r.includes(offset) {
+			return true
+		}
+	}
+	return false
+}
+
 func (v *View) tag(line, offset int) *Tag {
 	for i := range v.tags {
 		t := &v.tags[i]
@@ -923,11 +1286,12 @@ func (v *View) tag(line, offset int) *Tag {
 func (v *View) makeCell(line, offset int, ch rune) termbox.Cell {
 	tag := v.tag(line, offset)
 
+	t := v.theme()
 	if v.Selection().includes(buffer.Cursor{LineNum: line, Boffset: offset}) {
 		return termbox.Cell{
 			Ch: ch,
-			Fg: termbox.ColorDefault,
-			Bg: termbox.ColorDefault | termbox.AttrReverse,
+			Fg: t.Selection.Fg,
+			Bg: t.Selection.Bg,
 		}
 	}
 
@@ -945,8 +1309,11 @@ func (v *View) makeCell(line, offset int, ch rune) termbox.Cell {
 		Bg: tag.bg,
 	}
 	if v.inOneOfHighlightRanges(offset) && v.showHighlights {
-		cell.Fg = hlFG
-		cell.Bg = hlBG
+		cell.Fg = t.Highlight.Fg
+		cell.Bg = t.Highlight.Bg
+	} else if v.inOneOfSpellRanges(offset) {
+		cell.Fg = t.Spell.Fg
+		cell.Bg = t.Spell.Bg
 	}
 	return cell
 }
@@ -0,0 +1,562 @@
+package view
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/kisielk/vigo/buffer"
+	"github.com/nsf/termbox-go"
+	"github.com/nsf/tulib"
+)
+
+func TestResizeSplitKeep(t *testing.T) {
+	b, err := buffer.NewBuffer(strings.NewReader(strings.Repeat("line\n", 100)))
+	if err != nil {
+		t.Fatal("Error creating buffer")
+	}
+	for _, test := range []struct {
+		splitKeep string
+		row       int // of the cursor once the view is resized
+	}{
+		{"cursor", 10},
+		{"topline", 14},
+	} {
+		ctx := NewContext(nil, nil, nil, &Options{SplitKeep: test.splitKeep})
+		v := NewView(ctx, b, nil)
+		v.resize(80, 41)
+		v.moveCursorLineNtimes(20)
+		v.resize(80, 21)
+		if row := v.cursor.LineNum - v.topLineNum; row != test.row {
+			t.Errorf("splitkeep=%s: got cursor row %d, want %d", test.splitKeep, row, test.row)
+		}
+		v.Detach()
+	}
+}
+
+func TestReadTheme(t *testing.T) {
+	theme, err := ReadTheme(strings.NewReader("# comment\n\nhighlight black,bold yellow\nspell red\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (Color{termbox.ColorBlack | termbox.AttrBold, termbox.ColorYellow}); theme.Highlight != want {
+		t.Errorf("got highlight %v, want %v", theme.Highlight, want)
+	}
+	if want := (Color{termbox.ColorRed, termbox.ColorDefault}); theme.Spell != want {
+		t.Errorf("got spell %v, want %v", theme.Spell, want)
+	}
+	if theme.Status != DefaultTheme.Status {
+		t.Errorf("got status %v, want the default %v", theme.Status, DefaultTheme.Status)
+	}
+
+	for _, s := range []string{"foo red", "spell", "spell red blue green", "spell purple"} {
+		if _, err := ReadTheme(strings.NewReader(s)); err == nil {
+			t.Errorf("%q: expected an error", s)
+		}
+	}
+}
+
+func TestThemeFallback(t *testing.T) {
+	for _, test := range []struct {
+		color, want termbox.Attribute
+	}{
+		{termbox.ColorDefault, termbox.ColorDefault},
+		{termbox.ColorRed | termbox.AttrBold, termbox.ColorRed | termbox.AttrBold},
+		{paletteColor(12), termbox.ColorBlue},
+		{paletteColor(196), termbox.ColorRed},
+		{paletteColor(226) | termbox.AttrUnderline, termbox.ColorYellow | termbox.AttrUnderline},
+		{paletteColor(51), termbox.ColorCyan},
+		{paletteColor(235), termbox.ColorBlack},
+		{paletteColor(250), termbox.ColorWhite},
+	} {
+		theme := Theme{Highlight: Color{test.color, test.color}}.Fallback()
+		if got := theme.Highlight; got != (Color{test.want, test.want}) {
+			t.Errorf("%d: got %v, want %v", test.color, got.Fg, test.want)
+		}
+	}
+}
+
+func TestSelectionTwoViews(t *testing.T) {
+	b, err := buffer.NewBuffer(strings.NewReader("one\ntwo\nthree\nfour\n"))
+	if err != nil {
+		t.Fatal("Error creating buffer")
+	}
+	ctx := NewContext(nil, nil, nil, nil)
+	v1 := NewView(ctx, b, nil)
+	defer v1.Detach()
+	v2 := NewView(ctx, b, nil)
+	defer v2.Detach()
+
+	v1.moveCursorLineNtimes(1)
+	start := v1.Cursor()
+	v1.SetSelection(Selection{Range: buffer.Range{Start: start, End: start}, Type: SelectionChar})
+	v1.moveCursorLineNtimes(1)
+	v1.MoveCursorTo(buffer.Cursor{Line: v1.cursor.Line, LineNum: v1.cursor.LineNum, Boffset: 2})
+
+	// insert a line above the selection and delete a character at its start
+	// through the other view
+	b.Insert(v2.Cursor(), []byte("zero\n"))
+	v2.Sync()
+	// the cursor of v2 moved along with the insertion, to "one"
+	c := v2.Cursor()
+	c.LineNum, c.Line, c.Boffset = 3, c.Line.Next, 0
+	b.Delete(c, 1)
+	v1.Sync()
+	v2.Sync()
+
+	s := v1.Selection()
+	if s.Start.LineNum != 3 || s.Start.Boffset != 0 || string(s.Start.Line.Data) != "wo" {
+		t.Errorf("got selection start %d:%d %q, want 3:0 \"wo\"", s.Start.LineNum, s.Start.Boffset, s.Start.Line.Data)
+	}
+	if s.End.LineNum != 4 || s.End.Boffset != 2 || string(s.End.Line.Data) != "three" {
+		t.Errorf("got selection end %d:%d %q, want 4:2 \"three\"", s.End.LineNum, s.End.Boffset, s.End.Line.Data)
+	}
+	if s := v2.Selection(); s.Type != SelectionNone {
+		t.Errorf("got selection type %d in the other view, want none", s.Type)
+	}
+
+	// a detached view has nothing to wait for
+	v3 := NewView(ctx, b, nil)
+	v3.Detach()
+	v3.Sync()
+}
+
+func TestSelectionAdjust(t *testing.T) {
+	for _, test := range []struct {
+		name       string
+		edit       func(b *buffer.Buffer, first *buffer.Line)
+		start, end [2]int // line number and byte offset
+	}{
+		{
+			name: "insert above",
+			edit: func(b *buffer.Buffer, first *buffer.Line) {
+				b.Insert(buffer.Cursor{Line: first, LineNum: 1}, []byte("zero\n"))
+			},
+			start: [2]int{3, 1}, end: [2]int{4, 3},
+		},
+		{
+			name: "insert before the start",
+			edit: func(b *buffer.Buffer, first *buffer.Line) {
+				b.Insert(buffer.Cursor{Line: first.Next, LineNum: 2}, []byte("xx"))
+			},
+			start: [2]int{2, 3}, end: [2]int{3, 3},
+		},
+		{
+			name: "delete inside",
+			edit: func(b *buffer.Buffer, first *buffer.Line) {
+				b.Delete(buffer.Cursor{Line: first.Next.Next, LineNum: 3}, 2)
+			},
+			start: [2]int{2, 1}, end: [2]int{3, 1},
+		},
+		{
+			name: "delete lines inside",
+			edit: func(b *buffer.Buffer, first *buffer.Line) {
+				b.Delete(buffer.Cursor{Line: first.Next, LineNum: 2, Boffset: 2}, 5)
+			},
+			start: [2]int{2, 1}, end: [2]int{2, 2},
+		},
+		{
+			name: "undo insert above",
+			edit: func(b *buffer.Buffer, first *buffer.Line) {
+				b.FinalizeActionGroup()
+				b.Insert(buffer.Cursor{Line: first, LineNum: 1}, []byte("zero\n"))
+				b.FinalizeActionGroup()
+				b.Undo()
+			},
+			start: [2]int{2, 1}, end: [2]int{3, 3},
+		},
+	} {
+		b, err := buffer.NewBuffer(strings.NewReader("one\ntwo\nthree\nfour\n"))
+		if err != nil {
+			t.Fatal("Error creating buffer")
+		}
+		v := NewView(NewContext(func(string, ...interface{}) {}, nil, nil, nil), b, nil)
+		first := b.FirstLine
+		start := buffer.Cursor{Line: first.Next, LineNum: 2, Boffset: 1}
+		v.MoveCursorTo(start)
+		v.SetSelection(Selection{Range: buffer.Range{Start: start, End: start}, Type: SelectionChar})
+		v.MoveCursorTo(buffer.Cursor{Line: first.Next.Next, LineNum: 3, Boffset: 3})
+
+		test.edit(b, first)
+		v.Sync()
+
+		s := v.Selection()
+		if got := [2]int{s.Start.LineNum, s.Start.Boffset}; got != test.start {
+			t.Errorf("%s: got selection start %v, want %v", test.name, got, test.start)
+		}
+		if got := [2]int{s.End.LineNum, s.End.Boffset}; got != test.end {
+			t.Errorf("%s: got selection end %v, want %v", test.name, got, test.end)
+		}
+		v.Detach()
+	}
+}
+
+func TestCursorPosition(t *testing.T) {
+	b, err := buffer.NewBuffer(strings.NewReader("one\n\tt世o\n" + strings.Repeat("x", 100) + "\n"))
+	if err != nil {
+		t.Fatal("Error creating buffer")
+	}
+	v := NewView(NewContext(nil, nil, nil, nil), b, nil)
+	defer v.Detach()
+	v.resize(20, 10)
+
+	for _, test := range []struct {
+		x, y    int // clicked screen position
+		boffset int
+		cx      int // screen column of the cursor
+	}{
+		{2, 0, 2, 2},
+		{8, 1, 1, 8},
+		{3, 1, 0, 0},
+		{10, 1, 2, 9},
+		{50, 0, 3, 3},
+		{15, 2, 15, 15},
+	} {
+		v.MoveCursorToPosition(test.x, test.y)
+		c := v.Cursor()
+		if c.LineNum != test.y+1 || c.Boffset != test.boffset {
+			t.Errorf("%d,%d: got cursor at %d:%d, want %d:%d", test.x, test.y, c.LineNum, c.Boffset, test.y+1, test.boffset)
+		}
+		if x, y := v.CursorPosition(); x != test.cx || y != test.y {
+			t.Errorf("%d,%d: got cursor position %d,%d, want %d,%d", test.x, test.y, x, y, test.cx, test.y)
+		}
+	}
+}
+
+func TestScroll(t *testing.T) {
+	b, err := buffer.NewBuffer(strings.NewReader(strings.Repeat("line\n", 30)))
+	if err != nil {
+		t.Fatal("Error creating buffer")
+	}
+	v := NewView(NewContext(nil, nil, nil, nil), b, nil)
+	defer v.Detach()
+	v.resize(20, 11) // 10 lines and the status line
+
+	v.MoveCursorTo(b.LineCursor(15))
+	v.ScrollTop()
+	if v.topLineNum != 15 {
+		t.Errorf("ScrollTop: got top line %d, want 15", v.topLineNum)
+	}
+	v.ScrollBottom()
+	if v.topLineNum != 6 {
+		t.Errorf("ScrollBottom: got top line %d, want 6", v.topLineNum)
+	}
+
+	v.MoveCursorTo(b.LineCursor(3))
+	v.ScrollBottom()
+	if v.topLineNum != 1 {
+		t.Errorf("ScrollBottom near the start: got top line %d, want 1", v.topLineNum)
+	}
+	if v.topLine != b.FirstLine {
+		t.Errorf("ScrollBottom near the start: top line doesn't match its number")
+	}
+}
+
+func TestLastStatus(t *testing.T) {
+	b, err := buffer.NewBuffer(strings.NewReader("line\n"))
+	if err != nil {
+		t.Fatal("Error creating buffer")
+	}
+	for _, test := range []struct {
+		lastStatus int
+		single     int    // height of the view alone
+		split      [2]int // heights of the views split horizontally
+	}{
+		{0, 20, [2]int{9, 10}},
+		{1, 20, [2]int{9, 9}},
+		{2, 19, [2]int{9, 9}},
+	} {
+		ctx := NewContext(nil, nil, nil, &Options{LastStatus: test.lastStatus})
+		v := NewView(ctx, b, nil)
+		tree := NewTree(v)
+		tree.Resize(tulib.Rect{Width: 80, Height: 20})
+		if h := v.height(); h != test.single {
+			t.Errorf("laststatus=%d: got height %d, want %d", test.lastStatus, h, test.single)
+		}
+
+		tree.SplitHorizontally()
+		tree.Resize(tulib.Rect{Width: 80, Height: 20})
+		top, bottom := tree.Top().Leaf(), tree.Bottom().Leaf()
+		if h := [2]int{top.height(), bottom.height()}; h != test.split {
+			t.Errorf("laststatus=%d: got split heights %v, want %v", test.lastStatus, h, test.split)
+		}
+		top.Detach()
+		bottom.Detach()
+	}
+}
+
+func TestMoveToEdge(t *testing.T) {
+	b, err := buffer.NewBuffer(strings.NewReader("line\n"))
+	if err != nil {
+		t.Fatal("Error creating buffer")
+	}
+	v := NewView(NewContext(nil, nil, nil, nil), b, nil)
+	root := NewTree(v)
+	root.Resize(tulib.Rect{Width: 81, Height: 20})
+	// | a | b |
+	// |   | c |
+	root.SplitVertically()
+	root.Right().SplitHorizontally()
+	a, c := root.Left(), root.Right().Bottom()
+	defer func() { root.Walk(func(t *Tree) { t.Leaf().Detach() }) }()
+
+	root = c.MoveToEdge(root, false, true)
+	root.Resize(tulib.Rect{Width: 81, Height: 20})
+	if root.Top() != c || c.Parent() != root {
+		t.Fatal("moved view isn't at the top")
+	}
+	if c.Rect != (tulib.Rect{Width: 81, Height: 10}) {
+		t.Errorf("got moved view at %v, want the top half", c.Rect)
+	}
+	rest := root.Bottom()
+	if rest.Left() != a || rest.Right().Leaf() == nil || rest.Right().Parent() != rest {
+		t.Error("rest of the tree isn't a vertical split below the moved view")
+	}
+
+	root = root.MoveToEdge(root, true, true)
+	if root.Top() != c {
+		t.Error("moving the root changed the tree")
+	}
+}
+
+func TestRotate(t *testing.T) {
+	b, err := buffer.NewBuffer(strings.NewReader("line\n"))
+	if err != nil {
+		t.Fatal("Error creating buffer")
+	}
+	// a above b above c, and d beside them
+	root := NewTree(NewView(NewContext(nil, nil, nil, nil), b, nil))
+	root.SplitVertically()
+	col := root.Left()
+	col.SplitHorizontally()
+	col.Bottom().SplitHorizontally()
+	nodes := []*Tree{col.Top(), col.Bottom().Top(), col.Bottom().Bottom()}
+	a, bv, c := nodes[0].Leaf(), nodes[1].Leaf(), nodes[2].Leaf()
+	defer root.Walk(func(t *Tree) { t.Leaf().Detach() })
+
+	check := func(name string, want ...*View) {
+		for i, n := range nodes {
+			if n.Leaf() != want[i] {
+				t.Errorf("%s: wrong view at place %d", name, i)
+			}
+		}
+	}
+
+	if n, ok := nodes[0].Rotate(false); !ok || n != nodes[1] {
+		t.Error("rotate: view a didn't move down")
+	}
+	check("rotate", c, a, bv)
+	if n, ok := nodes[1].Rotate(true); !ok || n != nodes[0] {
+		t.Error("rotate back: view a didn't move up")
+	}
+	check("rotate back", a, bv, c)
+
+	if n, ok := nodes[2].Exchange(); !ok || n != nodes[1] {
+		t.Error("exchange: view c didn't move up")
+	}
+	check("exchange", a, c, bv)
+
+	// the row of d has a split place
+	if _, ok := root.Right().Rotate(false); ok {
+		t.Error("rotated a split place")
+	}
+	if _, ok := root.Right().Exchange(); ok {
+		t.Error("exchanged with a split place")
+	}
+}
+
+func TestScrollEdges(t *testing.T) {
+	b, err := buffer.NewBuffer(strings.NewReader(strings.Repeat("x", 100) + "\n"))
+	if err != nil {
+		t.Fatal("Error creating buffer")
+	}
+	v := NewView(NewContext(nil, nil, nil, nil), b, nil)
+	defer v.Detach()
+	v.resize(40, 10)
+
+	c := v.Cursor()
+	c.Boffset = 50
+	v.MoveCursorTo(c)
+	v.ScrollLeftEdge()
+	if x, _ := v.CursorPosition(); x != HorizontalThreshold {
+		t.Errorf("ScrollLeftEdge: got cursor at column %d, want %d", x, HorizontalThreshold)
+	}
+	v.ScrollRightEdge()
+	if x, _ := v.CursorPosition(); x != 39-HorizontalThreshold {
+		t.Errorf("ScrollRightEdge: got cursor at column %d, want %d", x, 39-HorizontalThreshold)
+	}
+
+	// the start of the line stays at the left edge
+	c.Boffset = 5
+	v.MoveCursorTo(c)
+	v.ScrollLeftEdge()
+	if x, _ := v.CursorPosition(); x != 5 {
+		t.Errorf("ScrollLeftEdge near the start: got cursor at column %d, want 5", x)
+	}
+}
+
+func TestParseListChars(t *testing.T) {
+	lc, err := ParseListChars("eol:$,tab:>-,trail:~")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := ListChars{Eol: '$', Tab: [2]rune{'>', '-'}, Trail: '~'}
+	if lc != want {
+		t.Errorf("got %+v, want %+v", lc, want)
+	}
+	for _, s := range []string{"eol", "tab:>", "trail:~~", "nbsp:+"} {
+		if _, err := ParseListChars(s); err == nil {
+			t.Errorf("ParseListChars(%q): got no error", s)
+		}
+	}
+
+	fc, err := ParseFillChars("vert:|")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (FillChars{Vert: '|', Status: '─'}); fc != want {
+		t.Errorf("got %+v, want %+v", fc, want)
+	}
+
+	// the defaults of the options are written from the default characters
+	if got, want := lc.String(), "eol:$,tab:>-,trail:~"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if lc, _ := ParseListChars(DefaultListChars.String()); lc != DefaultListChars {
+		t.Errorf("got %+v, want %+v", lc, DefaultListChars)
+	}
+	if fc, _ := ParseFillChars(DefaultFillChars.String()); fc != DefaultFillChars {
+		t.Errorf("got %+v, want %+v", fc, DefaultFillChars)
+	}
+}
+
+func TestDrawList(t *testing.T) {
+	b, err := buffer.NewBuffer(strings.NewReader("\tab  \n"))
+	if err != nil {
+		t.Fatal("Error creating buffer")
+	}
+	opts := &Options{List: true, FillChars: DefaultFillChars, Theme: DefaultTheme}
+	opts.ListChars, _ = ParseListChars("eol:$,tab:>-,trail:~")
+	v := NewView(NewContext(nil, nil, nil, opts), b, nil)
+	defer v.Detach()
+	v.resize(20, 5)
+	v.draw()
+
+	var got []rune
+	for _, c := range v.uiBuf.Cells[:13] {
+		got = append(got, c.Ch)
+	}
+	if want := ">-------ab~~$"; string(got) != want {
+		t.Errorf("got %q, want %q", string(got), want)
+	}
+}
+
+func TestGrowMaximizeEqualize(t *testing.T) {
+	b, err := buffer.NewBuffer(strings.NewReader("line\n"))
+	if err != nil {
+		t.Fatal("Error creating buffer")
+	}
+	root := NewTree(NewView(NewContext(nil, nil, nil, nil), b, nil))
+	// | a |   |
+	// | b | d |
+	// | c |   |
+	root.SplitVertically()
+	col := root.Left()
+	col.SplitHorizontally()
+	col.Bottom().SplitHorizontally()
+	a, c, d := col.Top(), col.Bottom().Bottom(), root.Right()
+	defer root.Walk(func(t *Tree) { t.Leaf().Detach() })
+	root.Resize(tulib.Rect{Width: 81, Height: 30})
+
+	root.Equalize()
+	for _, n := range []*Tree{a, col.Bottom().Top(), c} {
+		if n.Height != 10 || n.Width != 40 {
+			t.Errorf("equalize: got view of %dx%d, want 40x10", n.Width, n.Height)
+		}
+	}
+
+	if !c.Grow(3, false) || c.Height != 13 {
+		t.Errorf("grow: got height %d, want 13", c.Height)
+	}
+	if !d.Grow(-5, true) || d.Width != 35 {
+		t.Errorf("shrink: got width %d, want 35", d.Width)
+	}
+	if d.Grow(1, false) {
+		t.Error("grew a view without a split above it")
+	}
+
+	// the other views keep a line and their status line, or a column
+	if !c.Maximize(false) || c.Height != 26 || a.Height != 2 {
+		t.Errorf("maximize: got heights %d and %d, want 26 and 2", c.Height, a.Height)
+	}
+	if !a.Maximize(true) || a.Width != 79 || d.Width != 1 {
+		t.Errorf("maximize width: got widths %d and %d, want 79 and 1", a.Width, d.Width)
+	}
+	if !a.SetHeight(30) || a.Height != 26 {
+		t.Errorf("set height: got height %d, want 26", a.Height)
+	}
+	if !a.SetHeight(0) || a.Height != 2 {
+		t.Errorf("set height: got height %d, want 2", a.Height)
+	}
+}
+
+func TestEffectiveRange(t *testing.T) {
+	b, err := buffer.NewBuffer(strings.NewReader("one\ntwo\nthree"))
+	if err != nil {
+		t.Fatal("Error creating buffer")
+	}
+	cursor := func(line, boffset int) buffer.Cursor {
+		c := b.LineCursor(line)
+		c.Boffset = boffset
+		return c
+	}
+	for _, test := range []struct {
+		name       string
+		typ        SelectionType
+		start, end buffer.Cursor
+		want       [2][2]int // line numbers and byte offsets
+	}{
+		{"char backward", SelectionChar, cursor(3, 2), cursor(1, 1), [2][2]int{{1, 1}, {3, 3}}},
+		{"char to end of line", SelectionChar, cursor(1, 1), cursor(2, 3), [2][2]int{{1, 1}, {3, 0}}},
+		{"line backward", SelectionLine, cursor(2, 1), cursor(1, 2), [2][2]int{{1, 0}, {3, 0}}},
+		{"line to last line", SelectionLine, cursor(2, 2), cursor(3, 1), [2][2]int{{2, 0}, {3, 5}}},
+	} {
+		s := Selection{Range: buffer.Range{Start: test.start, End: test.end}, Type: test.typ}
+		r := s.EffectiveRange()
+		got := [2][2]int{{r.Start.LineNum, r.Start.Boffset}, {r.End.LineNum, r.End.Boffset}}
+		if got != test.want {
+			t.Errorf("%s: got range %v, want %v", test.name, got, test.want)
+		}
+		// every line between the ends is included
+		if !s.includes(cursor(2, 0)) {
+			t.Errorf("%s: middle line isn't included", test.name)
+		}
+	}
+}
+
+func TestScrollJump(t *testing.T) {
+	b, err := buffer.NewBuffer(strings.NewReader(strings.Repeat("line\n", 100)))
+	if err != nil {
+		t.Fatal("Error creating buffer")
+	}
+	opts := &Options{ScrollJump: 5}
+	v := NewView(NewContext(nil, nil, nil, opts), b, nil)
+	defer v.Detach()
+	v.resize(20, 21)
+
+	// one line past the threshold at the bottom
+	v.MoveCursorTo(b.LineCursor(21 - VerticalThreshold))
+	if top, _ := v.VisibleRange(); top != 6 {
+		t.Errorf("scrolling down: got top line %d, want 6", top)
+	}
+	v.MoveCursorTo(b.LineCursor(6 + VerticalThreshold - 1))
+	if top, _ := v.VisibleRange(); top != 1 {
+		t.Errorf("scrolling up: got top line %d, want 1", top)
+	}
+
+	// never so far that the cursor is in the other threshold
+	opts.ScrollJump = 50
+	v.MoveCursorTo(b.LineCursor(21 - VerticalThreshold))
+	if top, _ := v.VisibleRange(); top != 21-2*VerticalThreshold {
+		t.Errorf("large jump: got top line %d, want %d", top, 21-2*VerticalThreshold)
+	}
+}